@@ -6,13 +6,19 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-// Mobile represents basic info about a mobile clicked in the world.
+// Mobile represents basic info about a mobile in the world.
 type Mobile struct {
 	Index  uint8
 	Name   string
 	H, V   int16
 	PictID uint16
 	Colors uint8
+	// Type is the descriptor type (player, monster or NPC).
+	Type  uint8
+	State uint8
+	Dead  bool
+	// Range is the pixel distance from your own mobile.
+	Range int
 }
 
 // ClickInfo describes the last click in the game world.
@@ -44,20 +50,14 @@ var (
 func worldInfoAt(x, y int16) ClickInfo {
 	info := ClickInfo{X: x, Y: y}
 	stateMu.Lock()
+	self, _ := selfPosLocked()
 	for _, m := range state.liveMobs {
 		if d, ok := state.descriptors[m.Index]; ok {
 			size := mobileSize(d.PictID)
 			half := int16(size / 2)
 			if x >= m.H-half && x < m.H+half && y >= m.V-half && y < m.V+half {
 				info.OnMobile = true
-				info.Mobile = Mobile{
-					Index:  m.Index,
-					Name:   d.Name,
-					H:      m.H,
-					V:      m.V,
-					PictID: d.PictID,
-					Colors: m.Colors,
-				}
+				info.Mobile = makeMobile(m, d, self.H, self.V)
 				break
 			}
 		}
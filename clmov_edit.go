@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clmovRange is a half-open range of frames [start, end). An end of -1
+// means the end of the movie.
+type clmovRange struct {
+	start, end int
+}
+
+func (r clmovRange) contains(i int) bool {
+	return i >= r.start && (r.end < 0 || i < r.end)
+}
+
+// parseClmovPos accepts a frame number or a duration such as "1h2m" which
+// is converted at the movie frame rate.
+func parseClmovPos(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad frame or time %q", s)
+	}
+	return int(d.Seconds() * float64(clMovFPS)), nil
+}
+
+// parseClmovRange parses "start-end", "start-" or "-end". A single
+// position selects just that frame.
+func parseClmovRange(s string) (clmovRange, error) {
+	a, b, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		n, err := parseClmovPos(a)
+		return clmovRange{n, n + 1}, err
+	}
+	r := clmovRange{end: -1}
+	var err error
+	if strings.TrimSpace(a) != "" {
+		if r.start, err = parseClmovPos(a); err != nil {
+			return r, err
+		}
+	}
+	if strings.TrimSpace(b) != "" {
+		if r.end, err = parseClmovPos(b); err != nil {
+			return r, err
+		}
+		if r.end <= r.start {
+			return r, fmt.Errorf("empty range %q", s)
+		}
+	}
+	return r, nil
+}
+
+// clmovKeep builds the frame filter for an edit. trim keeps only one range
+// and drop is a comma separated list of ranges to remove; both index the
+// joined movie.
+func clmovKeep(trim, drop string) (func(int) bool, error) {
+	keep := clmovRange{end: -1}
+	if trim != "" {
+		r, err := parseClmovRange(trim)
+		if err != nil {
+			return nil, err
+		}
+		keep = r
+	}
+	var drops []clmovRange
+	for _, s := range strings.Split(drop, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		r, err := parseClmovRange(s)
+		if err != nil {
+			return nil, err
+		}
+		drops = append(drops, r)
+	}
+	return func(i int) bool {
+		if !keep.contains(i) {
+			return false
+		}
+		for _, r := range drops {
+			if r.contains(i) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// editClmov joins inputs, keeps the frames accepted by keep and writes the
+// result to outPath. Frames are renumbered from zero. Wherever frames were
+// cut, the draw state at that point is written as MobileData and
+// PictureTable blocks, together with the input's last GameState block, so
+// the following draw states still decode. It returns the frame count.
+func editClmov(outPath string, inputs []string, keep func(int) bool) (int, error) {
+	seekingMov = true
+	blockSound, blockBubbles, blockTTS, blockMusic = true, true, true, true
+	defer func() {
+		seekingMov = false
+		blockSound, blockBubbles, blockTTS, blockMusic = false, false, false, false
+	}()
+	drawStateEncrypted = false
+
+	var head []byte
+	var out bytes.Buffer
+	n, offset := 0, 0
+	for _, path := range inputs {
+		data, err := loadMovieData(path)
+		if err != nil {
+			return 0, err
+		}
+		frames, err := parseMovieData(data, clVersion)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		if head == nil {
+			headerLen := int(binary.BigEndian.Uint16(data[6:8]))
+			if headerLen < 24 || headerLen > len(data) {
+				headerLen = 24
+			}
+			head = append([]byte(nil), data[:headerLen]...)
+		} else if v, w := binary.BigEndian.Uint16(data[4:6]), binary.BigEndian.Uint16(head[4:6]); v != w {
+			return 0, fmt.Errorf("%s: movie version %d does not match %d", path, v, w)
+		}
+
+		stateMu.Lock()
+		state = cloneDrawState(initialState)
+		stateMu.Unlock()
+		var gameState []byte
+		first, skipped := true, false
+		for i, fr := range frames {
+			if fr.flags&flagGameState != 0 && len(fr.preData) >= 24 {
+				end := 24 + int(binary.BigEndian.Uint32(fr.preData[12:16]))
+				if end <= len(fr.preData) {
+					gameState = fr.preData[:end]
+				}
+			}
+			if !keep(offset + i) {
+				skipped = true
+				clmovReplay(fr)
+				continue
+			}
+			if skipped || (first && i > 0) {
+				if first && gameState != nil {
+					writeClmovFrame(&out, n, flagGameState, gameState, nil)
+					n++
+				}
+				n = writeClmovState(&out, n)
+			}
+			first, skipped = false, false
+			writeClmovFrame(&out, n, fr.flags, fr.preData, fr.data)
+			n++
+			clmovReplay(fr)
+		}
+		offset += len(frames)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no frames left")
+	}
+	binary.BigEndian.PutUint32(head[8:12], uint32(n))
+	if err := os.WriteFile(outPath, append(head, out.Bytes()...), 0o644); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// clmovReplay advances the draw state over one frame the way seeking does.
+func clmovReplay(m movieFrame) {
+	applyMovieBlocks(m)
+	if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
+		handleDrawState(m.data, false)
+	}
+}
+
+// writeClmovState writes the current descriptors and pictures as block
+// frames starting at frame n and returns the next frame number.
+func writeClmovState(out *bytes.Buffer, n int) int {
+	stateMu.Lock()
+	mobiles := encodeMobileTable(state.descriptors, movieVersion)
+	pictures := encodePictureTable(state.pictures)
+	stateMu.Unlock()
+	if mobiles != nil {
+		writeClmovFrame(out, n, flagMobileData, mobiles, nil)
+		n++
+	}
+	writeClmovFrame(out, n, flagPictureTable, pictures, nil)
+	return n + 1
+}
+
+// writeClmovFrame writes a frame in the layout checked by verifyClmov.
+func writeClmovFrame(out *bytes.Buffer, n int, flags uint16, preData, data []byte) {
+	h := make([]byte, 12)
+	binary.BigEndian.PutUint32(h[0:], movieSignature)
+	binary.BigEndian.PutUint32(h[4:], uint32(n))
+	binary.BigEndian.PutUint16(h[8:], uint16(len(data)))
+	binary.BigEndian.PutUint16(h[10:], flags)
+	out.Write(h)
+	out.Write(preData)
+	out.Write(data)
+}
+
+// encodeMobileTable writes descriptors as a MobileData block that
+// parseMobileTable reads back. Mobiles are left out; every draw state
+// carries them.
+func encodeMobileTable(descs map[uint8]frameDescriptor, version uint16) []byte {
+	l, ok := mobileTableLayout(version)
+	if !ok {
+		return nil
+	}
+	idxs := make([]int, 0, len(descs))
+	for i := range descs {
+		idxs = append(idxs, int(i))
+	}
+	sort.Ints(idxs)
+	var buf []byte
+	for _, i := range idxs {
+		d := descs[uint8(i)]
+		rec := make([]byte, 4+l.descSize)
+		binary.BigEndian.PutUint32(rec[0:], uint32(i+descTableSize))
+		desc := rec[4:]
+		binary.BigEndian.PutUint32(desc[0:], uint32(d.PictID))
+		binary.BigEndian.PutUint32(desc[16:], uint32(d.Type))
+		colors := d.Colors
+		if len(colors) > 30 {
+			colors = colors[:30]
+		}
+		binary.BigEndian.PutUint32(desc[l.numColorsOffset:], uint32(len(colors)))
+		copy(desc[l.colorsOffset:], colors)
+		name := d.Name
+		if len(name) > 47 {
+			name = name[:47]
+		}
+		copy(desc[l.nameOffset:], name)
+		buf = append(buf, rec...)
+	}
+	return append(buf, 0xff, 0xff, 0xff, 0xff)
+}
+
+// encodePictureTable writes pictures as a PictureTable block.
+func encodePictureTable(pics []framePicture) []byte {
+	buf := make([]byte, 2+6*len(pics)+4)
+	binary.BigEndian.PutUint16(buf[0:], uint16(len(pics)))
+	pos := 2
+	for _, p := range pics {
+		binary.BigEndian.PutUint16(buf[pos:], p.PictID)
+		binary.BigEndian.PutUint16(buf[pos+2:], uint16(p.H))
+		binary.BigEndian.PutUint16(buf[pos+4:], uint16(p.V))
+		pos += 6
+	}
+	return buf
+}
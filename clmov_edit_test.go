@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func writeEditTestMovie(t *testing.T, path string) {
+	t.Helper()
+	mr, err := newMovieRecorder(path, 1440, 1)
+	if err != nil {
+		t.Fatalf("newMovieRecorder: %v", err)
+	}
+	descs := map[uint8]frameDescriptor{3: {Index: 3, PictID: 447, Name: "Alice", Colors: []byte{1, 2}}}
+	if err := mr.WriteBlock(encodeMobileTable(descs, 1440), flagMobileData); err != nil {
+		t.Fatalf("WriteBlock: %v", err)
+	}
+	pics := []framePicture{{PictID: 10, H: 1, V: 2}}
+	if err := mr.WriteBlock(encodePictureTable(pics), flagPictureTable); err != nil {
+		t.Fatalf("WriteBlock: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			pics = []framePicture{{PictID: 20, H: -3, V: 4}, {PictID: 21}}
+			if err := mr.WriteBlock(encodePictureTable(pics), flagPictureTable); err != nil {
+				t.Fatalf("WriteBlock: %v", err)
+			}
+		}
+		if err := mr.WriteFrame([]byte{0, 9, byte(i)}, 0); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestClmovEdit(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.clMov")
+	writeEditTestMovie(t, a)
+
+	// Frames: 0-1 login blocks, 2-6 data, 7 pictures, 8-12 data.
+	out := filepath.Join(dir, "trim.clMov")
+	keep, err := clmovKeep("9-", "11")
+	if err != nil {
+		t.Fatalf("clmovKeep: %v", err)
+	}
+	n, err := editClmov(out, []string{a}, keep)
+	if err != nil {
+		t.Fatalf("editClmov: %v", err)
+	}
+	if err := verifyClmov(out, 0); err != nil {
+		t.Fatalf("verifyClmov: %v", err)
+	}
+	frames, err := parseMovie(out, 0)
+	if err != nil {
+		t.Fatalf("parseMovie: %v", err)
+	}
+	// Cut blocks, 9, 10, cut blocks, 12.
+	if n != 7 || len(frames) != 7 {
+		t.Fatalf("got %d/%d frames", n, len(frames))
+	}
+	for i, fr := range frames {
+		if fr.index != int32(i) {
+			t.Fatalf("frame %d numbered %d", i, fr.index)
+		}
+	}
+	if frames[2].data[2] != 6 || frames[6].data[2] != 9 {
+		t.Fatalf("wrong frames kept: %v %v", frames[2].data, frames[6].data)
+	}
+	d := initialState.descriptors[3]
+	if d.Name != "Alice" || d.PictID != 447 || len(d.Colors) != 2 {
+		t.Fatalf("descriptor %+v", d)
+	}
+	if p := initialState.pictures; len(p) != 2 || p[0].PictID != 20 || p[0].H != -3 {
+		t.Fatalf("pictures %+v", p)
+	}
+
+	joined := filepath.Join(dir, "join.clMov")
+	keep, _ = clmovKeep("", "0-5")
+	if n, err = editClmov(joined, []string{a, a}, keep); err != nil {
+		t.Fatalf("editClmov: %v", err)
+	}
+	if err := verifyClmov(joined, 0); err != nil {
+		t.Fatalf("verifyClmov: %v", err)
+	}
+	if n != 2+8+13 {
+		t.Fatalf("joined %d frames", n)
+	}
+}
+
+func TestParseClmovRange(t *testing.T) {
+	r, err := parseClmovRange("1m-1m30s")
+	if err != nil || r != (clmovRange{300, 450}) {
+		t.Fatalf("got %v %v", r, err)
+	}
+	if _, err := parseClmovRange("20-10"); err == nil {
+		t.Fatalf("empty range accepted")
+	}
+}
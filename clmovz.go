@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A .clMovZ file wraps the classic frame stream in deflate chunks:
+//
+//	"CLMZ" | format u16 | codec u16 | meta len u32 | meta JSON
+//	header len u16 | classic file header
+//	chunks of checkpointInterval frames, each a deflate stream
+//	index (deflate JSON) | index offset u64 | index len u32 | "CLMZ"
+//
+// The chunks hold the classic frames byte for byte, so exporting back to
+// .clMov is lossless. The index lists the chunks and, optionally, draw
+// state keyframes at each chunk start so seeking needs no replay.
+
+const (
+	clmovzMagic   = "CLMZ"
+	clmovzFormat  = 1
+	clmovzDeflate = 1
+	clmovzTrailer = 16
+)
+
+// clmovMeta describes a recording.
+type clmovMeta struct {
+	Character     string    `json:"character,omitempty"`
+	Server        string    `json:"server,omitempty"`
+	ClientVersion int       `json:"clientVersion,omitempty"`
+	Recorded      time.Time `json:"recorded"`
+	Tags          []string  `json:"tags,omitempty"`
+}
+
+type clmovzChunk struct {
+	First  int   `json:"first"`
+	Count  int   `json:"count"`
+	Offset int64 `json:"offset"`
+	Size   int   `json:"size"`
+}
+
+type clmovzIndex struct {
+	Frames    int             `json:"frames"`
+	Chunks    []clmovzChunk   `json:"chunks"`
+	Keyframes []movieKeyframe `json:"keyframes,omitempty"`
+}
+
+// movieKeyframe is the draw state after Frame frames have played, the
+// same point a movieCheckpoint records.
+type movieKeyframe struct {
+	Frame       int                       `json:"frame"`
+	Descriptors map[uint8]frameDescriptor `json:"descriptors"`
+	Mobiles     map[uint8]frameMobile     `json:"mobiles"`
+	Pictures    []framePicture            `json:"pictures"`
+	Stats       [6]int                    `json:"stats"` // hp, hpMax, sp, spMax, balance, balanceMax
+	Lighting    uint8                     `json:"lighting"`
+}
+
+func newMovieKeyframe(frame int, s drawState) movieKeyframe {
+	c := cloneDrawState(s)
+	return movieKeyframe{
+		Frame:       frame,
+		Descriptors: c.descriptors,
+		Mobiles:     c.mobiles,
+		Pictures:    c.pictures,
+		Stats:       [6]int{s.hp, s.hpMax, s.sp, s.spMax, s.balance, s.balanceMax},
+		Lighting:    s.lightingFlags,
+	}
+}
+
+func (k movieKeyframe) drawState() drawState {
+	s := drawState{
+		descriptors:   make(map[uint8]frameDescriptor, len(k.Descriptors)),
+		mobiles:       make(map[uint8]frameMobile, len(k.Mobiles)),
+		prevMobiles:   make(map[uint8]frameMobile),
+		prevDescs:     make(map[uint8]frameDescriptor),
+		pictures:      append([]framePicture(nil), k.Pictures...),
+		lightingFlags: k.Lighting,
+	}
+	// Planes come from CL_Images, which -clmovZ writes keyframes without,
+	// so the stored ones are not trusted.
+	for i, d := range k.Descriptors {
+		d.Plane = keyframePlane(d.PictID)
+		s.descriptors[i] = d
+	}
+	for i := range s.pictures {
+		s.pictures[i].Plane = keyframePlane(s.pictures[i].PictID)
+	}
+	for i, m := range k.Mobiles {
+		s.mobiles[i] = m
+	}
+	s.hp, s.hpMax, s.sp, s.spMax, s.balance, s.balanceMax = k.Stats[0], k.Stats[1], k.Stats[2], k.Stats[3], k.Stats[4], k.Stats[5]
+	return s
+}
+
+func keyframePlane(id uint16) int {
+	if clImages == nil {
+		return 0
+	}
+	return clImages.Plane(uint32(id))
+}
+
+func isClmovZ(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".clmovz")
+}
+
+// encodeClmovZ packs a classic movie. When keyframes is true the frames are
+// replayed into a private drawState to record draw state keyframes.
+func encodeClmovZ(classic []byte, meta clmovMeta, keyframes bool) ([]byte, error) {
+	if len(classic) < 24 || binary.BigEndian.Uint32(classic[:4]) != movieSignature {
+		return nil, errors.New("not a clMov file")
+	}
+	headerLen := int(binary.BigEndian.Uint16(classic[6:8]))
+	if headerLen < 24 || headerLen > len(classic) {
+		headerLen = 24
+	}
+	version := binary.BigEndian.Uint16(classic[4:6])
+	if version > 50000 {
+		version /= 100
+	}
+	offsets := movieFrameOffsets(classic, headerLen, version)
+
+	var frames []movieFrame
+	var err error
+	var s drawState
+	if keyframes {
+		frames, err = parseMovieData(classic, clVersion)
+		if err != nil {
+			return nil, err
+		}
+		if len(frames) != len(offsets) {
+			return nil, fmt.Errorf("frame count mismatch: %d vs %d", len(frames), len(offsets))
+		}
+		if meta.Character == "" {
+			meta.Character = extractMoviePlayerName(frames)
+		}
+		s = cloneDrawState(initialState)
+	}
+	var out bytes.Buffer
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	out.WriteString(clmovzMagic)
+	binary.Write(&out, binary.BigEndian, uint16(clmovzFormat))
+	binary.Write(&out, binary.BigEndian, uint16(clmovzDeflate))
+	binary.Write(&out, binary.BigEndian, uint32(len(metaJSON)))
+	out.Write(metaJSON)
+	binary.Write(&out, binary.BigEndian, uint16(headerLen))
+	out.Write(classic[:headerLen])
+
+	idx := clmovzIndex{Frames: len(offsets)}
+	// Chunks copy the file bytes between frame starts, including anything
+	// the parser skips, so the classic movie comes back unchanged.
+	start := headerLen
+	var last int32
+	for first := 0; first < len(offsets) || start < len(classic); first += checkpointInterval {
+		end := first + checkpointInterval
+		stop := len(classic)
+		if end < len(offsets) {
+			stop = offsets[end]
+		} else {
+			end = len(offsets)
+		}
+		var comp bytes.Buffer
+		zw, _ := flate.NewWriter(&comp, flate.BestCompression)
+		zw.Write(classic[start:stop])
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		idx.Chunks = append(idx.Chunks, clmovzChunk{First: first, Count: end - first, Offset: int64(out.Len()), Size: comp.Len()})
+		out.Write(comp.Bytes())
+		start = stop
+		if keyframes {
+			for i, m := range frames[first:end] {
+				dropped := 0
+				if last != 0 && m.index > last+1 {
+					dropped = int(m.index - last - 1)
+				}
+				if last == 0 || m.index > last {
+					last = m.index
+				}
+				applyMovieBlocksTo(&s, m)
+				if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
+					if err := parseDrawStateInto(&s, m.data[2:], dropped); err != nil {
+						logDebug("clMovZ frame %d: %v", first+i, err)
+					}
+				}
+			}
+			if end < len(frames) {
+				idx.Keyframes = append(idx.Keyframes, newMovieKeyframe(end, s))
+			}
+		}
+	}
+
+	idxJSON, err := json.Marshal(idx)
+	if err != nil {
+		return nil, err
+	}
+	var comp bytes.Buffer
+	zw, _ := flate.NewWriter(&comp, flate.BestCompression)
+	zw.Write(idxJSON)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	off := out.Len()
+	out.Write(comp.Bytes())
+	binary.Write(&out, binary.BigEndian, uint64(off))
+	binary.Write(&out, binary.BigEndian, uint32(comp.Len()))
+	out.WriteString(clmovzMagic)
+	return out.Bytes(), nil
+}
+
+// movieFrameOffsets returns where each frame starts, walking the file the
+// same way parseMovieData does but without applying any blocks.
+func movieFrameOffsets(data []byte, headerLen int, version uint16) []int {
+	var offsets []int
+	pos := headerLen
+	sign := []byte{0xde, 0xad, 0xbe, 0xef}
+	for pos+12 <= len(data) {
+		if binary.BigEndian.Uint32(data[pos:pos+4]) != movieSignature {
+			idx := bytes.Index(data[pos:], sign)
+			if idx < 0 {
+				break
+			}
+			pos += idx
+			continue
+		}
+		start := pos
+		size := int(binary.BigEndian.Uint16(data[pos+8 : pos+10]))
+		flags := binary.BigEndian.Uint16(data[pos+10 : pos+12])
+		pos += 12
+		if flags&flagGameState != 0 && pos+24 <= len(data) {
+			maxSize := int(binary.BigEndian.Uint32(data[pos+12 : pos+16]))
+			if end := pos + 24 + maxSize; maxSize >= 0 && end <= len(data) {
+				pos = end
+			}
+		}
+		if flags&flagMobileData != 0 && bytes.Contains(data[pos:], []byte{0xff, 0xff, 0xff, 0xff}) {
+			_, _, pos = decodeMobileTable(data, pos, version)
+		}
+		if flags&flagPictureTable != 0 {
+			if _, next, ok := parsePictureTable(data, pos); ok {
+				pos = next
+			}
+		}
+		if size > 0 {
+			if pos+size > len(data) {
+				break
+			}
+			offsets = append(offsets, start)
+			pos += size
+		} else {
+			offsets = append(offsets, start)
+			idx := bytes.Index(data[pos:], sign)
+			if idx < 0 {
+				break
+			}
+			pos += idx
+		}
+	}
+	return offsets
+}
+
+// readClmovZHead returns the metadata and the classic file header.
+func readClmovZHead(data []byte) (clmovMeta, []byte, error) {
+	var meta clmovMeta
+	if len(data) < 12+clmovzTrailer || string(data[:4]) != clmovzMagic {
+		return meta, nil, errors.New("not a clMovZ file")
+	}
+	if f := binary.BigEndian.Uint16(data[4:6]); f != clmovzFormat {
+		return meta, nil, fmt.Errorf("unsupported clMovZ format %d", f)
+	}
+	if c := binary.BigEndian.Uint16(data[6:8]); c != clmovzDeflate {
+		return meta, nil, fmt.Errorf("unsupported clMovZ codec %d", c)
+	}
+	metaLen := int(binary.BigEndian.Uint32(data[8:12]))
+	pos := 12 + metaLen
+	if pos+2 > len(data) {
+		return meta, nil, errors.New("truncated clMovZ header")
+	}
+	if err := json.Unmarshal(data[12:pos], &meta); err != nil {
+		return meta, nil, err
+	}
+	headLen := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	pos += 2
+	if pos+headLen > len(data) {
+		return meta, nil, errors.New("truncated clMovZ header")
+	}
+	return meta, data[pos : pos+headLen], nil
+}
+
+// readClmovZIndex decodes the index found through the trailer.
+func readClmovZIndex(data []byte) (clmovzIndex, error) {
+	var idx clmovzIndex
+	if len(data) < clmovzTrailer || string(data[len(data)-4:]) != clmovzMagic {
+		return idx, errors.New("clMovZ trailer missing")
+	}
+	t := data[len(data)-clmovzTrailer:]
+	off := binary.BigEndian.Uint64(t[0:8])
+	n := uint64(binary.BigEndian.Uint32(t[8:12]))
+	if off+n > uint64(len(data)-clmovzTrailer) {
+		return idx, errors.New("bad clMovZ index")
+	}
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(data[off : off+n])))
+	if err != nil {
+		return idx, err
+	}
+	err = json.Unmarshal(raw, &idx)
+	return idx, err
+}
+
+// decodeClmovZ returns the classic movie bytes stored in a container.
+func decodeClmovZ(data []byte) ([]byte, clmovMeta, clmovzIndex, error) {
+	meta, head, err := readClmovZHead(data)
+	if err != nil {
+		return nil, meta, clmovzIndex{}, err
+	}
+	idx, err := readClmovZIndex(data)
+	if err != nil {
+		return nil, meta, idx, err
+	}
+	out := append([]byte(nil), head...)
+	for _, c := range idx.Chunks {
+		if c.Offset < 0 || c.Offset+int64(c.Size) > int64(len(data)) {
+			return nil, meta, idx, errors.New("bad clMovZ chunk")
+		}
+		raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(data[c.Offset : c.Offset+int64(c.Size)])))
+		if err != nil {
+			return nil, meta, idx, fmt.Errorf("chunk at frame %d: %w", c.First, err)
+		}
+		out = append(out, raw...)
+	}
+	return out, meta, idx, nil
+}
+
+// loadMovieKeyframes returns the keyframes stored in a .clMovZ file, or
+// nil for other movies.
+func loadMovieKeyframes(path string) []movieKeyframe {
+	if !isClmovZ(path) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	idx, err := readClmovZIndex(data)
+	if err != nil {
+		logError("clMovZ index: %v", err)
+		return nil
+	}
+	return idx.Keyframes
+}
+
+// convertToClmovZ writes src (a .clMov, .zip or .clMovZ) as a container at
+// dst. Metadata from a .clMovZ source is kept and tags are added to it.
+func convertToClmovZ(src, dst string, meta clmovMeta, keyframes bool) error {
+	if isClmovZ(src) {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		old, _, err := readClmovZHead(data)
+		if err != nil {
+			return err
+		}
+		meta.Tags = append(old.Tags, meta.Tags...)
+		old.Tags = meta.Tags
+		meta = old
+	}
+	classic, err := loadMovieData(src)
+	if err != nil {
+		return err
+	}
+	if meta.Recorded.IsZero() && len(classic) >= 16 {
+		secs := int64(binary.BigEndian.Uint32(classic[12:16])) - macEpochDelta
+		meta.Recorded = time.Unix(secs, 0).UTC()
+	}
+	if meta.ClientVersion == 0 && len(classic) >= 6 {
+		meta.ClientVersion = int(binary.BigEndian.Uint16(classic[4:6]))
+	}
+	data, err := encodeClmovZ(classic, meta, keyframes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+// exportClmovZ writes the classic movie held in a .clMovZ file to dst.
+func exportClmovZ(src, dst string) error {
+	classic, err := loadMovieData(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, classic, 0644)
+}
+
+// parseTags splits a comma separated tag list.
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a .clMovZ container keeps the classic stream byte for byte
+// and carries its metadata and keyframes.
+func TestClmovZRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.clMov")
+	writeEditTestMovie(t, src)
+	orig, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	dst := filepath.Join(dir, "a.clMovZ")
+	meta := clmovMeta{Character: "Alice", Server: "example:5010", Tags: parseTags(" hunt, ,pvp")}
+	if err := convertToClmovZ(src, dst, meta, true); err != nil {
+		t.Fatalf("convertToClmovZ: %v", err)
+	}
+	if !isClmovZ(dst) || isClmovZ(src) {
+		t.Fatalf("isClmovZ mismatch")
+	}
+	got, err := loadMovieData(dst)
+	if err != nil {
+		t.Fatalf("loadMovieData: %v", err)
+	}
+	if !bytes.Equal(got, orig) {
+		t.Fatalf("classic stream changed: %d vs %d bytes", len(got), len(orig))
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	m, _, err := readClmovZHead(data)
+	if err != nil {
+		t.Fatalf("readClmovZHead: %v", err)
+	}
+	if m.Character != "Alice" || m.Server != "example:5010" || m.ClientVersion != 1440 ||
+		len(m.Tags) != 2 || m.Tags[0] != "hunt" || m.Tags[1] != "pvp" || m.Recorded.IsZero() {
+		t.Fatalf("meta %+v", m)
+	}
+	kfs := loadMovieKeyframes(dst)
+	if len(kfs) == 0 {
+		t.Fatalf("no keyframes")
+	}
+	if d := kfs[len(kfs)-1].drawState().descriptors[3]; d.Name != "Alice" || d.PictID != 447 {
+		t.Fatalf("keyframe descriptor %+v", d)
+	}
+
+	// Re-wrapping keeps the metadata and appends tags.
+	again := filepath.Join(dir, "b.clMovZ")
+	if err := convertToClmovZ(dst, again, clmovMeta{Tags: []string{"review"}}, false); err != nil {
+		t.Fatalf("convertToClmovZ: %v", err)
+	}
+	data, _ = os.ReadFile(again)
+	if m, _, _ := readClmovZHead(data); m.Character != "Alice" || len(m.Tags) != 3 {
+		t.Fatalf("rewrapped meta %+v", m)
+	}
+
+	out := filepath.Join(dir, "out.clMov")
+	if err := exportClmovZ(again, out); err != nil {
+		t.Fatalf("exportClmovZ: %v", err)
+	}
+	if exp, _ := os.ReadFile(out); !bytes.Equal(exp, orig) {
+		t.Fatalf("export differs from original")
+	}
+}
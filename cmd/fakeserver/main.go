@@ -0,0 +1,69 @@
+// Command fakeserver runs a local stand-in Clan Lord server so the client's
+// login, network loops and reconnect handling can be exercised without the
+// real game. Point the client at it with the server address setting, e.g.
+// 127.0.0.1:5010.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"gothoom/internal/fakeserver"
+)
+
+func main() {
+	var (
+		addr     = flag.String("addr", "127.0.0.1:5010", "TCP and UDP listen address")
+		password = flag.String("password", "", "only accept this character password (default any)")
+		version  = flag.Int("version", 1440, "client version announced to clients")
+		clmov    = flag.String("clmov", "", "serve the draw states of this .clMov instead of generated frames")
+		fps      = flag.Int("fps", 5, "draw states per second")
+		quiet    = flag.Bool("quiet", false, "don't log logins, input and commands")
+	)
+	flag.Parse()
+
+	cfg := fakeserver.Config{
+		Addr:     *addr,
+		Password: *password,
+		Version:  *version,
+		Logf:     log.Printf,
+	}
+	if *fps > 0 {
+		cfg.FrameInterval = time.Second / time.Duration(*fps)
+	}
+	if *quiet {
+		cfg.Logf = nil
+	}
+	if *clmov != "" {
+		frames, err := fakeserver.LoadMovieFrames(*clmov)
+		if err != nil {
+			log.Fatalf("load %s: %v", *clmov, err)
+		}
+		cfg.Frames = frames
+		log.Printf("serving %d frames from %s", len(frames), *clmov)
+	}
+
+	srv, err := fakeserver.Listen(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if !*quiet {
+		go func() {
+			for in := range srv.Inputs() {
+				if in.Command != "" {
+					log.Printf("%s: %q", in.Name, in.Command)
+				}
+			}
+		}()
+	}
+	log.Printf("listening on %s", srv.Addr())
+	if err := srv.Serve(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -135,15 +135,7 @@ func decodeBEPP(data []byte) string {
 	if len(data) < 3 || data[0] != 0xC2 {
 		return ""
 	}
-	prefix := string(data[1:3])
-	// Keep a raw copy (without NUL terminator) for backend parsing.
-	raw := data[3:]
-	if i := bytes.IndexByte(raw, 0); i >= 0 {
-		raw = raw[:i]
-	}
-	// For displayable text, strip BEPP tags and non-printables.
-	cleaned := stripBEPPTags(append([]byte(nil), raw...))
-	text := strings.TrimSpace(decodeMacRoman(cleaned))
+	prefix, raw, text := splitBEPP(data)
 
 	if dumpBEPPTags {
 		// Log the tag and a truncated form of the text for empirical analysis.
@@ -223,6 +215,21 @@ func decodeBEPP(data []byte) string {
 	return ""
 }
 
+// splitBEPP splits a BEPP line into its two-letter prefix, the raw body up
+// to the NUL terminator (for backend parsing) and the displayable text with
+// tags and non-printables stripped. data must start with 0xC2 and be at
+// least three bytes long.
+func splitBEPP(data []byte) (prefix string, raw []byte, text string) {
+	prefix = string(data[1:3])
+	raw = data[3:]
+	if i := bytes.IndexByte(raw, 0); i >= 0 {
+		raw = raw[:i]
+	}
+	cleaned := stripBEPPTags(append([]byte(nil), raw...))
+	text = strings.TrimSpace(decodeMacRoman(cleaned))
+	return prefix, raw, text
+}
+
 func stripBEPPTags(b []byte) []byte {
 	out := b[:0]
 	for i := 0; i < len(b); {
@@ -428,6 +435,7 @@ func handleInfoText(data []byte) {
 		if line[0] == 0xC2 {
 			if txt := decodeBEPP(line); txt != "" {
 				consoleMessage(txt)
+				autoRecordText(txt)
 			}
 			continue
 		}
@@ -467,5 +475,6 @@ func handleInfoText(data []byte) {
 			continue
 		}
 		consoleMessage(s)
+		autoRecordText(s)
 	}
 }
@@ -133,11 +133,10 @@ var pictureShiftScratchPool = sync.Pool{
 }
 
 type drawParseScratch struct {
-	descriptors   []frameDescriptor
-	pictures      []framePicture
-	mobiles       []frameMobile
-	bubbles       []bubble
-	mobilePresent map[uint8]struct{}
+	descriptors []frameDescriptor
+	pictures    []framePicture
+	mobiles     []frameMobile
+	bubbles     []bubble
 }
 
 func clearMap[K comparable, V any](m map[K]V) {
@@ -147,9 +146,7 @@ func clearMap[K comparable, V any](m map[K]V) {
 }
 
 func newDrawParseScratch() *drawParseScratch {
-	return &drawParseScratch{
-		mobilePresent: make(map[uint8]struct{}),
-	}
+	return &drawParseScratch{}
 }
 
 func (s *drawParseScratch) reset() {
@@ -157,11 +154,6 @@ func (s *drawParseScratch) reset() {
 	s.pictures = s.pictures[:0]
 	s.mobiles = s.mobiles[:0]
 	s.bubbles = s.bubbles[:0]
-	if s.mobilePresent == nil {
-		s.mobilePresent = make(map[uint8]struct{})
-	} else {
-		clearMap(s.mobilePresent)
-	}
 }
 
 var drawParseScratchPool = sync.Pool{
@@ -790,6 +782,7 @@ func handleDrawState(m []byte, buildCache bool) {
 	}
 	ackFrame = ack
 	resendFrame = resend
+	runFrameHandlers(ack)
 }
 
 // handleInvCmdFull resets and rebuilds the inventory from a full list command.
@@ -965,130 +958,120 @@ func parseInventory(data []byte) ([]byte, bool) {
 	return data, true
 }
 
-// parseDrawState decodes the draw state data. It returns an error when the
-// packet appears malformed, indicating the parsing stage that failed.
-//
-// When buildCache is false, state is updated without rebuilding the render
-// cache.
-func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
-	stage := "header"
-	if len(data) < 9 {
-		return 0, 0, errors.New(stage)
-	}
+// drawStateMsg is a draw state message decoded as far as its state data,
+// before it is applied to a drawState. Names are as sent.
+type drawStateMsg struct {
+	ackCmd      byte
+	ack, resend int32
+	// sections counts the sections decoded, so a message that fails to
+	// decode can still be shown up to the failure.
+	sections int
 
-	ackCmd := data[0]
-	ack := int32(binary.BigEndian.Uint32(data[1:5]))
-	resend := int32(binary.BigEndian.Uint32(data[5:9]))
-	dropped := 0
-	if movieMode {
-		dropped = movieDropped
-	} else {
-		dropped = updateFrameCounters(ack)
-	}
-	extra := dropped
-	if extra > 2 {
-		extra = 2
-	}
-	p := 9
+	descCount int
+	descs     []frameDescriptor
 
-	scratch := acquireDrawParseScratch()
-	descs := scratch.descriptors[:0]
-	pics := scratch.pictures[:0]
-	mobiles := scratch.mobiles[:0]
-	bubbles := scratch.bubbles[:0]
-	present := scratch.mobilePresent
-	if present == nil {
-		present = make(map[uint8]struct{})
-		scratch.mobilePresent = present
-	} else {
-		clearMap(present)
+	hp, hpMax, sp, spMax, bal, balMax int
+	lighting                          byte
+
+	pictAgain, pictCount int
+	pics                 []framePicture
+
+	mobileCount int
+	mobiles     []frameMobile
+
+	// stateData holds the info strings, bubbles, sounds and inventory.
+	stateData []byte
+}
+
+// Sections of a draw state message, in order.
+const (
+	drawSectionHeader = iota + 1
+	drawSectionDescriptors
+	drawSectionStats
+	drawSectionPictures
+	drawSectionMobiles
+	drawSectionStateData
+)
+
+var errDrawStateHeader = errors.New("header")
+
+// decodeDrawState decodes a decrypted draw state message into msg, reusing
+// its slices. It changes nothing else. The error names the stage that
+// failed; msg then holds what was decoded before it.
+func decodeDrawState(msg *drawStateMsg, data []byte) error {
+	*msg = drawStateMsg{descs: msg.descs[:0], pics: msg.pics[:0], mobiles: msg.mobiles[:0]}
+	if len(data) < 9 {
+		return errDrawStateHeader
 	}
-	defer func() {
-		scratch.descriptors = descs[:0]
-		scratch.pictures = pics[:0]
-		scratch.mobiles = mobiles[:0]
-		scratch.bubbles = bubbles[:0]
-		clearMap(present)
-		releaseDrawParseScratch(scratch)
-	}()
+	msg.ackCmd = data[0]
+	msg.ack = int32(binary.BigEndian.Uint32(data[1:5]))
+	msg.resend = int32(binary.BigEndian.Uint32(data[5:9]))
+	msg.sections = drawSectionHeader
+	p := 9
 
-	stage = "descriptor count"
+	stage := "descriptor count"
 	if len(data) <= p {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
 	descCount := int(data[p])
 	p++
 	if descCount > maxDescriptors {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
+	msg.descCount = descCount
 	stage = "descriptor"
-	if descCount > cap(descs) {
-		descs = make([]frameDescriptor, 0, descCount)
+	if descCount > cap(msg.descs) {
+		msg.descs = make([]frameDescriptor, 0, descCount)
 	}
 	for i := 0; i < descCount && p < len(data); i++ {
 		if p+4 > len(data) {
-			return ack, resend, errors.New(stage)
+			return errors.New(stage)
 		}
 		d := frameDescriptor{}
 		d.Index = data[p]
 		d.Type = data[p+1]
 		d.PictID = binary.BigEndian.Uint16(data[p+2:])
 		p += 4
-		if idx := bytes.IndexByte(data[p:], 0); idx >= 0 {
-			d.Name = utfFold(decodeMacRoman(data[p : p+idx]))
-			p += idx + 1
-			if d.Name == playerName {
-				playerIndex = d.Index
-			}
-			if wasmPrivacyActive() {
-				d.Name = ""
-			}
-		} else {
-			return ack, resend, errors.New(stage)
+		idx := bytes.IndexByte(data[p:], 0)
+		if idx < 0 {
+			return errors.New(stage)
 		}
+		d.Name = utfFold(decodeMacRoman(data[p : p+idx]))
+		p += idx + 1
 		if p >= len(data) {
-			return ack, resend, errors.New(stage)
+			return errors.New(stage)
 		}
 		cnt := int(data[p])
 		p++
 		if p+cnt > len(data) {
-			return ack, resend, errors.New(stage)
+			return errors.New(stage)
 		}
 		d.Colors = append([]byte(nil), data[p:p+cnt]...)
 		p += cnt
 		if clImages != nil {
 			d.Plane = clImages.Plane(uint32(d.PictID))
 		}
-		// Skip NPCs entirely for player list scanning. Only update
-		// appearance and queue info requests when not in movie mode to
-		// avoid side effects during playback.
-		if d.Type != kDescNPC && d.Name != "" {
-			if !movieMode {
-				updatePlayerAppearance(d.Name, d.PictID, d.Colors, false)
-				// Opportunistically request full info for visible players.
-				queueInfoRequest(d.Name)
-			}
-		}
-		descs = append(descs, d)
+		msg.descs = append(msg.descs, d)
 	}
+	msg.sections = drawSectionDescriptors
 
 	stage = "stats"
 	if len(data) < p+7 {
-		return ack, resend, errors.New(stage)
-	}
-	hp := int(data[p])
-	hpMax := int(data[p+1])
-	sp := int(data[p+2])
-	spMax := int(data[p+3])
-	bal := int(data[p+4])
-	balMax := int(data[p+5])
-	lighting := data[p+6]
-	gNight.SetFlags(uint(lighting))
+		return errors.New(stage)
+	}
+	msg.hp = int(data[p])
+	msg.hpMax = int(data[p+1])
+	msg.sp = int(data[p+2])
+	msg.spMax = int(data[p+3])
+	msg.bal = int(data[p+4])
+	msg.balMax = int(data[p+5])
+	msg.lighting = data[p+6]
 	p += 7
+	msg.sections = drawSectionStats
 
 	stage = "picture count"
 	if len(data) <= p {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
 	pictCount := int(data[p])
 	p++
@@ -1096,7 +1079,7 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 	stage = "picture header"
 	if pictCount == 255 {
 		if len(data) < p+2 {
-			return ack, resend, errors.New(stage)
+			return errors.New(stage)
 		}
 		pictAgain = int(data[p])
 		pictCount = int(data[p+1])
@@ -1104,129 +1087,218 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 	}
 	stage = "picture count"
 	if pictAgain+pictCount > maxPictures {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
+	msg.pictAgain, msg.pictCount = pictAgain, pictCount
 
-	stage = "pictures"
-	totalPics := pictAgain + pictCount
-	if totalPics > cap(pics) {
-		pics = make([]framePicture, 0, totalPics)
+	if pictCount > cap(msg.pics) {
+		msg.pics = make([]framePicture, 0, pictCount)
 	}
 	br := bitReader{data: data[p:]}
 	for i := 0; i < pictCount; i++ {
-		idBits, ok := br.readBits(14)
-		if !ok {
-			return ack, resend, errors.New("truncated picture bit stream")
-		}
-		hBits, ok := br.readBits(11)
-		if !ok {
-			return ack, resend, errors.New("truncated picture bit stream")
-		}
-		vBits, ok := br.readBits(11)
-		if !ok {
-			return ack, resend, errors.New("truncated picture bit stream")
+		idBits, ok1 := br.readBits(14)
+		hBits, ok2 := br.readBits(11)
+		vBits, ok3 := br.readBits(11)
+		if !ok1 || !ok2 || !ok3 {
+			return errors.New("truncated picture bit stream")
 		}
 		id := uint16(idBits)
-		h := signExtend(hBits, 11)
-		v := signExtend(vBits, 11)
 		plane := 0
 		if clImages != nil {
 			plane = clImages.Plane(uint32(id))
 		}
-		pics = append(pics, framePicture{PictID: id, H: h, V: v, Plane: plane})
+		msg.pics = append(msg.pics, framePicture{PictID: id, H: signExtend(hBits, 11), V: signExtend(vBits, 11), Plane: plane})
 	}
 	p += br.bitPos / 8
 	if br.bitPos%8 != 0 {
 		p++
 	}
+	msg.sections = drawSectionPictures
 
 	stage = "mobile count"
 	if len(data) <= p {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
 	mobileCount := int(data[p])
 	p++
 	if mobileCount > maxMobiles {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
+	msg.mobileCount = mobileCount
 	stage = "mobiles"
-	if mobileCount > cap(mobiles) {
-		mobiles = make([]frameMobile, 0, mobileCount)
+	if mobileCount > cap(msg.mobiles) {
+		msg.mobiles = make([]frameMobile, 0, mobileCount)
 	}
 	for i := 0; i < mobileCount && p+7 <= len(data); i++ {
-		m := frameMobile{}
-		m.Index = data[p]
-		m.State = data[p+1]
-		m.H = int16(binary.BigEndian.Uint16(data[p+2:]))
-		m.V = int16(binary.BigEndian.Uint16(data[p+4:]))
-		m.Colors = data[p+6]
+		msg.mobiles = append(msg.mobiles, frameMobile{
+			Index:  data[p],
+			State:  data[p+1],
+			H:      int16(binary.BigEndian.Uint16(data[p+2:])),
+			V:      int16(binary.BigEndian.Uint16(data[p+4:])),
+			Colors: data[p+6],
+		})
 		p += 7
-		mobiles = append(mobiles, m)
 	}
-	if len(mobiles) != mobileCount {
-		return ack, resend, errors.New(stage)
+	if len(msg.mobiles) != mobileCount {
+		return errors.New(stage)
 	}
+	msg.sections = drawSectionMobiles
 
 	stage = "state size"
 	if len(data) < p+2 {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
 	stateLen := int(binary.BigEndian.Uint16(data[p:]))
 	p += 2
 	if len(data) < p+stateLen {
-		return ack, resend, errors.New(stage)
+		return errors.New(stage)
 	}
-	stateData := data[p : p+stateLen]
+	msg.stateData = data[p : p+stateLen]
+	msg.sections = drawSectionStateData
+	return nil
+}
 
-	stateMu.Lock()
-	state.ackCmd = ackCmd
-	state.dropped = extra
-	state.lightingFlags = lighting
-	state.prevHP = state.hp
-	state.prevHPMax = state.hpMax
-	state.prevSP = state.sp
-	state.prevSPMax = state.spMax
-	state.prevBalance = state.balance
-	state.prevBalanceMax = state.balanceMax
-	state.hp = hp
-	state.hpMax = hpMax
-	state.sp = sp
-	state.spMax = spMax
-	state.balance = bal
-	state.balanceMax = balMax
+// drawStateBubble is one bubble from a draw state's state data.
+type drawStateBubble struct {
+	index uint8
+	typ   int
+	h, v  int16 // where a far bubble is
+	// data runs from the index byte through the text's terminating zero,
+	// as decodeBubble reads it.
+	data []byte
+}
+
+// splitDrawStateText splits the info strings and bubbles off the front of
+// a draw state's state data and returns the rest, which holds the sounds
+// and inventory. On error it returns what it split before the failing
+// stage.
+func splitDrawStateText(st []byte) (infos [][]byte, bubbles []drawStateBubble, rest []byte, err error) {
+	// Server sends a zero-terminated info-text blob which may contain
+	// multiple CR-separated lines. Consume the first C string, then
+	// defensively skip any additional stray C strings until what looks
+	// like a valid bubble count (<= maxBubbles) is encountered.
+	stage := "info strings"
+	if len(st) == 0 {
+		return nil, nil, st, errors.New(stage)
+	}
+	idx := bytes.IndexByte(st, 0)
+	if idx < 0 {
+		return nil, nil, st, errors.New(stage)
+	}
+	infos = append(infos, st[:idx])
+	st = st[idx+1:]
+	for len(st) > 0 && int(st[0]) > maxBubbles {
+		// Treat preceding bytes as another info text C string.
+		idx := bytes.IndexByte(st, 0)
+		if idx < 0 {
+			// No terminating zero found; give up.
+			return infos, nil, st, errors.New(stage)
+		}
+		infos = append(infos, st[:idx])
+		st = st[idx+1:]
+	}
+
+	stage = "bubble count"
+	if len(st) == 0 {
+		return infos, nil, st, errors.New(stage)
+	}
+	bubbleCount := int(st[0])
+	st = st[1:]
+	if bubbleCount > maxBubbles {
+		return infos, nil, st, errors.New(stage)
+	}
+	for i := 0; i < bubbleCount && len(st) > 0; i++ {
+		bad := fmt.Errorf("bubble=%d len=%d", i, len(st))
+		if len(st) < 2 {
+			return infos, bubbles, st, bad
+		}
+		b := drawStateBubble{index: st[0], typ: int(st[1])}
+		p := 2
+		if b.typ&kBubbleNotCommon != 0 {
+			if len(st) < p+1 {
+				return infos, bubbles, st, bad
+			}
+			p++
+		}
+		if b.typ&kBubbleFar != 0 {
+			if len(st) < p+4 {
+				return infos, bubbles, st, bad
+			}
+			b.h = int16(binary.BigEndian.Uint16(st[p:]))
+			b.v = int16(binary.BigEndian.Uint16(st[p+2:]))
+			p += 4
+		}
+		if len(st) <= p {
+			return infos, bubbles, st, bad
+		}
+		end := bytes.IndexByte(st[p:], 0)
+		if end < 0 {
+			return infos, bubbles, st, bad
+		}
+		b.data = st[:p+end+1]
+		bubbles = append(bubbles, b)
+		st = st[p+end+1:]
+	}
+	return infos, bubbles, st, nil
+}
+
+// applyDrawState applies a decoded draw state to s. extra is the number of
+// frames dropped before it, at most 2. live is false while seeking and for
+// private states, which are neither interpolated nor animated. images
+// allows loading images, to build name tags and to size mobiles that leave
+// at the edge of the field. It returns the shift of the pictures since the
+// last frame and whether one was found.
+func applyDrawState(s *drawState, msg *drawStateMsg, extra int, live, images bool) (int, int, bool) {
+	pics := msg.pics
+	mobiles := msg.mobiles
+	s.ackCmd = msg.ackCmd
+	s.dropped = extra
+	s.lightingFlags = msg.lighting
+	s.prevHP = s.hp
+	s.prevHPMax = s.hpMax
+	s.prevSP = s.sp
+	s.prevSPMax = s.spMax
+	s.prevBalance = s.balance
+	s.prevBalanceMax = s.balanceMax
+	s.hp = msg.hp
+	s.hpMax = msg.hpMax
+	s.sp = msg.sp
+	s.spMax = msg.spMax
+	s.balance = msg.bal
+	s.balanceMax = msg.balMax
 	changed := false
-	if gs.BlendMobiles && !seekingMov {
-		if len(descs) > 0 {
+	if gs.BlendMobiles && live {
+		if len(msg.descs) > 0 {
 			changed = true
 		}
-		if len(mobiles) != len(state.mobiles) {
+		if len(mobiles) != len(s.mobiles) {
 			changed = true
 		} else {
 			for _, m := range mobiles {
-				if pm, ok := state.mobiles[m.Index]; !ok || pm.State != m.State {
+				if pm, ok := s.mobiles[m.Index]; !ok || pm.State != m.State {
 					changed = true
 					break
 				}
 			}
 		}
 		if changed {
-			if state.prevDescs == nil {
-				state.prevDescs = make(map[uint8]frameDescriptor, len(state.descriptors))
+			if s.prevDescs == nil {
+				s.prevDescs = make(map[uint8]frameDescriptor, len(s.descriptors))
 			} else {
-				clearMap(state.prevDescs)
+				clearMap(s.prevDescs)
 			}
-			for idx, d := range state.descriptors {
-				state.prevDescs[idx] = d
+			for idx, d := range s.descriptors {
+				s.prevDescs[idx] = d
 			}
 		}
 	}
 	// retain previously drawn pictures when the packet specifies pictAgain
-	prevPics := state.pictures
-	again := pictAgain
+	prevPics := s.pictures
+	again := msg.pictAgain
 	if again > len(prevPics) {
 		again = len(prevPics)
 	}
-	newPics := make([]framePicture, again+pictCount)
+	newPics := make([]framePicture, again+len(pics))
 	copy(newPics, prevPics[:again])
 	copy(newPics[again:], pics)
 	for i := 0; i < again && i < len(newPics); i++ {
@@ -1237,7 +1309,7 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 	}
 	maxInterp := maxInterpPixels * (extra + 1)
 	dx, dy, bgIdxs, ok := pictureShift(prevPics, newPics, maxInterp)
-	if gs.MotionSmoothing && !seekingMov {
+	if gs.MotionSmoothing && live {
 		if gs.smoothMoving {
 			logDebug("interp pictures again=%d prev=%d cur=%d shift=(%d,%d) ok=%t", again, len(prevPics), len(newPics), dx, dy, ok)
 			if !ok {
@@ -1246,32 +1318,35 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 			}
 		}
 		if ok {
-			state.picShiftX = dx
-			state.picShiftY = dy
+			s.picShiftX = dx
+			s.picShiftY = dy
 		} else {
-			state.picShiftX = 0
-			state.picShiftY = 0
+			s.picShiftX = 0
+			s.picShiftY = 0
 		}
 	} else {
-		state.picShiftX = 0
-		state.picShiftY = 0
+		s.picShiftX = 0
+		s.picShiftY = 0
 	}
 	if !ok {
 		prevPics = nil
 		again = 0
 		newPics = append([]framePicture(nil), pics...)
-		state.prevDescs = nil
-		state.prevMobiles = nil
-		state.prevPictures = nil
-		state.prevTime = time.Time{}
-		state.curTime = time.Time{}
+		s.prevDescs = nil
+		s.prevMobiles = nil
+		s.prevPictures = nil
+		s.prevTime = time.Time{}
+		s.curTime = time.Time{}
 		logDebug("pictureShift failed; bypassing interpolation")
 	}
-	if state.descriptors == nil {
-		state.descriptors = make(map[uint8]frameDescriptor)
+	if s.descriptors == nil {
+		s.descriptors = make(map[uint8]frameDescriptor)
 	}
-	for _, d := range descs {
-		state.descriptors[d.Index] = d
+	for _, d := range msg.descs {
+		if wasmPrivacyActive() {
+			d.Name = ""
+		}
+		s.descriptors[d.Index] = d
 	}
 	for i := range prevPics {
 		prevPics[i].Owned = false
@@ -1281,8 +1356,8 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 			newPics[i].PrevH = newPics[i].H
 			newPics[i].PrevV = newPics[i].V
 		} else {
-			newPics[i].PrevH = int16(int(newPics[i].H) - state.picShiftX)
-			newPics[i].PrevV = int16(int(newPics[i].V) - state.picShiftY)
+			newPics[i].PrevH = int16(int(newPics[i].H) - s.picShiftX)
+			newPics[i].PrevV = int16(int(newPics[i].V) - s.picShiftY)
 		}
 		moving := true
 		var owner *framePicture
@@ -1296,8 +1371,8 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 					continue
 				}
 				if pp.PictID == newPics[i].PictID &&
-					int(pp.H)+state.picShiftX == int(newPics[i].H) &&
-					int(pp.V)+state.picShiftY == int(newPics[i].V) {
+					int(pp.H)+s.picShiftX == int(newPics[i].H) &&
+					int(pp.V)+s.picShiftY == int(newPics[i].V) {
 					moving = false
 					owner = pp
 					break
@@ -1315,8 +1390,8 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 				if pp.Owned || pp.PictID != newPics[i].PictID {
 					continue
 				}
-				dh := int(newPics[i].H) - int(pp.H) - state.picShiftX
-				dv := int(newPics[i].V) - int(pp.V) - state.picShiftY
+				dh := int(newPics[i].H) - int(pp.H) - s.picShiftX
+				dv := int(newPics[i].V) - int(pp.V) - s.picShiftY
 				dist := dh*dh + dv*dv
 				if dist < bestDist {
 					bestDist = dist
@@ -1350,7 +1425,7 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 	// legitimate ground tiles. Now we carry if the previous sprite was
 	// marked Background and still visible, or fall back to the stricter
 	// edge test for unclassified cases.
-	if (state.picShiftX != 0 || state.picShiftY != 0) && len(prevPics) > 0 {
+	if (s.picShiftX != 0 || s.picShiftY != 0) && len(prevPics) > 0 {
 		for _, pp := range prevPics {
 			if pp.Owned {
 				continue // already matched/present
@@ -1381,8 +1456,8 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 			}
 			oldH, oldV := pp.H, pp.V
 			// Advance by detected picture shift for this frame.
-			pp.H = int16(int(pp.H) + state.picShiftX)
-			pp.V = int16(int(pp.V) + state.picShiftY)
+			pp.H = int16(int(pp.H) + s.picShiftX)
+			pp.V = int16(int(pp.V) + s.picShiftY)
 			pp.PrevH = oldH
 			pp.PrevV = oldV
 			pp.Moving = false
@@ -1393,21 +1468,21 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 	}
 
 	// Save previous pictures for pinning/interpolation decisions
-	state.prevPictures = append([]framePicture(nil), prevPics...)
-	state.pictures = newPics
+	s.prevPictures = append([]framePicture(nil), prevPics...)
+	s.pictures = newPics
 
-	needPrev := (gs.MotionSmoothing || gs.BlendMobiles) && !seekingMov && ok
+	needPrev := (gs.MotionSmoothing || gs.BlendMobiles) && live && ok
 	if needPrev {
-		if state.prevMobiles == nil {
-			state.prevMobiles = make(map[uint8]frameMobile, len(state.mobiles))
+		if s.prevMobiles == nil {
+			s.prevMobiles = make(map[uint8]frameMobile, len(s.mobiles))
 		} else {
-			clearMap(state.prevMobiles)
+			clearMap(s.prevMobiles)
 		}
-		for idx, m := range state.mobiles {
-			state.prevMobiles[idx] = m
+		for idx, m := range s.mobiles {
+			s.prevMobiles[idx] = m
 		}
 	}
-	needAnimUpdate := (gs.MotionSmoothing || (gs.BlendMobiles && changed)) && ok && !seekingMov
+	needAnimUpdate := (gs.MotionSmoothing || (gs.BlendMobiles && changed)) && ok && live
 	if needAnimUpdate {
 		// Use the latest measured server interval; do not reuse the previous
 		// cur-prev duration as that can get stuck until a hard reset (e.g.,
@@ -1419,40 +1494,40 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 			interval = time.Second / 5
 		}
 		interval *= time.Duration(extra + 1)
-		state.prevTime = time.Now()
-		state.curTime = state.prevTime.Add(interval)
+		s.prevTime = time.Now()
+		s.curTime = s.prevTime.Add(interval)
 	}
 
 	// Carry over previous-frame mobiles that disappear at the edge to avoid
 	// premature culling from interpolation.
-	if len(state.mobiles) > 0 {
-		clearMap(present)
+	if len(s.mobiles) > 0 && images {
+		var present [256]bool
 		for _, m := range mobiles {
-			present[m.Index] = struct{}{}
+			present[m.Index] = true
 		}
-		for idx, pm := range state.mobiles {
+		for idx, pm := range s.mobiles {
 			if pm.Persist {
 				continue
 			}
-			if _, ok := present[idx]; ok {
+			if present[idx] {
 				continue
 			}
-			if d, ok := state.descriptors[idx]; ok && mobileOnEdge(pm, d) {
-				pm.H = int16(int(pm.H) + state.picShiftX)
-				pm.V = int16(int(pm.V) + state.picShiftY)
+			if d, ok := s.descriptors[idx]; ok && mobileOnEdge(pm, d) {
+				pm.H = int16(int(pm.H) + s.picShiftX)
+				pm.V = int16(int(pm.V) + s.picShiftY)
 				pm.Persist = true
 				mobiles = append(mobiles, pm)
 			}
 		}
 	}
 
-	if state.mobiles == nil {
-		state.mobiles = make(map[uint8]frameMobile)
+	if s.mobiles == nil {
+		s.mobiles = make(map[uint8]frameMobile)
 	} else {
-		clearMap(state.mobiles)
+		clearMap(s.mobiles)
 	}
 	for _, m := range mobiles {
-		if d, ok := state.descriptors[m.Index]; ok && d.Name != "" {
+		if d, ok := s.descriptors[m.Index]; ok && d.Name != "" && images {
 			style := styleRegular
 			playersMu.RLock()
 			if p, ok := players[d.Name]; ok {
@@ -1472,7 +1547,7 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 				FontGen: fontGen,
 				Style:   style,
 			}
-			if prev, ok := state.mobiles[m.Index]; ok && prev.nameTag != nil && prev.nameTagKey == key {
+			if prev, ok := s.mobiles[m.Index]; ok && prev.nameTag != nil && prev.nameTagKey == key {
 				m.nameTag = prev.nameTag
 				m.nameTagW = prev.nameTagW
 				m.nameTagH = prev.nameTagH
@@ -1494,97 +1569,83 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 				m.nameTagKey = key
 			}
 		}
-		state.mobiles[m.Index] = m
-	}
-	// Populate prevMobiles only when pictureShift succeeds so interpolation of
-	// mobiles and pinned effects is skipped on failure.
-	// Prepare render caches now that state has been updated when requested.
-	if buildCache {
-		prepareRenderCacheLocked()
+		s.mobiles[m.Index] = m
 	}
-	//ack := state.ackCmd
-	//light := state.lightingFlags
-	stateMu.Unlock()
+	return dx, dy, ok
+}
 
-	/*
-	   logDebug("draw state cmd=%d ack=%d resend=%d light=%#x desc=%d pict=%d again=%d mobile=%d state=%d",
-	           ackCmd, ack, resend, light, len(descs), len(pics), pictAgain, len(mobiles), len(stateData))
-	*/
+// parseDrawState decodes the draw state data. It returns an error when the
+// packet appears malformed, indicating the parsing stage that failed.
+//
+// When buildCache is false, state is updated without rebuilding the render
+// cache.
+func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
+	scratch := acquireDrawParseScratch()
+	msg := drawStateMsg{descs: scratch.descriptors, pics: scratch.pictures, mobiles: scratch.mobiles}
+	bubbles := scratch.bubbles[:0]
+	defer func() {
+		scratch.descriptors = msg.descs[:0]
+		scratch.pictures = msg.pics[:0]
+		scratch.mobiles = msg.mobiles[:0]
+		scratch.bubbles = bubbles[:0]
+		releaseDrawParseScratch(scratch)
+	}()
 
-	stage = "info strings"
-	// Server sends a zero-terminated info-text blob which may contain
-	// multiple CR-separated lines. Consume the first C string, then
-	// defensively skip any additional stray C strings until what looks
-	// like a valid bubble count (<= maxBubbles) is encountered.
-	if len(stateData) == 0 {
-		return ack, resend, errors.New(stage)
+	err := decodeDrawState(&msg, data)
+	if err == errDrawStateHeader {
+		return 0, 0, err
 	}
-	if idx := bytes.IndexByte(stateData, 0); idx >= 0 {
-		if idx > 0 {
-			handleInfoText(stateData[:idx])
-		}
-		stateData = stateData[idx+1:]
+	ack, resend := msg.ack, msg.resend
+	dropped := 0
+	if movieMode {
+		dropped = movieDropped
 	} else {
-		return ack, resend, errors.New(stage)
+		dropped = updateFrameCounters(ack)
+		netHealth.dropped(dropped)
 	}
-	for len(stateData) > 0 {
-		if int(stateData[0]) <= maxBubbles {
-			break
+	extra := dropped
+	if extra > 2 {
+		extra = 2
+	}
+	if err != nil {
+		return ack, resend, err
+	}
+
+	for _, d := range msg.descs {
+		if d.Name == playerName {
+			playerIndex = d.Index
 		}
-		// Treat preceding bytes as another info text C string.
-		if idx := bytes.IndexByte(stateData, 0); idx >= 0 {
-			if idx > 0 {
-				handleInfoText(stateData[:idx])
-			}
-			stateData = stateData[idx+1:]
-			continue
+		// Skip NPCs entirely for player list scanning. Only update
+		// appearance and queue info requests when not in movie mode to
+		// avoid side effects during playback.
+		if d.Type != kDescNPC && d.Name != "" && !movieMode && !wasmPrivacyActive() {
+			updatePlayerAppearance(d.Name, d.PictID, d.Colors, false)
+			// Opportunistically request full info for visible players.
+			queueInfoRequest(d.Name)
 		}
-		// No terminating zero found; give up.
-		return ack, resend, errors.New(stage)
 	}
+	gNight.SetFlags(uint(msg.lighting))
 
-	stage = "bubble count"
-	if len(stateData) == 0 {
-		return ack, resend, errors.New(stage)
+	stateMu.Lock()
+	dx, dy, ok := applyDrawState(&state, &msg, extra, !seekingMov, true)
+	if !seekingMov {
+		scriptViewShift(dx, dy, ok)
 	}
-	bubbleCount := int(stateData[0])
-	stateData = stateData[1:]
-	if bubbleCount > maxBubbles {
-		return ack, resend, errors.New(stage)
+	// Prepare render caches now that state has been updated when requested.
+	if buildCache {
+		prepareRenderCacheLocked()
 	}
-	stage = "bubble"
-	for i := 0; i < bubbleCount && len(stateData) > 0; i++ {
-		off := len(data) - len(stateData)
-		if len(stateData) < 2 {
-			return ack, resend, fmt.Errorf("bubble=%d off=%d len=%d", i, off, len(stateData))
-		}
-		idx := stateData[0]
-		typ := int(stateData[1])
-		p := 2
-		if typ&kBubbleNotCommon != 0 {
-			if len(stateData) < p+1 {
-				return ack, resend, fmt.Errorf("bubble=%d off=%d len=%d", i, off, len(stateData))
-			}
-			p++
-		}
-		var h, v int16
-		if typ&kBubbleFar != 0 {
-			if len(stateData) < p+4 {
-				return ack, resend, fmt.Errorf("bubble=%d off=%d len=%d", i, off, len(stateData))
-			}
-			h = int16(binary.BigEndian.Uint16(stateData[p:]))
-			v = int16(binary.BigEndian.Uint16(stateData[p+2:]))
-			p += 4
-		}
-		if len(stateData) <= p {
-			return ack, resend, fmt.Errorf("bubble=%d off=%d len=%d", i, off, len(stateData))
-		}
-		end := bytes.IndexByte(stateData[p:], 0)
-		if end < 0 {
-			return ack, resend, fmt.Errorf("bubble=%d off=%d len=%d", i, off, len(stateData))
+	stateMu.Unlock()
+
+	infos, textBubbles, stateData, textErr := splitDrawStateText(msg.stateData)
+	for _, info := range infos {
+		if len(info) > 0 {
+			handleInfoText(info)
 		}
-		bubbleData := stateData[:p+end+1]
-		if verb, txt, bubbleName, lang, code, bubbleType, target := decodeBubble(bubbleData); txt != "" || code != kBubbleCodeKnown {
+	}
+	for _, tb := range textBubbles {
+		idx, typ, h, v := tb.index, tb.typ, tb.h, tb.v
+		if verb, txt, bubbleName, lang, code, bubbleType, target := decodeBubble(tb.data); txt != "" || code != kBubbleCodeKnown {
 			name := bubbleName
 			if target == thinkNone {
 				if bubbleName == ThinkUnknownName {
@@ -1760,7 +1821,9 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 				chatMessage(msg)
 			}
 		}
-		stateData = stateData[p+end+1:]
+	}
+	if textErr != nil {
+		return ack, resend, textErr
 	}
 
 	if len(bubbles) > 0 {
@@ -1769,7 +1832,7 @@ func parseDrawState(data []byte, buildCache bool) (int32, int32, error) {
 		stateMu.Unlock()
 	}
 
-	stage = "sound count"
+	stage := "sound count"
 	if len(stateData) < 1 {
 		return ack, resend, errors.New(stage)
 	}
@@ -201,3 +201,43 @@ func BenchmarkHandleDrawStateNoEncryption(b *testing.B) {
 		handleDrawState(packet, true)
 	}
 }
+
+// Test that decoding a draw state reports each section, stops at a
+// truncation with the earlier sections kept, and splits the state data into
+// info strings, bubbles and the rest.
+func TestDecodeDrawState(t *testing.T) {
+	mob := frameMobile{Index: 3, State: 1, H: -20, V: 5, Colors: 2}
+	data := testDrawStatePacket(9, 40, 0, []framePicture{{PictID: 100, H: -7, V: 4}}, mob)[2:]
+	var msg drawStateMsg
+	if err := decodeDrawState(&msg, data); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if msg.ack != 9 || msg.hp != 40 || msg.sections != drawSectionStateData {
+		t.Fatalf("msg %+v", msg)
+	}
+	if len(msg.descs) != 1 || msg.descs[0].PictID != 0x1bf || len(msg.pics) != 1 || msg.pics[0].H != -7 {
+		t.Fatalf("descs %+v pics %+v", msg.descs, msg.pics)
+	}
+	if len(msg.mobiles) != 1 || msg.mobiles[0] != mob || len(msg.stateData) != 4 {
+		t.Fatalf("mobiles %+v state data % x", msg.mobiles, msg.stateData)
+	}
+
+	if err := decodeDrawState(&msg, data[:len(data)-8]); err == nil || msg.sections != drawSectionPictures {
+		t.Fatalf("truncated: %v, sections %d", err, msg.sections)
+	}
+	if err := decodeDrawState(&msg, data[:5]); err != errDrawStateHeader {
+		t.Fatalf("short header: %v", err)
+	}
+
+	st := []byte("one\x00\xC2hftwo\x00")
+	st = append(st, 1, 5, kBubbleNormal)
+	st = append(st, "hi\x00"...)
+	st = append(st, 0, 9)
+	infos, bubbles, rest, err := splitDrawStateText(st)
+	if err != nil || len(infos) != 2 || string(infos[1]) != "\xC2hftwo" {
+		t.Fatalf("infos %q, %v", infos, err)
+	}
+	if len(bubbles) != 1 || bubbles[0].index != 5 || string(bubbles[0].data) != "\x05\x00hi\x00" || string(rest) != "\x00\x09" {
+		t.Fatalf("bubbles %+v rest % x", bubbles, rest)
+	}
+}
@@ -1306,6 +1306,17 @@ func (item *itemData) drawItemInternal(parent *itemData, offset point, base poin
 			filledCol := style.SelectedColor
 			strokeLine(subImg, trackStart, trackY, knobCenter, trackY, 2*uiScale, filledCol, true)
 			strokeLine(subImg, knobCenter, trackY, trackStart+trackWidth, trackY, 2*uiScale, itemColor, true)
+			// ScrollMarks on a slider are drawn as ticks along the track.
+			if len(item.ScrollMarks) > 0 {
+				markCol := AccentColor().ToRGBA()
+				for _, m := range item.ScrollMarks {
+					if m < 0 || m > 1 {
+						continue
+					}
+					x := trackStart + m*trackWidth
+					drawFilledRect(subImg, x-uiScale, trackY-4*uiScale, 2*uiScale, 8*uiScale, markCol, false)
+				}
+			}
 			knobRect := point{X: knobCenter - knobW/2, Y: offset.Y + (maxSize.Y-knobH)/2}
 			drawRoundRect(subImg, &roundRect{
 				Size:     pointScaleMul(item.AuxSize),
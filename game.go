@@ -202,6 +202,7 @@ var notificationsWin *eui.WindowData
 
 var (
 	lastDebugStatsUpdate   time.Time
+	lastScriptStatsUpdate  time.Time
 	lastQualityPresetCheck time.Time
 	lastMovieWinRefresh    time.Time
 )
@@ -525,6 +526,11 @@ func computeInterpolation(now, prevTime, curTime time.Time, mobileRate, pictRate
 		suppressInterpOnce = false
 		return 1.0, 1.0, 1.0
 	}
+	if reversePlayback {
+		// Reverse playback restores saved states; there is nothing to
+		// blend toward.
+		return 1.0, 1.0, 1.0
+	}
 	alpha = 1.0
 	mobileFade = 1.0
 	pictFade = 1.0
@@ -617,6 +623,7 @@ func (g *Game) Update() error {
 	eui.Update() //We really need this to return eaten clicks
 	// Advance script tick waiters once per frame
 	scriptAdvanceTick()
+	runAutoRecord(now)
 	typingElsewhere := typingInUI()
 	if inputActive && inputFlow != nil && len(inputFlow.Contents) > 0 {
 		item := inputFlow.Contents[0]
@@ -673,6 +680,19 @@ func (g *Game) Update() error {
 		}
 	}
 
+	if inspectorWin != nil && inspectorWin.IsOpen() {
+		updateInspectorWindow(now)
+	}
+
+	updateNetHealth(now)
+
+	if scriptStatsWin != nil && scriptStatsWin.IsOpen() {
+		if now.Sub(lastScriptStatsUpdate) >= time.Second {
+			refreshscriptStatsWindow()
+			lastScriptStatsUpdate = now
+		}
+	}
+
 	if joystickWin != nil && joystickWin.IsOpen() {
 		updateJoystickWindow()
 	}
@@ -688,6 +708,8 @@ func (g *Game) Update() error {
 		playersDirty = false
 	}
 
+	runscriptUIQueue()
+
 	if syncWindowSettings() {
 		settingsDirty = true
 	}
@@ -855,6 +877,9 @@ func (g *Game) Update() error {
 							consoleMessage("> " + txt)
 							arg := strings.TrimSpace(txt[len("/testhooks"):])
 							testScriptHooks(arg)
+						} else if lower == "/instantreplay" {
+							consoleMessage("> " + txt)
+							saveInstantReplay()
 						} else {
 							parts := strings.SplitN(strings.TrimPrefix(txt, "/"), " ", 2)
 							name := strings.ToLower(parts[0])
@@ -867,7 +892,7 @@ func (g *Game) Update() error {
 								if !scriptDisabled[owner] {
 									consoleMessage("> " + txt)
 									scriptLogEvent(owner, "Command", args)
-									go handler(args)
+									scriptGo(owner, "Command", func() { handler(args) })
 								} else {
 									// Disabled script commands should fall through so the
 									// server still receives the user's input.
@@ -957,6 +982,12 @@ func (g *Game) Update() error {
 			keyY = int16(float64(dy) * float64(fieldCenterY) * speed)
 		}
 	}
+	if focused && !inputActive && !typingElsewhere && activeMovie != nil {
+		activeMovie.handleKeys()
+	}
+	if activeMovie != nil {
+		activeMovie.showPrecomputeProgress()
+	}
 	if focused && !inputActive && !typingElsewhere && gs.JoystickEnabled && selectedJoystick >= 0 && selectedJoystick < len(joystickIDs) && gs.JoystickWalkStick >= 0 {
 		id := joystickIDs[selectedJoystick]
 		axis := gs.JoystickWalkStick * 2
@@ -1091,6 +1122,11 @@ func (g *Game) Update() error {
 		x, y = prev.mouseX, prev.mouseY
 	}
 
+	// Script movement applies only while the user is not moving.
+	if s, ok := scriptMoveInput(walk || (click && inGame) || !focused); ok {
+		x, y, walk = s.mouseX, s.mouseY, s.mouseDown
+	}
+
 	queueInput(inputState{mouseX: x, mouseY: y, mouseDown: walk})
 
 	// Warn about poor performance and suggest disabling shaders.
@@ -1334,23 +1370,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		snap = captureDrawSnapshot()
 		var mobileFade, pictFade float32
 		alpha, mobileFade, pictFade = computeInterpolation(now, snap.prevTime, snap.curTime, gs.MobileBlendAmount, gs.BlendAmount)
-		prev := gs.GameScale
-		gs.GameScale = float64(offIntScale)
-		drawScene(worldView, 0, 0, snap, alpha, mobileFade, pictFade)
-		if gs.ShaderLighting {
-			// Use shader-based night darkening with inverse-square falloff.
-			addNightDarkSources(offW, offH, float32(alpha))
-		} else {
-			// Classic overlay path when shader is off.
-			//drawNightAmbient(worldView, 0, 0)
-			drawNightOverlay(worldView, 0, 0)
-		}
-		if gs.ShaderLighting {
-			// Apply lighting on the active subimage only
-			applyLightingShader(worldView, frameLights, frameDarks, float32(alpha))
-		}
-		drawStatusBars(worldView, 0, 0, snap, alpha)
-		gs.GameScale = prev
+		drawWorld(worldView, offIntScale, snap, alpha, mobileFade, pictFade)
 		haveSnap = true
 	}
 
@@ -1442,6 +1462,30 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 var lastSeekPrev time.Time
 
+// drawWorld renders the scene, night/lighting and status bars for snap into
+// worldView, which must be sized to the game area times scale.
+func drawWorld(worldView *ebiten.Image, scale int, snap drawSnapshot, alpha float64, mobileFade, pictFade float32) {
+	offW := worldView.Bounds().Dx()
+	offH := worldView.Bounds().Dy()
+	prev := gs.GameScale
+	gs.GameScale = float64(scale)
+	drawScene(worldView, 0, 0, snap, alpha, mobileFade, pictFade)
+	if gs.ShaderLighting {
+		// Use shader-based night darkening with inverse-square falloff.
+		addNightDarkSources(offW, offH, float32(alpha))
+	} else {
+		// Classic overlay path when shader is off.
+		//drawNightAmbient(worldView, 0, 0)
+		drawNightOverlay(worldView, 0, 0)
+	}
+	if gs.ShaderLighting {
+		// Apply lighting on the active subimage only
+		applyLightingShader(worldView, frameLights, frameDarks, float32(alpha))
+	}
+	drawStatusBars(worldView, 0, 0, snap, alpha)
+	gs.GameScale = prev
+}
+
 func drawRecPlayBadge(dst *ebiten.Image) {
 	// Only show when actively recording/armed or playing back.
 	showRec := recorder != nil || recordingMovie
@@ -2734,6 +2778,7 @@ func noteFrame() {
 	frameMu.Lock()
 	if !lastFrameTime.IsZero() {
 		dt := now.Sub(lastFrameTime)
+		netHealth.interval(dt)
 		ms := int(dt.Round(10*time.Millisecond) / time.Millisecond)
 		if ms > 0 {
 			intervalHist[ms]++
@@ -2832,6 +2877,7 @@ func sendInputLoop(ctx context.Context, udpConn, tcpConn net.Conn) {
 
 		var err error
 		if reliable {
+			netHealth.keepAlive()
 			err = sendPlayerInput(tcpConn, s.mouseX, s.mouseY, s.mouseDown, true)
 		} else {
 			err = sendPlayerInput(udpConn, s.mouseX, s.mouseY, s.mouseDown, false)
@@ -2857,7 +2903,7 @@ func udpReadLoop(ctx context.Context, conn net.Conn) {
 					continue
 				}
 			}
-			handleDisconnect()
+			connectionLost(ctx)
 			return
 		}
 		tag := binary.BigEndian.Uint16(m[:2])
@@ -2927,6 +2973,10 @@ func udpReadLoop(ctx context.Context, conn net.Conn) {
 				}
 			}
 		}
+		instantReplayFrame(m, flags)
+		if tag == 2 {
+			netHealth.frame(false)
+		}
 		latencyMu.Lock()
 		if !lastInputSent.IsZero() {
 			rtt := time.Since(lastInputSent)
@@ -2964,7 +3014,7 @@ loop:
 					continue
 				}
 			}
-			handleDisconnect()
+			connectionLost(ctx)
 			break
 		}
 		tag := binary.BigEndian.Uint16(m[:2])
@@ -3033,6 +3083,10 @@ loop:
 				}
 			}
 		}
+		instantReplayFrame(m, flags)
+		if tag == 2 {
+			netHealth.frame(true)
+		}
 		processServerMessage(m)
 		// Allow maintenance queues to issue commands even when the
 		// player isn't moving; this keeps /be-info and /be-who flowing
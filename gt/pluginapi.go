@@ -52,6 +52,18 @@ func Save(key, value string) {}
 func Load(key string) string { return "" }
 func Delete(key string)      {}
 
+// Per-character storage for the logged-in character. MoveToChar moves a
+// key from the shared storage above when the character does not have it.
+func CharSave(key, value string) {}
+func CharLoad(key string) string { return "" }
+func CharDelete(key string)      {}
+func MoveToChar(key string) bool { return false }
+
+// OnCharacter runs when you log in as a different character than the one
+// whose Char* data the script last used. Scripts that keep character data
+// in memory reload it here.
+func OnCharacter(fn func(name string)) {}
+
 // Input box helpers
 func InputText() string        { return "" }
 func SetInputText(text string) {}
@@ -111,6 +123,24 @@ func OverlayRect(x, y, w, h int, r, g, b, a uint8)       {}
 func OverlayText(x, y int, txt string, r, g, b, a uint8) {}
 func OverlayImage(id uint16, x, y int)                   {}
 
+// Script windows. NewWindow returns a handle (0 on failure) and reuses the
+// window when called again with the same title. Add* return widget handles
+// for the Set* functions. Window positions are saved between sessions.
+func NewWindow(title string) int                                                   { return 0 }
+func ShowWindow(w int)                                                             {}
+func HideWindow(w int)                                                             {}
+func ToggleWindow(w int)                                                           {}
+func ClearWindow(w int)                                                            {}
+func AddText(w int, txt string) int                                                { return 0 }
+func AddButton(w int, label string, fn func()) int                                 { return 0 }
+func AddCheckbox(w int, label string, checked bool, fn func(bool)) int             { return 0 }
+func AddSlider(w int, label string, min, max, value float64, fn func(float64)) int { return 0 }
+func AddList(w int, lines []string) int                                            { return 0 }
+func SetText(id int, txt string)                                                   {}
+func SetChecked(id int, on bool)                                                   {}
+func SetValue(id int, v float64)                                                   {}
+func SetList(id int, lines []string)                                               {}
+
 // Mouse and keyboard
 func KeyJustPressed(name string) bool   { return false }
 func MouseJustPressed(name string) bool { return false }
@@ -123,6 +153,10 @@ type Mobile struct {
 	H, V   int16
 	PictID uint16
 	Colors uint8
+	Type   uint8
+	State  uint8
+	Dead   bool
+	Range  int
 }
 type ClickInfo struct {
 	X, Y     int16
@@ -136,6 +170,42 @@ type ClickInfo struct {
 
 func LastClick() ClickInfo { return ClickInfo{} }
 
+// World snapshots
+type Picture struct {
+	PictID     uint16
+	H, V       int16
+	Plane      int
+	Moving     bool
+	Background bool
+}
+type Descriptor struct {
+	Index  uint8
+	Type   uint8
+	PictID uint16
+	Name   string
+	Colors []byte
+}
+type SelfInfo struct {
+	Mobile
+	Found      bool
+	HP, HPMax  int
+	SP, SPMax  int
+	Balance    int
+	BalanceMax int
+}
+
+// Descriptor types
+const (
+	DescPlayer  = 1
+	DescMonster = 2
+	DescNPC     = 3
+)
+
+func Mobiles() []Mobile         { return nil }
+func Pictures() []Picture       { return nil }
+func Descriptors() []Descriptor { return nil }
+func Self() SelfInfo            { return SelfInfo{} }
+
 // Chat trigger kinds
 const (
 	ChatAny = 1 << iota
@@ -165,6 +235,33 @@ func RegisterPlayerHandler(fn func(Player))                           {}
 func RegisterInputHandler(fn func(string) string)                     {}
 func RegisterChatHandler(fn func(string))                             {}
 
+// Frame and vitals hooks
+type FrameInfo struct {
+	Frame    int
+	Ack      int32
+	Dropped  int
+	Mobiles  int
+	Pictures int
+}
+
+func OnFrame(fn func(FrameInfo))                              {}
+func OnVitals(fn func(hp, hpMax, sp, spMax, bal, balMax int)) {}
+
+// Movement. WalkTo walks to a field position (the coordinates of Mobile.H
+// and Mobile.V) as it is when called, and stops within a few pixels of it.
+// It stops early on StopWalking, when you move yourself, or when the view
+// jumps, as after a teleport.
+// ClickMobile clicks the named mobile and reports whether it was in view.
+// Calls closer together than 100ms are ignored.
+func WalkTo(h, v int)              {}
+func ClickMobile(name string) bool { return false }
+func StopWalking()                 {}
+
+// Messages between scripts. Subscribers receive a JSON copy of the payload:
+// numbers arrive as float64 and structs as map[string]any.
+func Publish(topic string, payload any)    {}
+func Subscribe(topic string, fn func(any)) {}
+
 // Time helpers
 func SleepTicks(ticks int)                {}
 func After(ms int, fn func())             {}
@@ -995,7 +995,7 @@ func checkHotkeys() {
 							trig = parts[len(parts)-1]
 						}
 						ev := HotkeyEvent{Combo: combo, Parts: parts, Trigger: trig}
-						go fn(ev)
+						scriptGo(hk.Script, "Hotkey", func() { fn(ev) })
 					}
 				}
 				for _, c := range hk.Commands {
@@ -1010,6 +1010,10 @@ func checkHotkeys() {
 						settingsDirty = true
 						continue
 					}
+					if lower == "/instantreplay" {
+						saveInstantReplay()
+						continue
+					}
 					// Show hotkey-triggered command as if it were typed
 					var ok bool
 					cmd, ok = applyHotkeyVars(cmd)
@@ -0,0 +1,368 @@
+// Package fakeserver is a stand-in Clan Lord server for end-to-end client
+// testing. It speaks the wire protocol the client's login expects (the TCP
+// id handshake confirmed over UDP, the identifiers message and the twofish
+// challenge) and then serves draw state frames over UDP while reading
+// player input from both channels.
+package fakeserver
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gothoom/internal/twofish"
+)
+
+// Message tags used by the login and game protocol.
+const (
+	MsgDrawState   = 2
+	MsgPlayerInput = 3
+	MsgLogOn       = 13
+	MsgChallenge   = 18
+	MsgIdentifiers = 19
+)
+
+// Login result codes sent in the MsgLogOn reply.
+const (
+	resultOK       = 0
+	errBadCharPass = -30998
+)
+
+// Config describes the server. The zero value serves generated frames to
+// any character and password on a random local port.
+type Config struct {
+	// Addr is the TCP and UDP listen address; both use the same port.
+	// Defaults to 127.0.0.1:0.
+	Addr string
+	// Password, when set, is the only character password accepted.
+	Password string
+	// Version is the client version announced in the challenge.
+	// Defaults to 1440.
+	Version int
+	// FrameInterval is the time between draw states. Defaults to 200ms,
+	// the server's 5 frames per second.
+	FrameInterval time.Duration
+	// Frames, when set, are served in a loop instead of generated frames.
+	// Each is a full draw state message starting with its tag.
+	Frames [][]byte
+	// Logf receives progress messages; nil discards them.
+	Logf func(format string, args ...any)
+}
+
+// Input is a player input message received from a client.
+type Input struct {
+	Name      string
+	MouseX    int16
+	MouseY    int16
+	MouseDown bool
+	Command   string
+	Reliable  bool // sent over TCP
+}
+
+// Server is a running fake server.
+type Server struct {
+	cfg    Config
+	ln     net.Listener
+	pc     net.PacketConn
+	inputs chan Input
+
+	mu       sync.Mutex
+	pending  map[uint32]chan net.Addr
+	sessions map[string]*session // by UDP address
+}
+
+// Listen opens the TCP and UDP sockets. Call Serve to accept clients.
+func Listen(cfg Config) (*Server, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:0"
+	}
+	if cfg.Version == 0 {
+		cfg.Version = 1440
+	}
+	if cfg.FrameInterval <= 0 {
+		cfg.FrameInterval = 200 * time.Millisecond
+	}
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	// UDP shares the TCP port, as on the real server.
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &Server{
+		cfg:      cfg,
+		ln:       ln,
+		pc:       pc,
+		inputs:   make(chan Input, 256),
+		pending:  map[uint32]chan net.Addr{},
+		sessions: map[string]*session{},
+	}, nil
+}
+
+// Addr returns the address clients connect to.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// Inputs delivers player input from all clients. Input is dropped when
+// nobody reads it.
+func (s *Server) Inputs() <-chan Input { return s.inputs }
+
+// Sessions returns the names of the logged in characters.
+func (s *Server) Sessions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, c := range s.sessions {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// DisconnectAll drops every client, as a server restart would.
+func (s *Server) DisconnectAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.sessions {
+		c.tcp.Close()
+	}
+}
+
+// Serve accepts clients until ctx is canceled, then closes the sockets.
+func (s *Server) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		s.ln.Close()
+		s.pc.Close()
+		s.DisconnectAll()
+	}()
+	go s.readUDP()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			if err := s.handle(ctx, conn); err != nil {
+				s.logf("%v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.cfg.Logf != nil {
+		s.cfg.Logf(format, args...)
+	}
+}
+
+// readUDP routes handshakes to the waiting logins and messages to their
+// sessions.
+func (s *Server) readUDP() {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		p := buf[:n]
+		if n == 6 && p[0] == 0xff && p[1] == 0xff {
+			id := binary.BigEndian.Uint32(p[2:])
+			s.mu.Lock()
+			ch := s.pending[id]
+			delete(s.pending, id)
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- addr
+			}
+			continue
+		}
+		s.mu.Lock()
+		c := s.sessions[addr.String()]
+		s.mu.Unlock()
+		if c == nil {
+			continue
+		}
+		for len(p) >= 2 {
+			l := int(binary.BigEndian.Uint16(p))
+			if len(p) < 2+l {
+				break
+			}
+			c.handleMessage(p[2:2+l], false)
+			p = p[2+l:]
+		}
+	}
+}
+
+// handle runs the login exchange on conn and then the session.
+func (s *Server) handle(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var idBuf [4]byte
+	if _, err := rand.Read(idBuf[:]); err != nil {
+		return err
+	}
+	id := binary.BigEndian.Uint32(idBuf[:])
+	ch := make(chan net.Addr, 1)
+	s.mu.Lock()
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+	if _, err := conn.Write(idBuf[:]); err != nil {
+		return err
+	}
+	var udpAddr net.Addr
+	select {
+	case udpAddr = <-ch:
+	case <-time.After(10 * time.Second):
+		return errors.New("no UDP handshake")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if _, err := conn.Write([]byte{0, 0}); err != nil {
+		return err
+	}
+
+	msg, err := readMessage(conn)
+	if err != nil {
+		return fmt.Errorf("read identifiers: %w", err)
+	}
+	if tag := binary.BigEndian.Uint16(msg); tag != MsgIdentifiers {
+		return fmt.Errorf("expected identifiers, got tag %d", tag)
+	}
+
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	cm := make([]byte, 32)
+	binary.BigEndian.PutUint16(cm[0:], MsgChallenge)
+	binary.BigEndian.PutUint32(cm[4:], uint32(s.cfg.Version)<<8)
+	copy(cm[16:], challenge)
+	if err := writeMessage(conn, cm); err != nil {
+		return err
+	}
+
+	msg, err = readMessage(conn)
+	if err != nil {
+		return fmt.Errorf("read login: %w", err)
+	}
+	if tag := binary.BigEndian.Uint16(msg); tag != MsgLogOn || len(msg) < 17 {
+		return fmt.Errorf("expected login, got tag %d", tag)
+	}
+	body := append([]byte(nil), msg[16:]...)
+	simpleEncrypt(body)
+	i := strings.IndexByte(string(body), 0)
+	if i < 0 || len(body) < i+1+16 {
+		return errors.New("malformed login")
+	}
+	name := string(body[:i])
+	answer := body[i+1 : i+1+16]
+
+	result := int16(resultOK)
+	if s.cfg.Password != "" {
+		want, err := challengeAnswer(s.cfg.Password, challenge)
+		if err != nil {
+			return err
+		}
+		if string(want) != string(answer) {
+			result = errBadCharPass
+		}
+	}
+	reply := make([]byte, 16)
+	binary.BigEndian.PutUint16(reply[0:], MsgLogOn)
+	binary.BigEndian.PutUint16(reply[2:], uint16(result))
+	if err := writeMessage(conn, reply); err != nil {
+		return err
+	}
+	if result != resultOK {
+		return fmt.Errorf("%s: bad password", name)
+	}
+	conn.SetDeadline(time.Time{})
+	s.logf("%s logged in from %v", name, udpAddr)
+
+	c := &session{srv: s, tcp: conn, udp: udpAddr, name: name}
+	s.mu.Lock()
+	s.sessions[udpAddr.String()] = c
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, udpAddr.String())
+		s.mu.Unlock()
+		s.logf("%s disconnected", name)
+	}()
+	return c.run(ctx)
+}
+
+// challengeAnswer computes the response the client derives from the
+// password: the challenge decrypted with the password's MD5 as twofish
+// key, hashed, and encrypted again.
+func challengeAnswer(password string, challenge []byte) ([]byte, error) {
+	digest := md5.Sum([]byte(password))
+	key := make([]byte, len(digest))
+	for i := 0; i < len(digest); i += 4 {
+		binary.LittleEndian.PutUint32(key[i:], binary.BigEndian.Uint32(digest[i:]))
+	}
+	block, err := twofish.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	plain := make([]byte, len(challenge))
+	for i := 0; i+bs <= len(challenge); i += bs {
+		block.Decrypt(plain[i:i+bs], challenge[i:i+bs])
+	}
+	h := md5.Sum(plain)
+	out := make([]byte, len(h))
+	for i := 0; i+bs <= len(h); i += bs {
+		block.Encrypt(out[i:i+bs], h[i:i+bs])
+	}
+	return out, nil
+}
+
+// simpleEncrypt is the client's XOR obfuscation; applying it twice
+// restores the data.
+func simpleEncrypt(data []byte) {
+	key := []byte{0x3c, 0x5a, 0x69, 0x93, 0xa5, 0xc6}
+	for i := range data {
+		data[i] ^= key[i%len(key)]
+	}
+}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if len(buf) < 2 {
+		return nil, errors.New("short message")
+	}
+	return buf, nil
+}
+
+func writeMessage(w io.Writer, msg []byte) error {
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
@@ -0,0 +1,148 @@
+package fakeserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// login performs the client side of the handshake and returns the login
+// result along with the open connections.
+func login(t *testing.T, addr, name, password string) (int16, net.Conn, net.Conn) {
+	t.Helper()
+	tcp, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial tcp: %v", err)
+	}
+	udp, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial udp: %v", err)
+	}
+	tcp.SetDeadline(time.Now().Add(5 * time.Second))
+	var id [4]byte
+	if _, err := io.ReadFull(tcp, id[:]); err != nil {
+		t.Fatalf("read id: %v", err)
+	}
+	if _, err := udp.Write(append([]byte{0xff, 0xff}, id[:]...)); err != nil {
+		t.Fatalf("udp handshake: %v", err)
+	}
+	var confirm [2]byte
+	if _, err := io.ReadFull(tcp, confirm[:]); err != nil {
+		t.Fatalf("read confirm: %v", err)
+	}
+	ids := make([]byte, 20)
+	binary.BigEndian.PutUint16(ids, MsgIdentifiers)
+	if err := writeMessage(tcp, ids); err != nil {
+		t.Fatalf("send identifiers: %v", err)
+	}
+	msg, err := readMessage(tcp)
+	if err != nil || binary.BigEndian.Uint16(msg) != MsgChallenge {
+		t.Fatalf("read challenge: %v", err)
+	}
+	answer, err := challengeAnswer(password, msg[16:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16, 16+len(name)+1+len(answer))
+	binary.BigEndian.PutUint16(buf, MsgLogOn)
+	buf = append(append(append(buf, name...), 0), answer...)
+	simpleEncrypt(buf[16:])
+	if err := writeMessage(tcp, buf); err != nil {
+		t.Fatalf("send login: %v", err)
+	}
+	resp, err := readMessage(tcp)
+	if err != nil || len(resp) < 4 {
+		t.Fatalf("read login response: %v", err)
+	}
+	tcp.SetDeadline(time.Time{})
+	return int16(binary.BigEndian.Uint16(resp[2:])), tcp, udp
+}
+
+func TestLoginAndFrames(t *testing.T) {
+	srv, err := Listen(Config{Password: "secret", FrameInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx)
+
+	res, tcp, udp := login(t, srv.Addr(), "Tester", "wrong")
+	tcp.Close()
+	udp.Close()
+	if res != errBadCharPass {
+		t.Fatalf("bad password result %d", res)
+	}
+
+	res, tcp, udp = login(t, srv.Addr(), "Tester", "secret")
+	defer tcp.Close()
+	defer udp.Close()
+	if res != resultOK {
+		t.Fatalf("login result %d", res)
+	}
+
+	in := make([]byte, 20)
+	binary.BigEndian.PutUint16(in, MsgPlayerInput)
+	in = append(append(in, "hello"...), 0)
+	if err := writeMessage(tcp, in); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-srv.Inputs():
+		if got.Name != "Tester" || got.Command != "hello" || !got.Reliable {
+			t.Fatalf("input %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no input")
+	}
+
+	udp.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	for {
+		n, err := udp.Read(buf)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		msg := buf[2:n]
+		if binary.BigEndian.Uint16(msg) != MsgDrawState {
+			t.Fatalf("frame tag %d", binary.BigEndian.Uint16(msg))
+		}
+		if bytes.Contains(msg, []byte("hello")) {
+			break
+		}
+	}
+}
+
+func TestMovieFrames(t *testing.T) {
+	frame := func(flags uint16, blocks, msg []byte) []byte {
+		b := []byte{0xde, 0xad, 0xbe, 0xef, 0, 0, 0, 1}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(msg)))
+		b = binary.BigEndian.AppendUint16(b, flags)
+		return append(append(b, blocks...), msg...)
+	}
+	draw := []byte{0, MsgDrawState, 1, 2, 3}
+	other := []byte{0, 5, 9}
+	data := make([]byte, 24)
+	binary.BigEndian.PutUint32(data, movieSignature)
+	binary.BigEndian.PutUint16(data[6:], 24)
+	data = append(data, frame(0x04, []byte{7, 7, 7, 7}, draw)...)
+	data = append(data, frame(0, nil, other)...)
+	data = append(data, frame(0, nil, draw)...)
+
+	frames, err := movieFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames", len(frames))
+	}
+	for _, f := range frames {
+		if string(f) != string(draw) {
+			t.Fatalf("frame %v", f)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package fakeserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+const movieSignature = 0xdeadbeef
+
+// LoadMovieFrames returns the draw state messages of a classic .clMov so
+// they can be served as Config.Frames. Login blocks and other messages are
+// left out.
+func LoadMovieFrames(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return movieFrames(data)
+}
+
+// movieFrames walks the frame headers of a movie. A frame's payload is the
+// last Size bytes before the next frame header, which skips any login
+// blocks (GameState, MobileData, PictureTable) stored ahead of it without
+// decoding them.
+func movieFrames(data []byte) ([][]byte, error) {
+	if len(data) < 24 || binary.BigEndian.Uint32(data) != movieSignature {
+		return nil, errors.New("not a .clMov file")
+	}
+	sign := []byte{0xde, 0xad, 0xbe, 0xef}
+	pos := int(binary.BigEndian.Uint16(data[6:]))
+	if pos < 24 || pos > len(data) {
+		pos = 24
+	}
+	var frames [][]byte
+	for pos+12 <= len(data) {
+		if !bytes.Equal(data[pos:pos+4], sign) {
+			i := bytes.Index(data[pos:], sign)
+			if i < 0 {
+				break
+			}
+			pos += i
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+8:]))
+		flags := binary.BigEndian.Uint16(data[pos+10:])
+		start := pos + 12
+		end := start + size
+		if flags&0x0e != 0 {
+			// Blocks come first; find where the next frame starts.
+			next := len(data)
+			if i := bytes.Index(data[end:], sign); i >= 0 {
+				next = end + i
+			}
+			end = next
+			start = end - size
+		}
+		if end > len(data) {
+			break
+		}
+		if size >= 2 && binary.BigEndian.Uint16(data[start:]) == MsgDrawState {
+			frames = append(frames, append([]byte(nil), data[start:end]...))
+		}
+		pos = end
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("no draw states in movie")
+	}
+	return frames, nil
+}
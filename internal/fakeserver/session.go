@@ -0,0 +1,172 @@
+package fakeserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pimDownField = 0x0001 // player input flag: mouse down
+	descPlayer   = 1      // descriptor type of players
+	playerPict   = 447
+	playerIndex  = 0
+	maxStep      = 8 // pixels the player moves per frame toward the mouse
+)
+
+// session is a logged in client.
+type session struct {
+	srv  *Server
+	tcp  net.Conn
+	udp  net.Addr
+	name string
+
+	mu      sync.Mutex
+	frame   int32
+	h, v    int16
+	moveH   int16
+	moveV   int16
+	info    []string
+	bubbles []string
+}
+
+// run serves frames until the client goes away or ctx ends.
+func (c *session) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.serveFrames(ctx)
+	for {
+		msg, err := readMessage(c.tcp)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		c.handleMessage(msg, true)
+	}
+}
+
+func (c *session) serveFrames(ctx context.Context) {
+	t := time.NewTicker(c.srv.cfg.FrameInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		var msg []byte
+		if frames := c.srv.cfg.Frames; len(frames) > 0 {
+			c.mu.Lock()
+			msg = frames[int(c.frame)%len(frames)]
+			c.frame++
+			c.mu.Unlock()
+		} else {
+			msg = c.nextFrame()
+		}
+		buf := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+		if _, err := c.srv.pc.WriteTo(append(buf, msg...), c.udp); err != nil {
+			c.srv.logf("%s: send frame: %v", c.name, err)
+		}
+	}
+}
+
+// handleMessage reacts to a client message: mouse down walks the player
+// toward the pointer, speech becomes a bubble and a few commands are
+// answered in the info text.
+func (c *session) handleMessage(msg []byte, reliable bool) {
+	if len(msg) < 21 || binary.BigEndian.Uint16(msg) != MsgPlayerInput {
+		return
+	}
+	in := Input{
+		Name:      c.name,
+		MouseX:    int16(binary.BigEndian.Uint16(msg[2:])),
+		MouseY:    int16(binary.BigEndian.Uint16(msg[4:])),
+		MouseDown: binary.BigEndian.Uint16(msg[6:])&pimDownField != 0,
+		Reliable:  reliable,
+	}
+	cmd := msg[20:]
+	if i := strings.IndexByte(string(cmd), 0); i >= 0 {
+		cmd = cmd[:i]
+	}
+	in.Command = string(cmd)
+
+	c.mu.Lock()
+	c.moveH, c.moveV = 0, 0
+	if in.MouseDown {
+		c.moveH, c.moveV = clampStep(in.MouseX), clampStep(in.MouseY)
+	}
+	switch lower := strings.ToLower(in.Command); {
+	case in.Command == "":
+	case lower == "/who":
+		c.info = append(c.info, "In the lands: "+strings.Join(c.srv.Sessions(), ", "))
+	case lower == "/disconnect":
+		c.tcp.Close()
+	case strings.HasPrefix(lower, "/"):
+		c.info = append(c.info, "Unknown command: "+in.Command)
+	default:
+		c.bubbles = append(c.bubbles, in.Command)
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.srv.inputs <- in:
+	default:
+	}
+}
+
+func clampStep(d int16) int16 {
+	switch {
+	case d > maxStep:
+		return maxStep
+	case d < -maxStep:
+		return -maxStep
+	}
+	return d
+}
+
+// nextFrame builds the next generated draw state: the player's descriptor
+// on the first frame, the player's mobile, and any pending info text and
+// bubbles.
+func (c *session) nextFrame() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frame++
+	c.h += c.moveH
+	c.v += c.moveV
+
+	m := []byte{0, MsgDrawState, 0}
+	m = binary.BigEndian.AppendUint32(m, uint32(c.frame))
+	m = binary.BigEndian.AppendUint32(m, 0)
+	if c.frame == 1 {
+		m = append(m, 1, playerIndex, descPlayer)
+		m = binary.BigEndian.AppendUint16(m, playerPict)
+		m = append(m, c.name...)
+		m = append(m, 0, 0) // name terminator, no colors
+	} else {
+		m = append(m, 0)
+	}
+	m = append(m, 100, 100, 100, 100, 100, 100, 0) // stats, lighting
+	m = append(m, 0)                               // pictures
+	m = append(m, 1, playerIndex, 0)
+	m = binary.BigEndian.AppendUint16(m, uint16(c.h))
+	m = binary.BigEndian.AppendUint16(m, uint16(c.v))
+	m = append(m, 0)
+
+	st := []byte(strings.Join(c.info, "\r"))
+	st = append(st, 0, byte(len(c.bubbles)))
+	for _, b := range c.bubbles {
+		st = append(st, playerIndex, 0) // normal bubble
+		st = append(st, b...)
+		st = append(st, 0)
+	}
+	st = append(st, 0, 0) // sounds, inventory
+	c.info, c.bubbles = nil, nil
+
+	m = binary.BigEndian.AppendUint16(m, uint16(len(st)))
+	return append(m, st...)
+}
@@ -30,6 +30,16 @@ type serverTarget struct {
 
 var errRetryLogin = errors.New("retry login")
 
+// loginError is a login the server refused with a kError result code.
+type loginError int16
+
+func (e loginError) Error() string {
+	if name, ok := errorNames[int16(e)]; ok {
+		return fmt.Sprintf("login failed: %s (%d)", name, int16(e))
+	}
+	return fmt.Sprintf("login failed: %d", int16(e))
+}
+
 func serverTargets(addr string) []serverTarget {
 	primary := serverTarget{addr: addr, display: addr}
 	fallbackAddr, ok := fallbackAddress(addr)
@@ -58,7 +68,7 @@ func dialServer(network string, target serverTarget) (net.Conn, error) {
 		recordFallbackFailure(target, err)
 		return nil, err
 	}
-	return conn, nil
+	return wrapNetSim(conn, network), nil
 }
 
 var (
@@ -126,8 +136,9 @@ func fallbackAddress(addr string) (string, bool) {
 }
 
 func handleDisconnect() {
+	reconnecting := stopReconnect()
 	loginMu.Lock()
-	if loginCancel == nil {
+	if loginCancel == nil && !reconnecting {
 		loginMu.Unlock()
 		return
 	}
@@ -135,23 +146,13 @@ func handleDisconnect() {
 	loginCancel = nil
 	loginMu.Unlock()
 
-	cancel()
+	if cancel != nil {
+		cancel()
+	}
 	if recorder != nil {
 		stopRecording()
 	}
-	// Reset frame/loss counters so a new session starts fresh.
-	lastAckFrame = 0
-	numFrames = 0
-	lostFrames = 0
-	for i := range frameBuckets {
-		frameBuckets[i] = 0
-	}
-	for i := range lostBuckets {
-		lostBuckets[i] = 0
-	}
-	for i := range bucketTimes {
-		bucketTimes[i] = 0
-	}
+	resetFrameStats()
 	// Reset session sources so we return to splash state
 	clmov = ""
 	pcapPath = ""
@@ -174,6 +175,23 @@ func handleDisconnect() {
 	updateCharacterButtons()
 }
 
+// resetFrameStats clears the frame and loss counters so a new session
+// starts fresh.
+func resetFrameStats() {
+	lastAckFrame = 0
+	numFrames = 0
+	lostFrames = 0
+	for i := range frameBuckets {
+		frameBuckets[i] = 0
+	}
+	for i := range lostBuckets {
+		lostBuckets[i] = 0
+	}
+	for i := range bucketTimes {
+		bucketTimes[i] = 0
+	}
+}
+
 const CL_ImagesFile = "CL_Images"
 const CL_SoundsFile = "CL_Sounds"
 
@@ -348,7 +366,12 @@ func fetchDemoFromTarget(target serverTarget, sendVersion int, imagesVersion, so
 // It runs the network loops and blocks until the context is canceled.
 func login(ctx context.Context, clVersion int) error {
 	resetDrawState()
-	if gs.AutoRecord {
+	replayBuf.reset()
+	if !reconnectPending() {
+		netHealth.reset()
+	}
+	// A reconnect keeps appending to the running recording.
+	if gs.AutoRecord && recorder == nil {
 		recordingMovie = true
 	}
 	go setupSynthOnce.Do(setupSynth)
@@ -537,6 +560,7 @@ func runLoginAttempt(ctx context.Context, target serverTarget, sendVersion int,
 	playerName = utfFold(name)
 	applyLocalLabels()
 	applyEnabledScripts()
+	runCharacterHandlers()
 	loadShortcuts()
 
 	var resp []byte
@@ -629,10 +653,7 @@ func runLoginAttempt(ctx context.Context, target serverTarget, sendVersion int,
 		tcp = nil
 		udp.Close()
 		udp = nil
-		if name, ok := errorNames[result]; ok {
-			return fmt.Errorf("login failed: %s (%d)", name, result)
-		}
-		return fmt.Errorf("login failed: %d", result)
+		return loginError(result)
 	}
 
 	logDebug("login succeeded, reading messages (Ctrl-C to quit)...")
@@ -653,6 +674,7 @@ func runLoginAttempt(ctx context.Context, target serverTarget, sendVersion int,
 	loginMu.Lock()
 	tcpConn = tcp
 	loginMu.Unlock()
+	reconnected()
 
 	if err := tcp.SetDeadline(time.Time{}); err != nil {
 		tcp.Close()
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gothoom/internal/fakeserver"
+)
+
+// Test the full login handshake and the network loops against the local
+// stand-in server: a queued command must reach it over UDP or TCP.
+func TestLoginWithFakeServer(t *testing.T) {
+	srv, err := fakeserver.Listen(fakeserver.Config{Password: "secret", FrameInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go srv.Serve(ctx)
+
+	origName, origPass, origHash := name, pass, passHash
+	defer func() { name, pass, passHash = origName, origPass, origHash }()
+	target := serverTarget{addr: srv.Addr(), display: srv.Addr()}
+
+	name, pass, passHash = "Tester", "wrong", ""
+	err = runLoginAttempt(ctx, target, clVersion, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "kBadCharPass") {
+		t.Fatalf("bad password: err = %v", err)
+	}
+
+	name, pass = "Tester", "secret"
+	enqueueCommand("/who")
+	loginCtx, logout := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- runLoginAttempt(loginCtx, target, clVersion, 0, 0) }()
+	for {
+		select {
+		case in := <-srv.Inputs():
+			if in.Command != "/who" {
+				continue
+			}
+			if in.Name != "Tester" {
+				t.Fatalf("input from %q", in.Name)
+			}
+			logout()
+			if err := <-done; err != nil {
+				t.Fatalf("runLoginAttempt: %v", err)
+			}
+			return
+		case err := <-done:
+			t.Fatalf("login ended early: %v", err)
+		case <-ctx.Done():
+			t.Fatalf("command never reached the server")
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime/pprof"
+	"strings"
 	"time"
 
 	"gothoom/climg"
@@ -70,8 +71,12 @@ func main() {
 	dumpTempo := flag.Int("dumpTempo", 120, "tempo for -dumpTune (BPM)")
 	dumpInst := flag.Int("dumpInst", defaultInstrument, "instrument index for -dumpTune")
 	flag.StringVar(&clmov, "clmov", "", "play back a .clMov file")
-	flag.StringVar(&pcapPath, "pcap", "", "replay network frames from a .pcap/.pcapng file")
+	flag.StringVar(&pcapPath, "pcap", "", "play back the server frames of a .pcap/.pcapng capture")
+	pcapServer := flag.String("pcapServer", "", "server address in the -pcap capture: host, host:port or :port (default :5010)")
+	pcapOut := flag.String("pcapOut", "", "write the -pcap capture as a .clMov to this file and exit")
+	speed := flag.Float64("speed", 1, "playback speed multiplier for -clmov and -pcap")
 	flag.BoolVar(&fake, "fake", false, "simulate server messages without connecting")
+	netSimSpec := flag.String("netsim", "", "simulate a bad network on the server connection and -clmov/-pcap playback, e.g. latency=150ms,jitter=40ms,loss=5%,reorder=1%,dup=1%,seed=1")
 	flag.BoolVar(&doDebug, "debug", false, "verbose/debug logging")
 	flag.BoolVar(&eui.CacheCheck, "cacheCheck", false, "display window and item render counts")
 	flag.BoolVar(&dumpMusic, "dumpMusic", false, "write played music as a .wav file")
@@ -84,6 +89,20 @@ func main() {
 	flag.BoolVar(&measureLoads, "measure", false, "report asset load times and metadata (sounds/images)")
 	genPGO := flag.Bool("pgo", false, "create default.pgo using test.clMov at 30 fps for 30s")
 	verifyPath := flag.String("verifyClmov", "", "verify a .clMov file by re-encoding and comparing")
+	clmovOut := flag.String("clmovOut", "", "write the -clmov movie, edited by -clmovTrim/-clmovDrop/-clmovConcat, to this file and exit")
+	clmovTrim := flag.String("clmovTrim", "", "keep only this frame range of the movie, e.g. 1500-3000 or 1h2m-1h7m")
+	clmovDrop := flag.String("clmovDrop", "", "comma separated frames or ranges to drop, e.g. 10-20,35")
+	clmovConcat := flag.String("clmovConcat", "", "comma separated movies to append to -clmov")
+	clmovZOut := flag.String("clmovZ", "", "write the -clmov movie as a .clMovZ container with seek keyframes and exit")
+	clmovTags := flag.String("clmovTags", "", "comma separated tags stored by -clmovZ")
+	clmovExport := flag.String("clmovExport", "", "write the -clmov movie (e.g. a .clMovZ) as a classic .clMov and exit")
+	clmovDump := flag.String("clmovDump", "", "write a per-frame timeline of the -clmov movie to this file (- for stdout) and exit")
+	dumpFormat := flag.String("format", "", "format for -clmovDump: json or csv (default from the file extension)")
+	exportDir := flag.String("exportVideo", "", "render the -clmov movie to PNG frames and audio.wav in the given directory and exit (opens a minimized window)")
+	exportFPS := flag.Int("exportFPS", defaultExportFPS, "frame rate for -exportVideo")
+	exportScale := flag.Int("exportScale", 1, "integer render scale for -exportVideo")
+	testScript := flag.String("testScript", "", "run a script against a JSON scenario without connecting and exit")
+	scenario := flag.String("scenario", "", "scenario for -testScript (default <script>.test.json)")
 	flag.Parse()
 
 	// Classic timing and parser are always enabled; flags removed.
@@ -122,6 +141,137 @@ func main() {
 		return
 	}
 
+	if *clmovOut != "" {
+		if clmov == "" {
+			log.Fatalf("clmovOut: -clmov is required")
+		}
+		inputs := []string{clmov}
+		for _, p := range strings.Split(*clmovConcat, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				inputs = append(inputs, p)
+			}
+		}
+		keep, err := clmovKeep(*clmovTrim, *clmovDrop)
+		if err != nil {
+			log.Fatalf("clmovOut: %v", err)
+		}
+		n, err := editClmov(*clmovOut, inputs, keep)
+		if err != nil {
+			log.Fatalf("clmovOut: %v", err)
+		}
+		if err := verifyClmov(*clmovOut, clVersion); err != nil {
+			log.Fatalf("clmovOut: verify: %v", err)
+		}
+		log.Printf("clmovOut: wrote %d frames to %s", n, *clmovOut)
+		return
+	}
+
+	if *clmovZOut != "" || *clmovExport != "" {
+		if clmov == "" {
+			log.Fatalf("clmovZ: -clmov is required")
+		}
+		if *clmovZOut != "" {
+			meta := clmovMeta{Tags: parseTags(*clmovTags)}
+			if err := convertToClmovZ(clmov, *clmovZOut, meta, true); err != nil {
+				log.Fatalf("clmovZ: %v", err)
+			}
+			log.Printf("clmovZ: wrote %s", *clmovZOut)
+		}
+		if *clmovExport != "" {
+			if err := exportClmovZ(clmov, *clmovExport); err != nil {
+				log.Fatalf("clmovExport: %v", err)
+			}
+			log.Printf("clmovExport: wrote %s", *clmovExport)
+		}
+		return
+	}
+
+	if *netSimSpec != "" {
+		cfg, err := parseNetSim(*netSimSpec)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		// -fake writes the draw state directly, with no messages to delay.
+		if fake && clmov == "" && pcapPath == "" {
+			log.Fatalf("netsim: cannot be used with -fake")
+		}
+		setNetSim(cfg)
+		log.Printf("simulating network: %v", cfg)
+	}
+
+	var pcapFilt pcapFilter
+	if pcapPath != "" {
+		var err error
+		if pcapFilt, err = parsePCAPFilter(*pcapServer); err != nil {
+			log.Fatalf("pcap: %v", err)
+		}
+	}
+
+	if *pcapOut != "" {
+		if pcapPath == "" {
+			log.Fatalf("pcapOut: -pcap is required")
+		}
+		n, err := pcapToClmov(pcapPath, *pcapOut, pcapFilt)
+		if err != nil {
+			log.Fatalf("pcapOut: %v", err)
+		}
+		if err := verifyClmov(*pcapOut, clVersion); err != nil {
+			log.Fatalf("pcapOut: verify: %v", err)
+		}
+		log.Printf("pcapOut: wrote %d frames to %s", n, *pcapOut)
+		return
+	}
+
+	if *clmovDump != "" {
+		if clmov == "" {
+			log.Fatalf("clmovDump: -clmov is required")
+		}
+		if err := dumpClmov(clmov, *clmovDump, *dumpFormat); err != nil {
+			log.Fatalf("clmovDump: %v", err)
+		}
+		return
+	}
+
+	if *testScript != "" {
+		setupLogging(doDebug)
+		// Keep script storage and logs out of the real data directory.
+		dir, err := os.MkdirTemp("", "gothoom-testscript")
+		if err != nil {
+			log.Fatalf("testScript: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		dataDirPath = dir
+		path := *scenario
+		if path == "" {
+			path = scriptScenarioPath(*testScript)
+		}
+		if err := runScriptTest(*testScript, path); err != nil {
+			fmt.Println("FAIL:", err)
+			os.RemoveAll(dir)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *exportDir != "" {
+		if clmov == "" {
+			log.Fatalf("exportVideo: -clmov is required")
+		}
+		loadSettings()
+		initSoundContext()
+		applySettings()
+		setupLogging(doDebug)
+		if err := loadExportAssets(); err != nil {
+			log.Fatalf("exportVideo: %v", err)
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), shutdownSignals()...)
+		defer cancel()
+		if err := exportVideo(ctx, clmov, *exportDir, *exportFPS, *exportScale); err != nil {
+			log.Fatalf("exportVideo: %v", err)
+		}
+		return
+	}
+
 	if *genPGO {
 		clmov = filepath.Join("clmovFiles", "test.clMov.zip")
 	}
@@ -154,6 +304,20 @@ func main() {
 	if clmov != "" {
 		clmovPath = clmov
 	}
+	if pcapPath != "" && clmovPath == "" {
+		// Captures play through the movie player so they can be paused,
+		// seeked and sped up like any movie.
+		tmp, err := os.CreateTemp("", "gothoom-pcap-*.clMov")
+		if err != nil {
+			log.Fatalf("pcap: %v", err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if _, err := pcapToClmov(pcapPath, tmp.Name(), pcapFilt); err != nil {
+			log.Fatalf("pcap: %v", err)
+		}
+		clmovPath = tmp.Name()
+	}
 
 	loadStats()
 	defer saveStats()
@@ -254,12 +418,20 @@ func main() {
 			applyEnabledScripts()
 
 			mp := newMoviePlayer(frames, clMovFPS, cancel)
+			if clmov == "" && pcapPath != "" {
+				mp.source = "pcap"
+			}
+			mp.loadNotes(clmovPath)
+			mp.addKeyframes(loadMovieKeyframes(clmovPath))
 			if isWASM {
 				mp.repeat = true
 				gs.PowerSaveAlways = false
 				gs.PowerSaveBackground = false
 			}
 			mp.makePlaybackWindow()
+			if *speed > 0 && *speed != 1 {
+				mp.setFPS(int(float64(clMovFPS)*(*speed) + 0.5))
+			}
 
 			if (gs.precacheSounds || gs.precacheImages) && !assetsPrecached {
 				for !assetsPrecached {
@@ -272,24 +444,6 @@ func main() {
 			return
 		}
 
-		if pcapPath != "" {
-			drawStateEncrypted = false
-			if (gs.precacheSounds || gs.precacheImages) && !assetsPrecached {
-				for !assetsPrecached {
-					time.Sleep(time.Millisecond * 100)
-				}
-			}
-			go func() {
-				if err := replayPCAP(ctx, pcapPath); err != nil {
-					log.Printf("replay PCAP: %v", err)
-				} else {
-					log.Print("PCAP replay complete")
-				}
-			}()
-			<-ctx.Done()
-			return
-		}
-
 		if fake {
 			drawStateEncrypted = false
 			if (gs.precacheSounds || gs.precacheImages) && !assetsPrecached {
@@ -22,6 +22,10 @@ const oldestMovieVersion = 193
 
 var movieRevision int32
 
+// movieVersion is the client version of the last parsed movie, after the
+// Arindal adjustment.
+var movieVersion uint16
+
 type movieFrame struct {
 	data    []byte
 	index   int32
@@ -50,6 +54,14 @@ func parseMovieZipBytes(zipData []byte, clVersion int) ([]movieFrame, error) {
 }
 
 func loadMovieData(path string) ([]byte, error) {
+	if isClmovZ(path) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		classic, _, _, err := decodeClmovZ(data)
+		return classic, err
+	}
 	if strings.HasSuffix(strings.ToLower(path), ".zip") {
 		zr, err := zip.OpenReader(path)
 		if err != nil {
@@ -100,6 +112,7 @@ func parseMovieData(data []byte, clVersion int) ([]movieFrame, error) {
 	if version < oldestMovieVersion {
 		return nil, fmt.Errorf("movie version too old: %d", version)
 	}
+	movieVersion = version
 	headerLen := int(binary.BigEndian.Uint16(data[6:8]))
 	if headerLen <= 0 || headerLen > len(data) {
 		headerLen = 24
@@ -149,30 +162,11 @@ func parseMovieData(data []byte, clVersion int) ([]movieFrame, error) {
 			}
 		}
 		if flags&flagPictureTable != 0 {
-			if pos+2 <= len(data) {
-				count := int(binary.BigEndian.Uint16(data[pos : pos+2]))
-				size := 2 + 6*count + 4
-				if count >= 0 && size >= 0 && pos+size <= len(data) && count <= 8192 {
-					pos += 2
-					pics := make([]framePicture, 0, count)
-					for i := 0; i < count && pos+6 <= len(data); i++ {
-						id := binary.BigEndian.Uint16(data[pos : pos+2])
-						h := int16(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
-						v := int16(binary.BigEndian.Uint16(data[pos+4 : pos+6]))
-						plane := 0
-						if clImages != nil {
-							plane = clImages.Plane(uint32(id))
-						}
-						pos += 6
-						pics = append(pics, framePicture{PictID: id, H: h, V: v, Plane: plane})
-					}
-					if pos+4 <= len(data) {
-						pos += 4
-					}
-					stateMu.Lock()
-					state.pictures = pics
-					stateMu.Unlock()
-				}
+			if pics, next, ok := parsePictureTable(data, pos); ok {
+				pos = next
+				stateMu.Lock()
+				state.pictures = pics
+				stateMu.Unlock()
 			}
 		}
 		preData := append([]byte(nil), data[preStart:pos]...)
@@ -197,6 +191,64 @@ func parseMovieData(data []byte, clVersion int) ([]movieFrame, error) {
 	return frames, nil
 }
 
+// parsePictureTable decodes a PictureTable block at pos and returns the
+// pictures and the position after the block.
+func parsePictureTable(data []byte, pos int) ([]framePicture, int, bool) {
+	if pos+2 > len(data) {
+		return nil, pos, false
+	}
+	count := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	size := 2 + 6*count + 4
+	if pos+size > len(data) || count > 8192 {
+		return nil, pos, false
+	}
+	pos += 2
+	pics := make([]framePicture, 0, count)
+	for i := 0; i < count; i++ {
+		id := binary.BigEndian.Uint16(data[pos : pos+2])
+		h := int16(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		v := int16(binary.BigEndian.Uint16(data[pos+4 : pos+6]))
+		plane := 0
+		if clImages != nil {
+			plane = clImages.Plane(uint32(id))
+		}
+		pos += 6
+		pics = append(pics, framePicture{PictID: id, H: h, V: v, Plane: plane})
+	}
+	return pics, pos + 4, true
+}
+
+// applyMovieBlocks applies the MobileData and PictureTable blocks of a
+// block-only frame during playback. Such frames mark a login or a cut in
+// an edited movie, so the following draw states depend on them. GameState
+// blocks are skipped; they only carry text already shown at load.
+func applyMovieBlocks(m movieFrame) {
+	if len(m.data) != 0 || len(m.preData) == 0 {
+		return
+	}
+	data := m.preData
+	pos := 0
+	if m.flags&flagGameState != 0 {
+		if len(data) < 24 {
+			return
+		}
+		pos = 24 + int(binary.BigEndian.Uint32(data[12:16]))
+		if pos > len(data) {
+			return
+		}
+	}
+	if m.flags&flagMobileData != 0 {
+		pos = parseMobileTable(data, pos, movieVersion, uint16(movieRevision))
+	}
+	if m.flags&flagPictureTable != 0 {
+		if pics, _, ok := parsePictureTable(data, pos); ok {
+			stateMu.Lock()
+			state.pictures = pics
+			stateMu.Unlock()
+		}
+	}
+}
+
 // parseGameState decodes an initial game state block found in movies. The
 // payload mirrors the data sent by the server after login and may embed
 // descriptor and picture tables. The decoding here is intentionally
@@ -248,38 +300,76 @@ func parseGameState(gs []byte, version, revision uint16) {
 	}
 }
 
-// parseMobileTable decodes the descriptor table for a frame.  Descriptor
-// layouts have changed many times over Clan Lord's long history; the version
-// checks below mirror the Mac client's ReadMobileTable/Read1Descriptor logic.
-// Version breakpoints correspond to kOldestMovieVersion and friends in the
-// original source.
-func parseMobileTable(data []byte, pos int, version, revision uint16) int {
-	const descTableSize = 266 // kDescTableSize
+const descTableSize = 266 // kDescTableSize
 
-	type layout struct {
-		descSize            int
-		colorsOffset        int
-		nameOffset          int
-		numColorsOffset     int
-		bubbleCounterOffset int
-	}
+// mobileLayout holds the descriptor field offsets for one movie version.
+type mobileLayout struct {
+	descSize            int
+	colorsOffset        int
+	nameOffset          int
+	numColorsOffset     int
+	bubbleCounterOffset int
+}
 
-	var l layout
+func mobileTableLayout(version uint16) (mobileLayout, bool) {
 	switch {
 	case version > 141: // v142+ (current format)
-		l = layout{descSize: 156, colorsOffset: 56, nameOffset: 86, numColorsOffset: 48, bubbleCounterOffset: 28}
+		return mobileLayout{descSize: 156, colorsOffset: 56, nameOffset: 86, numColorsOffset: 48, bubbleCounterOffset: 28}, true
 	case version > 113: // v114-141
-		l = layout{descSize: 150, colorsOffset: 52, nameOffset: 82, numColorsOffset: 44, bubbleCounterOffset: 24}
+		return mobileLayout{descSize: 150, colorsOffset: 52, nameOffset: 82, numColorsOffset: 44, bubbleCounterOffset: 24}, true
 	case version > 105: // v106-113
-		l = layout{descSize: 142, colorsOffset: 52, nameOffset: 82, numColorsOffset: 44, bubbleCounterOffset: 24}
+		return mobileLayout{descSize: 142, colorsOffset: 52, nameOffset: 82, numColorsOffset: 44, bubbleCounterOffset: 24}, true
 	case version > 97: // v98-105
-		l = layout{descSize: 130, colorsOffset: 40, nameOffset: 70, numColorsOffset: 32, bubbleCounterOffset: 24}
-	default: // v80-97
-		if version < 80 {
-			logDebug("unsupported mobile table version %d", version)
-			return pos
-		}
-		l = layout{descSize: 126, colorsOffset: 36, nameOffset: 66, numColorsOffset: 28, bubbleCounterOffset: 20}
+		return mobileLayout{descSize: 130, colorsOffset: 40, nameOffset: 70, numColorsOffset: 32, bubbleCounterOffset: 24}, true
+	case version >= 80: // v80-97
+		return mobileLayout{descSize: 126, colorsOffset: 36, nameOffset: 66, numColorsOffset: 28, bubbleCounterOffset: 20}, true
+	}
+	return mobileLayout{}, false
+}
+
+// parseMobileTable decodes the descriptor table for a frame and applies it
+// to state and the Players list.
+func parseMobileTable(data []byte, pos int, version, revision uint16) int {
+	if _, ok := mobileTableLayout(version); !ok {
+		logDebug("unsupported mobile table version %d", version)
+		return pos
+	}
+	descs, mobs, pos := decodeMobileTable(data, pos, version)
+
+	stateMu.Lock()
+	if state.mobiles == nil {
+		state.mobiles = make(map[uint8]frameMobile)
+	}
+	for _, m := range mobs {
+		state.mobiles[m.Index] = m
+	}
+	if state.descriptors == nil {
+		state.descriptors = make(map[uint8]frameDescriptor)
+	}
+	for _, d := range descs {
+		state.descriptors[d.Index] = d
+	}
+	stateMu.Unlock()
+
+	// Update the Players list appearance immediately from descriptor data,
+	// mirroring live behavior so movies show avatars right away.
+	for _, d := range descs {
+		updatePlayerAppearance(d.Name, d.PictID, d.Colors, d.Type == kDescNPC)
+		queueInfoRequest(d.Name)
+	}
+	return pos
+}
+
+// decodeMobileTable reads the descriptor table at pos without applying it.
+// mobs holds the entries that also carried a mobile. Descriptor layouts have
+// changed many times over Clan Lord's long history; the version checks in
+// mobileTableLayout mirror the Mac client's ReadMobileTable/Read1Descriptor
+// logic. Version breakpoints correspond to kOldestMovieVersion and friends in
+// the original source.
+func decodeMobileTable(data []byte, pos int, version uint16) (descs []frameDescriptor, mobs []frameMobile, end int) {
+	l, ok := mobileTableLayout(version)
+	if !ok {
+		return nil, nil, pos
 	}
 
 	for pos+4 <= len(data) {
@@ -296,7 +386,7 @@ func parseMobileTable(data []byte, pos int, version, revision uint16) int {
 		var mob frameMobile
 		if hasMobile {
 			if pos+16 > len(data) {
-				return len(data)
+				return descs, mobs, len(data)
 			}
 			mob.Index = uint8(idx)
 			mob.State = uint8(binary.BigEndian.Uint32(data[pos : pos+4]))
@@ -307,7 +397,7 @@ func parseMobileTable(data []byte, pos int, version, revision uint16) int {
 		}
 
 		if pos+l.descSize > len(data) {
-			return len(data)
+			return descs, mobs, len(data)
 		}
 		buf := data[pos : pos+l.descSize]
 		pos += l.descSize
@@ -341,34 +431,21 @@ func parseMobileTable(data []byte, pos int, version, revision uint16) int {
 		bubbleCounter := int32(binary.BigEndian.Uint32(buf[l.bubbleCounterOffset : l.bubbleCounterOffset+4]))
 		if bubbleCounter != 0 {
 			if pos+2 > len(data) {
-				return len(data)
+				return descs, mobs, len(data)
 			}
 			lgt := int(binary.BigEndian.Uint16(data[pos : pos+2]))
 			pos += 2
 			if pos+lgt > len(data) {
-				return len(data)
+				return descs, mobs, len(data)
 			}
 			_ = string(data[pos : pos+lgt]) // bubble text, ignored
 			pos += lgt
 		}
 
-		stateMu.Lock()
 		if hasMobile {
-			if state.mobiles == nil {
-				state.mobiles = make(map[uint8]frameMobile)
-			}
-			state.mobiles[mob.Index] = mob
-		}
-		if state.descriptors == nil {
-			state.descriptors = make(map[uint8]frameDescriptor)
+			mobs = append(mobs, mob)
 		}
-		state.descriptors[d.Index] = d
-		stateMu.Unlock()
-
-		// Update the Players list appearance immediately from descriptor data,
-		// mirroring live behavior so movies show avatars right away.
-		updatePlayerAppearance(d.Name, d.PictID, d.Colors, d.Type == kDescNPC)
-		queueInfoRequest(d.Name)
+		descs = append(descs, d)
 	}
-	return pos
+	return descs, mobs, pos
 }
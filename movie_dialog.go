@@ -11,7 +11,7 @@ import (
 var errMovieDialogCancelled = errors.New("movie dialog cancelled")
 
 func pickMovieFile() (string, error) {
-	filename, err := dialog.File().Filter("clMov files", "clMov", "clmov", "zip", "ZIP").Load()
+	filename, err := dialog.File().Filter("clMov files", "clMov", "clmov", "clMovZ", "clmovz", "zip", "ZIP").Load()
 	if err != nil {
 		if err == dialog.Cancelled {
 			return "", errMovieDialogCancelled
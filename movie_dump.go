@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// movieDumpRecord is the -clmovDump record for one movie frame.
+type movieDumpRecord struct {
+	Frame      int               `json:"frame"` // position in the movie
+	Index      int32             `json:"index"` // server frame number
+	Time       float64           `json:"time"`  // seconds from the start
+	HP         int               `json:"hp"`
+	HPMax      int               `json:"hp_max"`
+	SP         int               `json:"sp"`
+	SPMax      int               `json:"sp_max"`
+	Balance    int               `json:"balance"`
+	BalanceMax int               `json:"balance_max"`
+	Mobiles    []movieDumpMobile `json:"mobiles"`
+	Bubbles    []movieDumpBubble `json:"bubbles,omitempty"`
+	Info       []movieDumpInfo   `json:"info,omitempty"`
+}
+
+type movieDumpMobile struct {
+	Index uint8  `json:"index"`
+	Name  string `json:"name"`
+	H     int16  `json:"h"`
+	V     int16  `json:"v"`
+	State uint8  `json:"state"`
+}
+
+type movieDumpBubble struct {
+	Speaker string `json:"speaker"`
+	Verb    string `json:"verb"`
+	Text    string `json:"text"`
+	Lang    string `json:"lang,omitempty"`
+}
+
+type movieDumpInfo struct {
+	Kind string `json:"kind"` // info, think, fallen or share
+	Text string `json:"text"`
+}
+
+// movieDumpCSVHeader lists the CSV columns. CSV output is in long form:
+// a "frame" row with the vitals, then one row per mobile, bubble and info
+// line, all carrying the frame and time so they can be grouped.
+var movieDumpCSVHeader = []string{
+	"frame", "index", "time", "kind",
+	"hp", "hp_max", "sp", "sp_max", "balance", "balance_max",
+	"mobile", "name", "h", "v", "state",
+	"verb", "lang", "text",
+}
+
+// dumpClmov writes a per-frame timeline of the movie at moviePath to out
+// ("-" for stdout). format is json or csv; when empty it follows the
+// extension of out.
+func dumpClmov(moviePath, out, format string) error {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = "json"
+		if strings.EqualFold(filepath.Ext(out), ".csv") {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	drawStateEncrypted = false
+	frames, err := parseMovie(moviePath, clVersion)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if format == "csv" {
+		err = writeMovieDumpCSV(bw, frames)
+	} else {
+		err = writeMovieDumpJSON(bw, frames)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// movieDump replays frames into a private drawState and calls fn with the
+// record of every frame that carries game data.
+func movieDump(frames []movieFrame, fn func(movieDumpRecord) error) error {
+	s := cloneDrawState(initialState)
+	var last int32
+	for i, m := range frames {
+		applyMovieBlocksTo(&s, m)
+		if len(m.data) == 0 {
+			continue
+		}
+		rec := movieDumpRecord{Frame: i, Index: m.index, Time: float64(i) / float64(clMovFPS)}
+		if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
+			dropped := 0
+			if last != 0 && m.index > last+1 {
+				dropped = int(m.index - last - 1)
+			}
+			if last == 0 || m.index > last {
+				last = m.index
+			}
+			if err := parseDrawStateInto(&s, m.data[2:], dropped); err != nil {
+				logDebug("clmovDump frame %d: %v", i, err)
+			}
+			names := map[uint8]string{}
+			for idx, d := range s.descriptors {
+				names[idx] = d.Name
+			}
+			info, bubbles := drawStateText(m.data[2:], names)
+			rec.Info = movieDumpInfoLines(info)
+			for _, b := range bubbles {
+				verb, txt, name, lang, _, _, _ := decodeBubble(b)
+				if txt == "" {
+					continue
+				}
+				if name == "" {
+					name = names[b[0]]
+				}
+				rec.Bubbles = append(rec.Bubbles, movieDumpBubble{Speaker: name, Verb: verb, Text: txt, Lang: lang})
+			}
+		} else if len(m.data) > 16 {
+			rec.Info = movieDumpInfoLines(m.data[16:])
+		}
+		rec.HP, rec.HPMax = s.hp, s.hpMax
+		rec.SP, rec.SPMax = s.sp, s.spMax
+		rec.Balance, rec.BalanceMax = s.balance, s.balanceMax
+		rec.Mobiles = make([]movieDumpMobile, 0, len(s.mobiles))
+		for idx, mob := range s.mobiles {
+			rec.Mobiles = append(rec.Mobiles, movieDumpMobile{
+				Index: idx,
+				Name:  s.descriptors[idx].Name,
+				H:     mob.H,
+				V:     mob.V,
+				State: mob.State,
+			})
+		}
+		sort.Slice(rec.Mobiles, func(i, j int) bool { return rec.Mobiles[i].Index < rec.Mobiles[j].Index })
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func movieDumpInfoLines(info []byte) []movieDumpInfo {
+	var out []movieDumpInfo
+	for _, line := range bytes.Split(info, []byte{'\r'}) {
+		if kind, txt := movieInfoText(line); txt != "" {
+			out = append(out, movieDumpInfo{Kind: kind, Text: txt})
+		}
+	}
+	return out
+}
+
+// writeMovieDumpJSON writes the records as one JSON array, a record per
+// line.
+func writeMovieDumpJSON(w io.Writer, frames []movieFrame) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	sep := "\n"
+	err := movieDump(frames, func(rec movieDumpRecord) error {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		sep = ",\n"
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+func writeMovieDumpCSV(w io.Writer, frames []movieFrame) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(movieDumpCSVHeader); err != nil {
+		return err
+	}
+	itoa := strconv.Itoa
+	err := movieDump(frames, func(rec movieDumpRecord) error {
+		row := func(kind string) []string {
+			r := make([]string, len(movieDumpCSVHeader))
+			r[0] = itoa(rec.Frame)
+			r[1] = strconv.FormatInt(int64(rec.Index), 10)
+			r[2] = strconv.FormatFloat(rec.Time, 'f', -1, 64)
+			r[3] = kind
+			return r
+		}
+		r := row("frame")
+		r[4], r[5], r[6], r[7], r[8], r[9] = itoa(rec.HP), itoa(rec.HPMax), itoa(rec.SP), itoa(rec.SPMax), itoa(rec.Balance), itoa(rec.BalanceMax)
+		if err := cw.Write(r); err != nil {
+			return err
+		}
+		for _, m := range rec.Mobiles {
+			r := row("mobile")
+			r[10], r[11], r[12], r[13], r[14] = itoa(int(m.Index)), m.Name, itoa(int(m.H)), itoa(int(m.V)), itoa(int(m.State))
+			if err := cw.Write(r); err != nil {
+				return err
+			}
+		}
+		for _, b := range rec.Bubbles {
+			r := row("bubble")
+			r[11], r[15], r[16], r[17] = b.Speaker, b.Verb, b.Lang, b.Text
+			if err := cw.Write(r); err != nil {
+				return err
+			}
+		}
+		for _, in := range rec.Info {
+			r := row(in.Kind)
+			r[17] = in.Text
+			if err := cw.Write(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
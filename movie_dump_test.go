@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+)
+
+func TestMovieDump(t *testing.T) {
+	stateMu.Lock()
+	initialState = drawState{}
+	stateMu.Unlock()
+
+	ds := []byte{0, 2, 0, 0, 0, 0, 7, 0, 0, 0, 0} // tag, ack and resend
+	ds = append(ds, 1, 5, 0, 0, 1)                // one descriptor, index 5
+	ds = append(ds, "Bob\x00"...)
+	ds = append(ds, 0)                       // colors
+	ds = append(ds, 30, 100, 4, 10, 0, 0, 0) // stats
+	ds = append(ds, 0)                       // pictures
+	ds = append(ds, 1, 5, 2, 0, 10, 0xff, 0xfd, 0)
+	st := []byte("\xC2hfBob has fallen\x00")
+	st = append(st, 1, 5, kBubbleNormal)
+	st = append(st, "heal me\x00"...)
+	ds = append(ds, byte(len(st)>>8), byte(len(st)))
+	ds = append(ds, st...)
+	frames := []movieFrame{{index: 7, data: ds}}
+
+	var js bytes.Buffer
+	if err := writeMovieDumpJSON(&js, frames); err != nil {
+		t.Fatalf("writeMovieDumpJSON: %v", err)
+	}
+	var recs []movieDumpRecord
+	if err := json.Unmarshal(js.Bytes(), &recs); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, js.Bytes())
+	}
+	if len(recs) != 1 {
+		t.Fatalf("records %+v", recs)
+	}
+	r := recs[0]
+	if r.Index != 7 || r.HP != 30 || r.SPMax != 10 {
+		t.Fatalf("record %+v", r)
+	}
+	if len(r.Mobiles) != 1 || r.Mobiles[0] != (movieDumpMobile{Index: 5, Name: "Bob", H: 10, V: -3, State: 2}) {
+		t.Fatalf("mobiles %+v", r.Mobiles)
+	}
+	if len(r.Bubbles) != 1 || r.Bubbles[0].Speaker != "Bob" || r.Bubbles[0].Verb != "says" || r.Bubbles[0].Text != "heal me" {
+		t.Fatalf("bubbles %+v", r.Bubbles)
+	}
+	if len(r.Info) != 1 || r.Info[0] != (movieDumpInfo{Kind: "fallen", Text: "Bob has fallen"}) {
+		t.Fatalf("info %+v", r.Info)
+	}
+
+	var cs bytes.Buffer
+	if err := writeMovieDumpCSV(&cs, frames); err != nil {
+		t.Fatalf("writeMovieDumpCSV: %v", err)
+	}
+	rows, err := csv.NewReader(&cs).ReadAll()
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	// header, frame, mobile, bubble, fallen
+	if len(rows) != 5 || rows[1][3] != "frame" || rows[1][4] != "30" || rows[2][11] != "Bob" || rows[3][17] != "heal me" || rows[4][3] != "fallen" {
+		t.Fatalf("rows %q", rows)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gothoom/eui"
+)
+
+// maxMovieEventRows caps the rows shown at once; searching narrows the list.
+const maxMovieEventRows = 300
+
+// makeMovieEventsWindow opens a searchable list of the movie's chat and
+// event lines. Clicking a line seeks to its frame.
+func (p *moviePlayer) makeMovieEventsWindow() {
+	if p.eventsWin != nil {
+		p.eventsWin.MarkOpen()
+		return
+	}
+	win := eui.NewWindow()
+	win.Title = "Movie Events"
+	win.Size = eui.Point{X: 520, Y: 360}
+	win.Closable = true
+	win.Movable = true
+	win.Resizable = true
+	win.NoScroll = true
+	win.Searchable = true
+	win.SetZone(eui.HZoneRight, eui.VZoneMiddleTop)
+
+	flow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Fixed: true}
+	win.AddItem(flow)
+	p.eventsList = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true, Fixed: true}
+	flow.AddItem(p.eventsList)
+
+	win.OnSearch = func(s string) { p.refreshMovieEvents(s) }
+	win.OnResize = func() { p.refreshMovieEvents(win.SearchText) }
+	p.eventsWin = win
+	win.AddWindow(false)
+	p.refreshMovieEvents("")
+	win.MarkOpen()
+}
+
+func (p *moviePlayer) refreshMovieEvents(query string) {
+	if p.eventsWin == nil || p.eventsList == nil {
+		return
+	}
+	win := p.eventsWin
+	pad := (win.Padding + win.BorderPad) * eui.UIScale()
+	p.eventsList.Size = eui.Point{
+		X: win.GetSize().X - 2*pad,
+		Y: win.GetSize().Y - win.GetTitleSize() - 2*pad,
+	}
+	width := p.eventsList.Size.X - eui.ScrollbarWidth()
+	p.eventsList.Contents = p.eventsList.Contents[:0]
+
+	matches := searchMovieIndex(p.events, query)
+	for i, ev := range matches {
+		if i == maxMovieEventRows {
+			more, _ := eui.NewText()
+			more.Text = fmt.Sprintf("%d more; refine the search", len(matches)-i)
+			more.Size = eui.Point{X: width, Y: 20}
+			more.FontSize = 10
+			p.eventsList.AddItem(more)
+			break
+		}
+		frame := ev.frame
+		btn, events := eui.NewButton()
+		btn.Text = fmt.Sprintf("%s  [%s] %s", movieTimeLabel(frame, p.baseFPS), ev.kind, ev.text)
+		btn.Size = eui.Point{X: width, Y: 20}
+		btn.FontSize = 10
+		events.Handle = func(e eui.UIEvent) {
+			if e.Type == eui.EventClick && !seekingMov {
+				seekLock.Lock()
+				go func() {
+					p.seek(frame)
+					seekLock.Unlock()
+				}()
+			}
+		}
+		p.eventsList.AddItem(btn)
+	}
+	if len(matches) == 0 {
+		none, _ := eui.NewText()
+		none.Text = "No matching lines"
+		none.Size = eui.Point{X: width, Y: 20}
+		none.FontSize = 10
+		p.eventsList.AddItem(none)
+	}
+	p.eventsWin.Refresh()
+}
+
+// movieTimeLabel formats a frame index as h:mm:ss playback time.
+func movieTimeLabel(frame, fps int) string {
+	d := time.Duration(frame) * time.Second / time.Duration(fps)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
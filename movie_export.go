@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"gothoom/climg"
+	"gothoom/clsnd"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Default output rate for -exportVideo.
+const defaultExportFPS = 30
+
+// videoAudio captures game sounds and music while a movie is being exported.
+// When non-nil, playSound and enqueueTune mix into it instead of playing.
+var videoAudio *exportAudio
+
+// exportAudio accumulates a stereo mix at sampleRate. Sounds are placed at
+// the current export position; tunes play back to back like the tune worker.
+// Nothing is mixed before the position any more, so samples behind it are
+// moved to a spill file as float32 pairs instead of being kept for the
+// whole movie; writeWAV normalizes them from there.
+type exportAudio struct {
+	mu       sync.Mutex
+	pos      time.Duration
+	musicEnd time.Duration
+	base     int // sample index of left[0]
+	left     []float32
+	right    []float32
+	peak     float32
+	spillF   *os.File
+	spillW   *bufio.Writer
+	err      error
+}
+
+// exportAudioChunk is how many finished samples exportAudio holds before
+// spilling them.
+const exportAudioChunk = 10 * sampleRate
+
+func samplesAt(d time.Duration) int {
+	return int(d * sampleRate / time.Second)
+}
+
+func (a *exportAudio) setPos(d time.Duration) {
+	a.mu.Lock()
+	a.pos = d
+	if n := samplesAt(d); n-a.base >= exportAudioChunk {
+		a.spill(n)
+	}
+	a.mu.Unlock()
+}
+
+// mix adds src starting at sample offset off, growing the buffers as needed.
+// Call with a.mu held.
+func (a *exportAudio) mix(off int, left, right []float32, gain float32) {
+	if off < a.base {
+		// Already spilled; only happens to the part of a tune before pos.
+		skip := a.base - off
+		if skip >= len(left) {
+			return
+		}
+		left, right, off = left[skip:], right[skip:], a.base
+	}
+	off -= a.base
+	if end := off + len(left); end > len(a.left) {
+		a.left = append(a.left, make([]float32, end-len(a.left))...)
+		a.right = append(a.right, make([]float32, end-len(a.right))...)
+	}
+	for i := range left {
+		a.left[off+i] += left[i] * gain
+		a.right[off+i] += right[i] * gain
+	}
+}
+
+// spill moves the samples before sample n to the spill file, as silence
+// where nothing was mixed. Call with a.mu held.
+func (a *exportAudio) spill(n int) {
+	if n <= a.base || a.err != nil {
+		return
+	}
+	if a.spillF == nil {
+		if a.spillF, a.err = os.CreateTemp("", "gothoom-audio-*.raw"); a.err != nil {
+			return
+		}
+		a.spillW = bufio.NewWriter(a.spillF)
+	}
+	var buf [8]byte
+	for i := 0; i < n-a.base; i++ {
+		var l, r float32
+		if i < len(a.left) {
+			l, r = a.left[i], a.right[i]
+		}
+		a.peak = max(a.peak, float32(math.Abs(float64(l))), float32(math.Abs(float64(r))))
+		binary.LittleEndian.PutUint32(buf[0:], math.Float32bits(l))
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(r))
+		if _, err := a.spillW.Write(buf[:]); err != nil {
+			a.err = err
+			return
+		}
+	}
+	k := min(n-a.base, len(a.left))
+	a.left = append(a.left[:0], a.left[k:]...)
+	a.right = append(a.right[:0], a.right[k:]...)
+	a.base = n
+}
+
+// discard removes the spill file.
+func (a *exportAudio) discard() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.spillF != nil {
+		a.spillF.Close()
+		os.Remove(a.spillF.Name())
+		a.spillF, a.spillW = nil, nil
+	}
+	a.left, a.right = nil, nil
+}
+
+// addSounds mixes the given sound IDs at the current position, matching the
+// per-event normalization used by playSound.
+func (a *exportAudio) addSounds(ids []uint16) {
+	if len(ids) == 0 || !gs.GameSound {
+		return
+	}
+	var mono [][]byte
+	maxSamples := 0
+	for _, id := range ids {
+		pcm := loadSound(id)
+		if pcm == nil {
+			continue
+		}
+		mono = append(mono, pcm)
+		if n := len(pcm) / 2; n > maxSamples {
+			maxSamples = n
+		}
+	}
+	if len(mono) == 0 {
+		return
+	}
+	mixed := make([]int32, maxSamples)
+	for _, pcm := range mono {
+		for i := 0; i < len(pcm)/2; i++ {
+			mixed[i] += int32(int16(uint16(pcm[2*i]) | uint16(pcm[2*i+1])<<8))
+		}
+	}
+	if gs.SoundEnhancement {
+		applyGameSoundReverb(mixed)
+	}
+	buf := make([]float32, maxSamples)
+	for i, v := range mixed {
+		buf[i] = float32(v) / 32768
+	}
+	gain := float32(gs.GameVolume) / float32(len(mono))
+
+	a.mu.Lock()
+	a.mix(samplesAt(a.pos), buf, buf, gain)
+	a.mu.Unlock()
+}
+
+// addTune renders job and places it after any tune still playing.
+func (a *exportAudio) addTune(job tuneJob) {
+	left, right, err := renderSong(job.program, job.notes)
+	if err != nil {
+		log.Printf("export tune: %v", err)
+		return
+	}
+	if gs.MusicEnhancement {
+		applyMusicReverb(left, right, sampleRate)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	start := a.pos
+	if a.musicEnd > start {
+		start = a.musicEnd
+	}
+	a.mix(samplesAt(start), left, right, float32(gs.MusicVolume))
+	a.musicEnd = start + time.Duration(len(left))*time.Second/sampleRate
+}
+
+// writeWAV writes the mix as 16-bit stereo PCM, padded or cut to length,
+// and discards the spill file.
+func (a *exportAudio) writeWAV(path string, length time.Duration) error {
+	defer a.discard()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := samplesAt(length)
+	a.spill(n)
+	if a.err != nil {
+		return a.err
+	}
+	if n > a.base {
+		n = a.base
+	}
+	gain := float32(1)
+	if a.peak > 1 {
+		gain = 1 / a.peak
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	header := wavHeader(uint32(n * 4))
+	w := bufio.NewWriter(f)
+	w.Write(header[:])
+	if n > 0 {
+		if err := a.spillW.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := a.spillF.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+		r := bufio.NewReader(a.spillF)
+		var in [8]byte
+		var out [4]byte
+		for i := 0; i < n; i++ {
+			if _, err := io.ReadFull(r, in[:]); err != nil {
+				f.Close()
+				return err
+			}
+			l := int16(math.Float32frombits(binary.LittleEndian.Uint32(in[0:])) * gain * 32767)
+			rr := int16(math.Float32frombits(binary.LittleEndian.Uint32(in[4:])) * gain * 32767)
+			binary.LittleEndian.PutUint16(out[0:], uint16(l))
+			binary.LittleEndian.PutUint16(out[2:], uint16(rr))
+			w.Write(out[:])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// videoExporter steps a movie at its native rate while rendering output
+// frames at a fixed rate into an offscreen image.
+type videoExporter struct {
+	ctx    context.Context
+	mp     *moviePlayer
+	dir    string
+	fps    int
+	scale  int
+	base   time.Time
+	out    *ebiten.Image
+	pixels []byte
+	frame  int
+	audio  *exportAudio
+	pngs   chan exportPNG
+	wg     sync.WaitGroup
+	errMu  sync.Mutex
+	err    error
+}
+
+type exportPNG struct {
+	path string
+	img  *image.RGBA
+}
+
+// movieTime returns the movie position of movie frame idx.
+func (e *videoExporter) movieTime(idx int) time.Duration {
+	return time.Duration(idx) * time.Second / time.Duration(e.mp.baseFPS)
+}
+
+func (e *videoExporter) length() time.Duration {
+	return e.movieTime(len(e.mp.frames))
+}
+
+func (e *videoExporter) setErr(err error) {
+	e.errMu.Lock()
+	if e.err == nil {
+		e.err = err
+	}
+	e.errMu.Unlock()
+}
+
+// stepMovie advances one movie frame and rebases the interpolation window
+// onto the export clock so blending follows movie time, not wall time.
+func (e *videoExporter) stepMovie() {
+	at := e.movieTime(e.mp.cur)
+	e.audio.setPos(at)
+	stateMu.Lock()
+	prevCur := state.curTime
+	stateMu.Unlock()
+
+	e.mp.step()
+
+	stateMu.Lock()
+	if !state.curTime.Equal(prevCur) {
+		d := state.curTime.Sub(state.prevTime)
+		state.prevTime = e.base.Add(at)
+		state.curTime = state.prevTime.Add(d)
+	}
+	stateMu.Unlock()
+}
+
+// renderFrame draws the current state at export time t and queues it for
+// encoding.
+func (e *videoExporter) renderFrame(t time.Duration) {
+	snap := captureDrawSnapshot()
+	alpha, mobileFade, pictFade := computeInterpolation(e.base.Add(t), snap.prevTime, snap.curTime, gs.MobileBlendAmount, gs.BlendAmount)
+	e.out.Fill(color.Black)
+	drawWorld(e.out, e.scale, snap, alpha, mobileFade, pictFade)
+
+	prev := gs.GameScale
+	gs.GameScale = float64(e.scale)
+	drawSpeechBubbles(e.out, snap, alpha, float64(e.scale)/gsdef.GameScale)
+	gs.GameScale = prev
+
+	e.out.ReadPixels(e.pixels)
+	img := image.NewRGBA(e.out.Bounds())
+	copy(img.Pix, e.pixels)
+	name := filepath.Join(e.dir, fmt.Sprintf("frame_%06d.png", e.frame))
+	e.pngs <- exportPNG{path: name, img: img}
+	e.frame++
+}
+
+func (e *videoExporter) encodePNGs() {
+	defer e.wg.Done()
+	for job := range e.pngs {
+		f, err := os.Create(job.path)
+		if err != nil {
+			e.setErr(err)
+			continue
+		}
+		if err := png.Encode(f, job.img); err != nil {
+			e.setErr(err)
+		}
+		if err := f.Close(); err != nil {
+			e.setErr(err)
+		}
+	}
+}
+
+// Update renders a batch of frames per tick so the export runs as fast as
+// the GPU allows while still yielding to ebiten.
+func (e *videoExporter) Update() error {
+	once.Do(func() {
+		initGame()
+		applyExportSettings()
+	})
+	if e.ctx.Err() != nil {
+		return ebiten.Termination
+	}
+	const framesPerTick = 8
+	for i := 0; i < framesPerTick; i++ {
+		t := time.Duration(e.frame) * time.Second / time.Duration(e.fps)
+		if t >= e.length() {
+			return ebiten.Termination
+		}
+		for e.mp.cur < len(e.mp.frames) && e.movieTime(e.mp.cur) <= t {
+			e.stepMovie()
+		}
+		e.renderFrame(t)
+		if e.frame%(e.fps*10) == 0 {
+			log.Printf("exportVideo: %v / %v", t.Round(time.Second), e.length().Round(time.Second))
+		}
+	}
+	return nil
+}
+
+func (e *videoExporter) Draw(screen *ebiten.Image) {}
+
+func (e *videoExporter) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+// applyExportSettings keeps audio capture independent of the mute and focus
+// state of the helper window.
+func applyExportSettings() {
+	gs.Mute = false
+	gs.MuteWhenUnfocused = false
+	gs.PowerSaveAlways = false
+	gs.PowerSaveBackground = false
+	focusMuted = false
+}
+
+// loadExportAssets loads CL_Images and CL_Sounds for an export run.
+func loadExportAssets() error {
+	var err error
+	clImages, err = climg.Load(filepath.Join(dataDirPath, CL_ImagesFile))
+	if err != nil {
+		return fmt.Errorf("load CL_Images: %w", err)
+	}
+	clImages.Denoise = gs.DenoiseImages
+	clImages.DenoiseSharpness = gs.DenoiseSharpness
+	clImages.DenoiseAmount = gs.DenoiseAmount
+	clImages.SetGammaCorrection(gs.SpriteGammaCorrection, gs.SpriteGamma, gs.MonitorGamma)
+	clSounds, err = clsnd.Load(filepath.Join(dataDirPath, CL_SoundsFile))
+	if err != nil {
+		return fmt.Errorf("load CL_Sounds: %w", err)
+	}
+	return nil
+}
+
+// exportVideo renders the movie at path to numbered PNG frames plus an
+// audio.wav in dir. Frames are drawn into an offscreen image and read back,
+// but Ebiten has no headless mode: its graphics context only exists inside
+// RunGame, so a small minimized window must be opened. On a machine without
+// a display, run it under a virtual one such as xvfb-run.
+func exportVideo(ctx context.Context, path, dir string, fps, scale int) error {
+	if err := exportDisplayAvailable(); err != nil {
+		return err
+	}
+	if fps < 1 {
+		fps = defaultExportFPS
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	drawStateEncrypted = false
+	frames, err := parseMovie(path, clVersion)
+	if err != nil {
+		return fmt.Errorf("parse movie: %w", err)
+	}
+	if len(frames) == 0 {
+		return errors.New("movie has no frames")
+	}
+	playerName = extractMoviePlayerName(frames)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mp := newMoviePlayer(frames, clMovFPS, cancel)
+	mp.ticker.Stop()
+	mp.playing = false
+
+	w, h := gameAreaSizeX*scale, gameAreaSizeY*scale
+	e := &videoExporter{
+		ctx:    ctx,
+		mp:     mp,
+		dir:    dir,
+		fps:    fps,
+		scale:  scale,
+		base:   time.Now(),
+		out:    ebiten.NewImage(w, h),
+		pixels: make([]byte, 4*w*h),
+		audio:  &exportAudio{},
+		pngs:   make(chan exportPNG, 2*runtime.NumCPU()),
+	}
+	// Anchor the interpolation window to the export clock.
+	stateMu.Lock()
+	state.prevTime = e.base
+	state.curTime = e.base
+	stateMu.Unlock()
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		e.wg.Add(1)
+		go e.encodePNGs()
+	}
+
+	videoAudio = e.audio
+	defer func() { videoAudio = nil }()
+	defer e.audio.discard()
+
+	ebiten.SetWindowTitle("goThoom export")
+	ebiten.SetWindowSize(320, 240)
+	ebiten.SetRunnableOnUnfocused(true)
+	ebiten.SetVsyncEnabled(false)
+	ebiten.SetTPS(ebiten.SyncWithFPS)
+	ebiten.MinimizeWindow()
+	gameCtx = ctx
+	runErr := ebiten.RunGame(e)
+	close(e.pngs)
+	e.wg.Wait()
+	if runErr != nil && !errors.Is(runErr, ebiten.Termination) {
+		return runErr
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wav := filepath.Join(dir, "audio.wav")
+	if err := e.audio.writeWAV(wav, e.length()); err != nil {
+		return fmt.Errorf("write audio: %w", err)
+	}
+	log.Printf("exportVideo: wrote %d frames at %d fps and %s", e.frame, fps, wav)
+	return nil
+}
+
+// exportDisplayAvailable reports a clear error instead of a window system
+// failure when -exportVideo runs where no window can be opened.
+func exportDisplayAvailable() error {
+	switch runtime.GOOS {
+	case "windows", "darwin", "js", "android", "ios":
+		return nil
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return errors.New("no display: rendering needs a window, run under a virtual display such as xvfb-run")
+	}
+	return nil
+}
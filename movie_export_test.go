@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportAudioWAV(t *testing.T) {
+	a := &exportAudio{}
+	a.setPos(500 * time.Millisecond)
+	tone := []float32{0.5, 0.5, 0.5, 0.5}
+	a.mix(samplesAt(a.pos), tone, tone, 1)
+	a.mix(samplesAt(a.pos), tone, tone, 1)
+
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	if err := a.writeWAV(path, time.Second); err != nil {
+		t.Fatalf("writeWAV: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := 44 + sampleRate*4
+	if len(data) != want {
+		t.Fatalf("wav size %d, want %d", len(data), want)
+	}
+	if got := binary.LittleEndian.Uint32(data[40:]); got != uint32(sampleRate*4) {
+		t.Fatalf("data length %d", got)
+	}
+	off := 44 + samplesAt(500*time.Millisecond)*4
+	if l := int16(binary.LittleEndian.Uint16(data[off:])); l != 32767 {
+		t.Fatalf("mixed sample %d, want 32767", l)
+	}
+	if l := int16(binary.LittleEndian.Uint16(data[44:])); l != 0 {
+		t.Fatalf("leading silence %d", l)
+	}
+}
+
+// Test that finished audio is spilled as the position moves instead of
+// kept in memory, and still normalized over the whole export.
+func TestExportAudioSpill(t *testing.T) {
+	a := &exportAudio{}
+	defer a.discard()
+	tone := []float32{0.5, 0.5}
+	loud := []float32{2, 2}
+	a.mix(samplesAt(a.pos), tone, tone, 1)
+	late := 2*exportAudioChunk + 7
+	a.setPos(time.Duration(late) * time.Second / sampleRate)
+	if a.base == 0 || len(a.left) > exportAudioChunk {
+		t.Fatalf("nothing spilled: base %d, %d samples held", a.base, len(a.left))
+	}
+	at := samplesAt(a.pos)
+	a.mix(at, loud, loud, 1)
+
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	length := time.Duration(3*exportAudioChunk) * time.Second / sampleRate
+	if err := a.writeWAV(path, length); err != nil {
+		t.Fatalf("writeWAV: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if want := 44 + samplesAt(length)*4; len(data) != want {
+		t.Fatalf("wav size %d, want %d", len(data), want)
+	}
+	sample := func(i int) int16 { return int16(binary.LittleEndian.Uint16(data[44+4*i:])) }
+	if got := sample(0); got != 32767/4 {
+		t.Errorf("early tone %d, want %d", got, 32767/4)
+	}
+	if got := sample(at); got != 32767 {
+		t.Errorf("late tone %d, want 32767", got)
+	}
+	if got := sample(at + 2); got != 0 {
+		t.Errorf("silence after tone %d", got)
+	}
+	if a.spillF != nil {
+		t.Errorf("spill file kept")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// movieEvent is one line of text found in a movie, keyed by the index of
+// the frame that carries it.
+type movieEvent struct {
+	frame int
+	kind  string // chat, info, think, fallen or share
+	text  string
+}
+
+// buildMovieIndex collects the chat, bubble, fallen and share text of every
+// frame so playback can search and seek to it. Frames are scanned without
+// touching the draw state.
+func buildMovieIndex(frames []movieFrame) []movieEvent {
+	names := map[uint8]string{}
+	stateMu.Lock()
+	for idx, d := range initialState.descriptors {
+		names[idx] = d.Name
+	}
+	stateMu.Unlock()
+
+	var events []movieEvent
+	for i, fr := range frames {
+		m := fr.data
+		if len(m) >= 2 && binary.BigEndian.Uint16(m[:2]) == 2 {
+			info, bubbles := drawStateText(m[2:], names)
+			for _, line := range bytes.Split(info, []byte{'\r'}) {
+				if kind, txt := movieInfoText(line); txt != "" {
+					events = append(events, movieEvent{frame: i, kind: kind, text: txt})
+				}
+			}
+			for _, b := range bubbles {
+				if txt := movieBubbleText(b, names); txt != "" {
+					events = append(events, movieEvent{frame: i, kind: "chat", text: txt})
+				}
+			}
+			continue
+		}
+		if len(m) > 16 {
+			if kind, txt := movieInfoText(m[16:]); txt != "" {
+				events = append(events, movieEvent{frame: i, kind: kind, text: txt})
+			}
+		}
+	}
+	return events
+}
+
+// drawStateText returns the info text and raw bubbles of a draw state.
+// Descriptor names are recorded in names so bubbles can be attributed.
+func drawStateText(data []byte, names map[uint8]string) (info []byte, bubbles [][]byte) {
+	var msg drawStateMsg
+	err := decodeDrawState(&msg, data)
+	for _, d := range msg.descs {
+		names[d.Index] = d.Name
+	}
+	if err != nil {
+		return nil, nil
+	}
+	infos, bs, _, _ := splitDrawStateText(msg.stateData)
+	for _, b := range bs {
+		bubbles = append(bubbles, b.data)
+	}
+	return bytes.Join(infos, []byte{'\r'}), bubbles
+}
+
+// movieInfoText returns the kind and text of one info text line. BEPP
+// lines are classified by prefix; back-end and music commands are skipped.
+func movieInfoText(line []byte) (string, string) {
+	if i := bytes.IndexByte(line, 0); i >= 0 {
+		line = line[:i]
+	}
+	if len(line) == 0 {
+		return "", ""
+	}
+	kind := "info"
+	if line[0] == 0xC2 {
+		if len(line) < 3 {
+			return "", ""
+		}
+		switch string(line[1:3]) {
+		case "be", "mu", "ba":
+			return "", ""
+		case "hf", "nf":
+			kind = "fallen"
+		case "sh", "su":
+			kind = "share"
+		case "th":
+			kind = "think"
+		}
+		line = line[3:]
+	}
+	s := strings.TrimSpace(decodeMacRoman(stripBEPPTags(append([]byte(nil), line...))))
+	if strings.HasPrefix(s, "/") {
+		return "", ""
+	}
+	return kind, s
+}
+
+// movieBubbleText formats a bubble the way it appears in chat.
+func movieBubbleText(b []byte, names map[uint8]string) string {
+	verb, txt, bubbleName, _, _, bubbleType, _ := decodeBubble(b)
+	if txt == "" {
+		return ""
+	}
+	name := names[b[0]]
+	if bubbleName != "" {
+		name = bubbleName
+	}
+	switch {
+	case bubbleType == kBubbleNarrate && name != "":
+		return fmt.Sprintf("(%v): %v", name, txt)
+	case bubbleType == kBubbleNarrate, verb == bubbleVerbVerbatim:
+		return txt
+	case verb == bubbleVerbParentheses:
+		return fmt.Sprintf("(%v)", txt)
+	case name == "":
+		return "* " + txt
+	}
+	return fmt.Sprintf("%v %v, %v", name, verb, txt)
+}
+
+// searchMovieIndex returns the events whose text contains query, ignoring
+// case. An empty query matches everything.
+func searchMovieIndex(events []movieEvent, query string) []movieEvent {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return events
+	}
+	var out []movieEvent
+	for _, ev := range events {
+		if strings.Contains(strings.ToLower(ev.text), q) || ev.kind == q {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestBuildMovieIndex(t *testing.T) {
+	stateMu.Lock()
+	initialState = drawState{}
+	stateMu.Unlock()
+
+	ds := []byte{0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0} // tag, ack and resend
+	ds = append(ds, 1, 5, 0, 0, 1)                // one descriptor, index 5
+	ds = append(ds, "Bob\x00"...)
+	ds = append(ds, 0)                   // colors
+	ds = append(ds, 0, 0, 0, 0, 0, 0, 0) // stats
+	ds = append(ds, 0, 0)                // pictures, mobiles
+	st := []byte("\xC2hfBob has fallen\x00")
+	st = append(st, 1, 5, kBubbleNormal)
+	st = append(st, "hello there\x00"...)
+	ds = append(ds, byte(len(st)>>8), byte(len(st)))
+	ds = append(ds, st...)
+
+	msg := append(make([]byte, 16), "\xC2shYou are sharing experiences with Ann.\x00"...)
+	frames := []movieFrame{{data: []byte{0, 9}}, {data: ds}, {data: msg}}
+	events := buildMovieIndex(frames)
+	want := []movieEvent{
+		{1, "fallen", "Bob has fallen"},
+		{1, "chat", "Bob says, hello there"},
+		{2, "share", "You are sharing experiences with Ann."},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events %+v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+	if got := searchMovieIndex(events, "FALLEN"); len(got) != 1 || got[0].frame != 1 {
+		t.Fatalf("search %+v", got)
+	}
+	if got := searchMovieIndex(events, "share"); len(got) != 1 || got[0].frame != 2 {
+		t.Fatalf("kind search %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+// movieNote is a named bookmark with an optional note. Frame is the
+// movieFrame.index it points at so notes survive edits that keep it.
+type movieNote struct {
+	Frame int32  `json:"frame"`
+	Name  string `json:"name"`
+	Text  string `json:"text,omitempty"`
+}
+
+type movieNotesFile struct {
+	Notes []movieNote `json:"notes"`
+}
+
+// movieNotesPath returns the sidecar file for a movie, e.g.
+// hunt.clMov.notes.json. Movies without a path keep notes in memory.
+func movieNotesPath(moviePath string) string {
+	if moviePath == "" {
+		return ""
+	}
+	return moviePath + ".notes.json"
+}
+
+func loadMovieNotes(path string) ([]movieNote, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f movieNotesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	sortMovieNotes(f.Notes)
+	return f.Notes, nil
+}
+
+// saveMovieNotes writes notes to path, removing the file once the last
+// note is deleted.
+func saveMovieNotes(path string, notes []movieNote) error {
+	if path == "" {
+		return nil
+	}
+	if len(notes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(movieNotesFile{Notes: notes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func sortMovieNotes(notes []movieNote) {
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Frame < notes[j].Frame })
+}
+
+// loadNotes reads the bookmarks stored next to the movie at moviePath.
+func (p *moviePlayer) loadNotes(moviePath string) {
+	p.notesPath = movieNotesPath(moviePath)
+	notes, err := loadMovieNotes(p.notesPath)
+	if err != nil {
+		logError("movie notes: %v", err)
+	}
+	p.notes = notes
+	p.updateNoteMarks()
+}
+
+// framePos returns the position in p.frames of the frame with the given
+// movieFrame.index, or the first frame after it.
+func (p *moviePlayer) framePos(index int32) int {
+	return sort.Search(len(p.frames), func(i int) bool { return p.frames[i].index >= index })
+}
+
+// addNote bookmarks the frame about to play and saves the sidecar.
+func (p *moviePlayer) addNote(name, text string) error {
+	if len(p.frames) == 0 {
+		return nil
+	}
+	pos := p.cur
+	if pos >= len(p.frames) {
+		pos = len(p.frames) - 1
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = movieTimeLabel(pos, p.baseFPS)
+	}
+	p.notes = append(p.notes, movieNote{Frame: p.frames[pos].index, Name: name, Text: strings.TrimSpace(text)})
+	sortMovieNotes(p.notes)
+	p.updateNoteMarks()
+	return saveMovieNotes(p.notesPath, p.notes)
+}
+
+func (p *moviePlayer) deleteNote(i int) error {
+	if i < 0 || i >= len(p.notes) {
+		return nil
+	}
+	p.notes = append(p.notes[:i], p.notes[i+1:]...)
+	p.updateNoteMarks()
+	return saveMovieNotes(p.notesPath, p.notes)
+}
+
+// updateNoteMarks shows the bookmarks as ticks on the seek slider.
+func (p *moviePlayer) updateNoteMarks() {
+	if p.slider == nil || len(p.frames) == 0 {
+		return
+	}
+	marks := make([]float32, 0, len(p.notes))
+	for _, n := range p.notes {
+		marks = append(marks, float32(p.framePos(n.Frame))/float32(len(p.frames)))
+	}
+	p.slider.ScrollMarks = marks
+	p.slider.Dirty = true
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that bookmarks are keyed by frame index, kept sorted and saved in
+// the sidecar next to the movie.
+func TestMovieNotes(t *testing.T) {
+	movie := filepath.Join(t.TempDir(), "hunt.clMov")
+	p := &moviePlayer{baseFPS: 5}
+	for i := 0; i < 10; i++ {
+		p.frames = append(p.frames, movieFrame{index: int32(100 + i)})
+	}
+	p.loadNotes(movie)
+	if p.notesPath != movie+".notes.json" || len(p.notes) != 0 {
+		t.Fatalf("path %q notes %v", p.notesPath, p.notes)
+	}
+
+	p.cur = 7
+	if err := p.addNote("late", "bad positioning"); err != nil {
+		t.Fatalf("addNote: %v", err)
+	}
+	p.cur = 2
+	if err := p.addNote("", ""); err != nil {
+		t.Fatalf("addNote: %v", err)
+	}
+
+	q := &moviePlayer{frames: p.frames, baseFPS: 5}
+	q.loadNotes(movie)
+	if len(q.notes) != 2 || q.notes[0].Frame != 102 || q.notes[0].Name != "0:00:00" || q.notes[1].Text != "bad positioning" {
+		t.Fatalf("loaded %+v", q.notes)
+	}
+	if q.framePos(q.notes[1].Frame) != 7 {
+		t.Fatalf("framePos = %d", q.framePos(q.notes[1].Frame))
+	}
+
+	q.deleteNote(0)
+	q.deleteNote(0)
+	if _, err := os.Stat(movie + ".notes.json"); !os.IsNotExist(err) {
+		t.Fatalf("empty sidecar kept: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+
+	"gothoom/eui"
+)
+
+// promptNote asks for a bookmark name and note at the current frame.
+func (p *moviePlayer) promptNote() {
+	pos := p.cur
+	if pos >= len(p.frames) {
+		pos = len(p.frames) - 1
+	}
+	if pos < 0 {
+		return
+	}
+	field := func(label string, width float32) (*eui.ItemData, *eui.ItemData) {
+		row := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL}
+		l, _ := eui.NewText()
+		l.Text = label
+		l.Size = eui.Point{X: 50, Y: 20}
+		l.FontSize = 12
+		row.AddItem(l)
+		in, _ := eui.NewInput()
+		in.Size = eui.Point{X: width, Y: 20}
+		in.FontSize = 12
+		row.AddItem(in)
+		return row, in
+	}
+	nameRow, nameInput := field("Name:", 300)
+	noteRow, noteInput := field("Note:", 420)
+	showPopup(
+		"Add Bookmark",
+		fmt.Sprintf("Bookmark at %s", movieTimeLabel(pos, p.baseFPS)),
+		[]popupButton{{Text: "Cancel"}, {Text: "Add", Action: func() {
+			if err := p.addNote(nameInput.Text, noteInput.Text); err != nil {
+				makeErrorWindow("Error: Save notes: " + err.Error())
+			}
+			p.refreshMovieNotes()
+		}}},
+		nameRow, noteRow,
+	)
+}
+
+// makeMovieNotesWindow lists the movie's bookmarks. Clicking one seeks to
+// it.
+func (p *moviePlayer) makeMovieNotesWindow() {
+	if p.notesWin != nil {
+		p.refreshMovieNotes()
+		p.notesWin.MarkOpen()
+		return
+	}
+	win := eui.NewWindow()
+	win.Title = "Movie Notes"
+	win.Size = eui.Point{X: 520, Y: 300}
+	win.Closable = true
+	win.Movable = true
+	win.Resizable = true
+	win.NoScroll = true
+	win.SetZone(eui.HZoneLeft, eui.VZoneMiddleTop)
+
+	flow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Fixed: true}
+	win.AddItem(flow)
+	p.notesList = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true, Fixed: true}
+	flow.AddItem(p.notesList)
+
+	win.OnResize = func() { p.refreshMovieNotes() }
+	p.notesWin = win
+	win.AddWindow(false)
+	p.refreshMovieNotes()
+	win.MarkOpen()
+}
+
+func (p *moviePlayer) refreshMovieNotes() {
+	if p.notesWin == nil || p.notesList == nil {
+		return
+	}
+	win := p.notesWin
+	pad := (win.Padding + win.BorderPad) * eui.UIScale()
+	p.notesList.Size = eui.Point{
+		X: win.GetSize().X - 2*pad,
+		Y: win.GetSize().Y - win.GetTitleSize() - 2*pad,
+	}
+	width := p.notesList.Size.X - eui.ScrollbarWidth()
+	p.notesList.Contents = p.notesList.Contents[:0]
+
+	for i, n := range p.notes {
+		idx := i
+		pos := p.framePos(n.Frame)
+		row := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL, Fixed: true}
+		row.Size = eui.Point{X: width, Y: 20}
+		label := fmt.Sprintf("%s  %s", movieTimeLabel(pos, p.baseFPS), n.Name)
+		if n.Text != "" {
+			label += ": " + n.Text
+		}
+		btn, events := eui.NewButton()
+		btn.Text = label
+		btn.Size = eui.Point{X: width - 24, Y: 20}
+		btn.FontSize = 10
+		events.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventClick && !seekingMov {
+				seekLock.Lock()
+				go func() {
+					p.seek(pos)
+					seekLock.Unlock()
+				}()
+			}
+		}
+		row.AddItem(btn)
+		del, delEvents := eui.NewButton()
+		del.Text = "x"
+		del.SetTooltip("Remove this bookmark")
+		del.Size = eui.Point{X: 20, Y: 20}
+		del.FontSize = 10
+		delEvents.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventClick {
+				if err := p.deleteNote(idx); err != nil {
+					makeErrorWindow("Error: Save notes: " + err.Error())
+				}
+				p.refreshMovieNotes()
+			}
+		}
+		row.AddItem(del)
+		p.notesList.AddItem(row)
+	}
+	if len(p.notes) == 0 {
+		none, _ := eui.NewText()
+		none.Text = "No bookmarks yet; use Bookmark in Movie Controls"
+		none.Size = eui.Point{X: width, Y: 20}
+		none.FontSize = 10
+		p.notesList.AddItem(none)
+	}
+	win.Refresh()
+}
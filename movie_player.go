@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gothoom/eui"
@@ -18,6 +20,11 @@ var (
 	movieMode     bool
 	movieWin      *eui.WindowData
 	movieDropped  int
+	// activeMovie is the player behind movieWin, for keyboard controls.
+	activeMovie *moviePlayer
+	// reversePlayback disables interpolation while frames are restored
+	// backwards.
+	reversePlayback bool
 )
 
 // movieCheckpoint captures the draw state after processing a frame. idx
@@ -39,18 +46,38 @@ type moviePlayer struct {
 	baseFPS int
 	cur     int // number of frames processed
 	playing bool
+	reverse bool // play backwards one frame per tick
 	repeat  bool
 	ticker  *time.Ticker
 	cancel  context.CancelFunc
+	source  string // protocol inspector source: "movie" or "pcap"
 
 	checkpoints []movieCheckpoint
-
-	slider       *eui.ItemData
-	curLabel     *eui.ItemData
-	totalLabel   *eui.ItemData
-	fpsLabel     *eui.ItemData
-	playButton   *eui.ItemData
-	repeatButton *eui.ItemData
+	cpMu        sync.Mutex // guards checkpoints against precompute
+	// precomputeProgress is the percentage precompute has decoded plus
+	// one, or 0 when it is not running. precomputeShown is the value the
+	// label shows; only the game thread touches it.
+	precomputeProgress atomic.Int32
+	precomputeShown    int32
+	// rewind holds the state after every frame of the span last replayed
+	// for stepping back, so reverse playback restores instead of replaying.
+	rewind    []movieCheckpoint
+	events    []movieEvent // chat and event lines by frame
+	notes     []movieNote
+	notesPath string
+
+	slider          *eui.ItemData
+	curLabel        *eui.ItemData
+	totalLabel      *eui.ItemData
+	fpsLabel        *eui.ItemData
+	precomputeLabel *eui.ItemData
+	playButton      *eui.ItemData
+	reverseButton   *eui.ItemData
+	repeatButton    *eui.ItemData
+	eventsWin       *eui.WindowData
+	eventsList      *eui.ItemData
+	notesWin        *eui.WindowData
+	notesList       *eui.ItemData
 }
 
 func newMoviePlayer(frames []movieFrame, fps int, cancel context.CancelFunc) *moviePlayer {
@@ -70,7 +97,9 @@ func newMoviePlayer(frames []movieFrame, fps int, cancel context.CancelFunc) *mo
 		playing:     true,
 		ticker:      time.NewTicker(time.Second / time.Duration(fps)),
 		cancel:      cancel,
+		source:      "movie",
 		checkpoints: []movieCheckpoint{{idx: 0, state: cloneDrawState(initialState)}},
+		events:      buildMovieIndex(frames),
 	}
 }
 
@@ -81,6 +110,7 @@ var seekingMov bool
 func (p *moviePlayer) makePlaybackWindow() {
 	win := eui.NewWindow()
 	movieWin = win
+	activeMovie = p
 	win.Title = "Movie Controls"
 	win.ShowDragbar = true
 	win.Theme.Window.DragbarColor = eui.Color{R: 96, G: 96, B: 96}
@@ -127,6 +157,12 @@ func (p *moviePlayer) makePlaybackWindow() {
 	p.totalLabel.FontSize = 10
 	tFlow.AddItem(p.totalLabel)
 
+	p.precomputeLabel, _ = eui.NewText()
+	p.precomputeLabel.Size = eui.Point{X: 90, Y: 24}
+	p.precomputeLabel.FontSize = 10
+	p.precomputeLabel.SetTooltip("Preparing seek points in the background")
+	tFlow.AddItem(p.precomputeLabel)
+
 	flow.AddItem(tFlow)
 
 	// Button flow
@@ -154,6 +190,29 @@ func (p *moviePlayer) makePlaybackWindow() {
 	}
 	bFlow.AddItem(back)
 
+	stepBackBtn, stepBackEv := eui.NewButton()
+	stepBackBtn.Text = "|<"
+	stepBackBtn.Size = eui.Point{X: 40, Y: 24}
+	stepBackBtn.SetTooltip("Back one frame (,)")
+	stepBackEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.stepFrame(-1)
+		}
+	}
+	bFlow.AddItem(stepBackBtn)
+
+	reverseBtn, reverseEv := eui.NewButton()
+	reverseBtn.Text = "Reverse"
+	reverseBtn.Size = eui.Point{X: 80, Y: 24}
+	reverseBtn.SetTooltip("Play backwards (J)")
+	p.reverseButton = reverseBtn
+	reverseEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.playReverse(!(p.playing && p.reverse))
+		}
+	}
+	bFlow.AddItem(reverseBtn)
+
 	play, playEv := eui.NewButton()
 	play.Text = "Play/Pause"
 	play.SetTooltip("Toggle playback")
@@ -172,6 +231,17 @@ func (p *moviePlayer) makePlaybackWindow() {
 	}
 	bFlow.AddItem(play)
 
+	stepFwdBtn, stepFwdEv := eui.NewButton()
+	stepFwdBtn.Text = ">|"
+	stepFwdBtn.Size = eui.Point{X: 40, Y: 24}
+	stepFwdBtn.SetTooltip("Forward one frame (.)")
+	stepFwdEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.stepFrame(1)
+		}
+	}
+	bFlow.AddItem(stepFwdBtn)
+
 	repeatBtn, repeatEv := eui.NewButton()
 	repeatBtn.Text = "repeat"
 	repeatBtn.SetTooltip("Loop playback when the movie ends")
@@ -208,6 +278,39 @@ func (p *moviePlayer) makePlaybackWindow() {
 	}
 	bFlow.AddItem(forward)
 
+	eventsBtn, eventsEv := eui.NewButton()
+	eventsBtn.Text = "Events"
+	eventsBtn.Size = eui.Point{X: 80, Y: 24}
+	eventsBtn.SetTooltip("Search chat and events and jump to them")
+	eventsEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.makeMovieEventsWindow()
+		}
+	}
+	bFlow.AddItem(eventsBtn)
+
+	markBtn, markEv := eui.NewButton()
+	markBtn.Text = "Bookmark"
+	markBtn.Size = eui.Point{X: 80, Y: 24}
+	markBtn.SetTooltip("Add a bookmark and note at this point")
+	markEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.promptNote()
+		}
+	}
+	bFlow.AddItem(markBtn)
+
+	notesBtn, notesEv := eui.NewButton()
+	notesBtn.Text = "Notes"
+	notesBtn.Size = eui.Point{X: 80, Y: 24}
+	notesBtn.SetTooltip("List bookmarks and jump to them")
+	notesEv.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			p.makeMovieNotesWindow()
+		}
+	}
+	bFlow.AddItem(notesBtn)
+
 	spacer, _ := eui.NewText()
 	spacer.Text = ""
 	spacer.Size = eui.Point{X: 40, Y: 24}
@@ -310,6 +413,15 @@ func (p *moviePlayer) makePlaybackWindow() {
 	win.OnClose = func() {
 		// Pause and stop ticker
 		p.pause()
+		if activeMovie == p {
+			activeMovie = nil
+		}
+		if p.eventsWin != nil {
+			p.eventsWin.Close()
+		}
+		if p.notesWin != nil {
+			p.notesWin.Close()
+		}
 		if p.ticker != nil {
 			p.ticker.Stop()
 		}
@@ -340,6 +452,7 @@ func (p *moviePlayer) makePlaybackWindow() {
 		}
 	}
 
+	p.updateNoteMarks()
 	p.updateUI()
 	updateRecordButton()
 }
@@ -366,6 +479,7 @@ func changeRepeatButton(p *moviePlayer, repeat *eui.ItemData) {
 
 func (p *moviePlayer) run(ctx context.Context) {
 	<-gameStarted
+	go p.precompute(ctx)
 	for {
 		select {
 		case <-ctx.Done():
@@ -374,14 +488,26 @@ func (p *moviePlayer) run(ctx context.Context) {
 			movieMode = false
 			return
 		case <-p.ticker.C:
-			if p.playing {
+			// Ticks are skipped while a seek or frame step holds the
+			// lock; both replace the state step works on.
+			if p.playing && seekLock.TryLock() {
 				p.step()
+				seekLock.Unlock()
 			}
 		}
 	}
 }
 
 func (p *moviePlayer) step() {
+	if p.reverse {
+		p.stepBack()
+		if p.cur == 0 {
+			p.setReverse(false)
+			p.playing = false
+			p.updateUI()
+		}
+		return
+	}
 	if len(p.frames) == 0 {
 		p.playing = false
 		playingMovie = false
@@ -409,22 +535,22 @@ func (p *moviePlayer) step() {
 		}
 	}
 	m := p.frames[p.cur]
-	movieDropped = updateFrameCounters(m.index)
-	if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
-		handleDrawState(m.data, true)
+	simulating := currentNetSim().enabled()
+	if simulating {
+		for _, f := range movieSim.send(p.cur, m) {
+			applyMovieFrame(f, p.source)
+		}
 	} else {
-		// Advance the logical frame counter even when this movie frame
-		// does not contain a draw-state update so time-based effects
-		// (e.g., bubble expiration) progress correctly during playback.
-		frameCounter++
+		applyMovieFrame(m, p.source)
 	}
-	maybeDecodeMessage(m.data)
 	p.cur++
-	if p.cur%checkpointInterval == 0 {
+	// Simulated network trouble makes the state differ from the movie's,
+	// so it must not become a seek checkpoint.
+	if !simulating && p.cur%checkpointInterval == 0 {
 		stateMu.Lock()
 		cp := movieCheckpoint{idx: p.cur, state: cloneDrawState(state)}
 		stateMu.Unlock()
-		p.checkpoints = append(p.checkpoints, cp)
+		p.addCheckpoint(cp)
 	}
 	if p.cur >= len(p.frames) {
 		if p.repeat {
@@ -438,6 +564,23 @@ func (p *moviePlayer) step() {
 	p.updateUI()
 }
 
+// applyMovieFrame plays one movie frame as if it had just arrived. source
+// labels it in the protocol inspector.
+func applyMovieFrame(m movieFrame, source string) {
+	movieDropped = updateFrameCounters(m.index)
+	applyMovieBlocks(m)
+	inspectMessage(m.data, source)
+	if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
+		handleDrawState(m.data, true)
+	} else {
+		// Advance the logical frame counter even when this movie frame
+		// does not contain a draw-state update so time-based effects
+		// (e.g., bubble expiration) progress correctly during playback.
+		frameCounter++
+	}
+	maybeDecodeMessage(m.data)
+}
+
 func (p *moviePlayer) updateUI() {
 	if p.slider != nil {
 		p.slider.Value = float32(p.cur)
@@ -465,6 +608,15 @@ func (p *moviePlayer) updateUI() {
 		changePlayButton(p, p.playButton)
 	}
 
+	if p.reverseButton != nil {
+		if p.playing && p.reverse {
+			p.reverseButton.Text = "Forward"
+		} else {
+			p.reverseButton.Text = "Reverse"
+		}
+		p.reverseButton.Dirty = true
+	}
+
 	if p.repeatButton != nil {
 		changeRepeatButton(p, p.repeatButton)
 	}
@@ -481,10 +633,14 @@ func (p *moviePlayer) setFPS(fps int) {
 	p.updateUI()
 }
 
-func (p *moviePlayer) play() { p.playing = true }
+func (p *moviePlayer) play() {
+	p.setReverse(false)
+	p.playing = true
+}
 
 func (p *moviePlayer) pause() {
 	p.playing = false
+	p.setReverse(false)
 }
 
 func (p *moviePlayer) skipBackMilli(milli int) {
@@ -513,7 +669,14 @@ func (p *moviePlayer) skipForwardMilli(milli int) {
 
 }
 
+// seek jumps to frame idx. Callers other than step hold seekLock.
 func (p *moviePlayer) seek(idx int) {
+	p.seekCapture(idx, false)
+}
+
+// seekCapture is seek that, when captureRewind is set, also keeps the state
+// after every frame it replays in p.rewind for stepBack.
+func (p *moviePlayer) seekCapture(idx int, captureRewind bool) {
 	seekingMov = true
 	defer func() { seekingMov = false }()
 
@@ -541,6 +704,7 @@ func (p *moviePlayer) seek(idx int) {
 	wasPlaying := p.playing
 	p.playing = false
 
+	p.cpMu.Lock()
 	cp := p.checkpoints[0]
 	for i := len(p.checkpoints) - 1; i >= 0; i-- {
 		if p.checkpoints[i].idx <= idx {
@@ -548,6 +712,7 @@ func (p *moviePlayer) seek(idx int) {
 			break
 		}
 	}
+	p.cpMu.Unlock()
 
 	stateMu.Lock()
 	state = cloneDrawState(cp.state)
@@ -556,10 +721,14 @@ func (p *moviePlayer) seek(idx int) {
 	prepareRenderCacheLocked()
 	stateMu.Unlock()
 	frameCounter = cp.idx
+	if captureRewind {
+		p.rewind = append(p.rewind[:0], movieCheckpoint{idx: cp.idx, state: cloneDrawState(cp.state)})
+	}
 
 	for i := cp.idx; i < idx; i++ {
 		m := p.frames[i]
 		movieDropped = updateFrameCounters(m.index)
+		applyMovieBlocks(m)
 		if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
 			// Skip render cache preparation for intermediate frames.
 			handleDrawState(m.data, i == idx-1)
@@ -569,24 +738,24 @@ func (p *moviePlayer) seek(idx int) {
 			frameCounter++
 		}
 		maybeDecodeMessage(m.data)
+		if captureRewind {
+			stateMu.Lock()
+			p.rewind = append(p.rewind, movieCheckpoint{idx: i + 1, state: cloneDrawState(state)})
+			stateMu.Unlock()
+		}
 		if frameCounter%checkpointInterval == 0 {
-			last := p.checkpoints[len(p.checkpoints)-1]
-			if last.idx != frameCounter {
-				stateMu.Lock()
-				snap := movieCheckpoint{idx: frameCounter, state: cloneDrawState(state)}
-				stateMu.Unlock()
-				p.checkpoints = append(p.checkpoints, snap)
-			}
+			stateMu.Lock()
+			snap := movieCheckpoint{idx: frameCounter, state: cloneDrawState(state)}
+			stateMu.Unlock()
+			p.addCheckpoint(snap)
 		}
 	}
-	last := p.checkpoints[len(p.checkpoints)-1]
-	if last.idx != idx {
-		stateMu.Lock()
-		snap := movieCheckpoint{idx: idx, state: cloneDrawState(state)}
-		stateMu.Unlock()
-		p.checkpoints = append(p.checkpoints, snap)
-	}
+	stateMu.Lock()
+	snap := movieCheckpoint{idx: idx, state: cloneDrawState(state)}
+	stateMu.Unlock()
+	p.addCheckpoint(snap)
 	p.cur = idx
+	movieSim.reset()
 	resetInterpolation()
 	// Avoid interpolation artifacts on the first frame after a seek.
 	suppressInterpOnce = true
@@ -595,6 +764,30 @@ func (p *moviePlayer) seek(idx int) {
 	p.playing = wasPlaying
 }
 
+// addCheckpoint records cp, keeping checkpoints sorted by idx. An
+// existing checkpoint for the same frame is kept.
+func (p *moviePlayer) addCheckpoint(cp movieCheckpoint) {
+	p.cpMu.Lock()
+	defer p.cpMu.Unlock()
+	i := sort.Search(len(p.checkpoints), func(i int) bool { return p.checkpoints[i].idx >= cp.idx })
+	if i < len(p.checkpoints) && p.checkpoints[i].idx == cp.idx {
+		return
+	}
+	p.checkpoints = append(p.checkpoints, movieCheckpoint{})
+	copy(p.checkpoints[i+1:], p.checkpoints[i:])
+	p.checkpoints[i] = cp
+}
+
+// addKeyframes turns the keyframes stored in a .clMovZ file into
+// checkpoints so seeking starts close to the target.
+func (p *moviePlayer) addKeyframes(kfs []movieKeyframe) {
+	for _, k := range kfs {
+		if k.Frame > 0 && k.Frame < len(p.frames) {
+			p.addCheckpoint(movieCheckpoint{idx: k.Frame, state: k.drawState()})
+		}
+	}
+}
+
 // maybeDecodeMessage applies a simple heuristic to determine whether a frame
 // could contain a textual message. Frames shorter than the 16-byte prefix or
 // tagged as draw-state (tag 2) are skipped to avoid needless decoding.
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// precompute decodes the whole movie into a private drawState in the
+// background and records a checkpoint every checkpointInterval frames, so
+// seeking far ahead doesn't have to replay everything playback hasn't
+// reached yet. It leaves the global state, players and sounds alone.
+func (p *moviePlayer) precompute(ctx context.Context) {
+	total := len(p.frames)
+	if total < checkpointInterval || p.haveAllCheckpoints() {
+		return
+	}
+	s := cloneDrawState(initialState)
+	var last int32
+	for i, m := range p.frames {
+		if i%checkpointInterval == 0 {
+			select {
+			case <-ctx.Done():
+				p.setPrecomputeProgress(-1)
+				return
+			default:
+			}
+			p.setPrecomputeProgress(i * 100 / total)
+		}
+		dropped := 0
+		if last != 0 && m.index > last+1 {
+			dropped = int(m.index - last - 1)
+		}
+		if last == 0 || m.index > last {
+			last = m.index
+		}
+		applyMovieBlocksTo(&s, m)
+		if len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2 {
+			data := m.data[2:]
+			if drawStateEncrypted {
+				data = append([]byte(nil), data...)
+				simpleEncrypt(data)
+			}
+			if err := parseDrawStateInto(&s, data, dropped); err != nil {
+				logDebug("precompute frame %d: %v", i, err)
+			}
+		}
+		if n := i + 1; n%checkpointInterval == 0 {
+			p.addCheckpoint(movieCheckpoint{idx: n, state: cloneDrawState(s)})
+		}
+	}
+	p.setPrecomputeProgress(-1)
+}
+
+// haveAllCheckpoints reports whether every checkpoint precompute would make
+// already exists, e.g. from .clMovZ keyframes.
+func (p *moviePlayer) haveAllCheckpoints() bool {
+	p.cpMu.Lock()
+	defer p.cpMu.Unlock()
+	have := make(map[int]bool, len(p.checkpoints))
+	for _, cp := range p.checkpoints {
+		have[cp.idx] = true
+	}
+	for n := checkpointInterval; n <= len(p.frames); n += checkpointInterval {
+		if !have[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// setPrecomputeProgress records the percentage decoded for the playback
+// window. A negative value clears it. Safe to call from any goroutine.
+func (p *moviePlayer) setPrecomputeProgress(pct int) {
+	p.precomputeProgress.Store(int32(pct + 1))
+}
+
+// showPrecomputeProgress puts the recorded percentage in the playback
+// window. Called from Update.
+func (p *moviePlayer) showPrecomputeProgress() {
+	v := p.precomputeProgress.Load()
+	if v == p.precomputeShown || p.precomputeLabel == nil {
+		return
+	}
+	p.precomputeShown = v
+	if v <= 0 {
+		p.precomputeLabel.Text = ""
+	} else {
+		p.precomputeLabel.Text = fmt.Sprintf("Indexing %d%%", v-1)
+	}
+	p.precomputeLabel.Dirty = true
+}
+
+// applyMovieBlocksTo is applyMovieBlocks for a private drawState.
+func applyMovieBlocksTo(s *drawState, m movieFrame) {
+	if len(m.data) != 0 || len(m.preData) == 0 {
+		return
+	}
+	data := m.preData
+	pos := 0
+	if m.flags&flagGameState != 0 {
+		if len(data) < 24 {
+			return
+		}
+		pos = 24 + int(binary.BigEndian.Uint32(data[12:16]))
+		if pos > len(data) {
+			return
+		}
+	}
+	if m.flags&flagMobileData != 0 {
+		var descs []frameDescriptor
+		var mobs []frameMobile
+		descs, mobs, pos = decodeMobileTable(data, pos, movieVersion)
+		if s.mobiles == nil {
+			s.mobiles = make(map[uint8]frameMobile)
+		}
+		for _, mob := range mobs {
+			s.mobiles[mob.Index] = mob
+		}
+		if s.descriptors == nil {
+			s.descriptors = make(map[uint8]frameDescriptor)
+		}
+		for _, d := range descs {
+			s.descriptors[d.Index] = d
+		}
+	}
+	if m.flags&flagPictureTable != 0 {
+		if pics, _, ok := parsePictureTable(data, pos); ok {
+			s.pictures = pics
+		}
+	}
+}
+
+// parseDrawStateInto applies a decrypted draw state message to s the way
+// parseDrawState does while seeking, without touching the global state, the
+// Players list, sounds, bubbles or images. Name tags and edge carry-over of
+// mobiles are skipped; playback rebuilds the former and the latter lasts a
+// frame.
+func parseDrawStateInto(s *drawState, data []byte, dropped int) error {
+	var msg drawStateMsg
+	if err := decodeDrawState(&msg, data); err != nil {
+		return err
+	}
+	applyDrawState(s, &msg, min(dropped, 2), false, false)
+	return nil
+}
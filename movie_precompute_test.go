@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+)
+
+// testDrawStatePacket builds a tagged draw state message with one unnamed
+// descriptor, the given pictures (repeating again from the last frame) and
+// one mobile.
+func testDrawStatePacket(ack int32, hp byte, again int, pics []framePicture, mob frameMobile) []byte {
+	m := []byte{0, 2, 0}
+	m = binary.BigEndian.AppendUint32(m, uint32(ack))
+	m = binary.BigEndian.AppendUint32(m, 0)
+	m = append(m, 1, mob.Index, kDescPlayer, 0x01, 0xbf, 0, 1, 7)
+	m = append(m, hp, 100, 50, 100, 20, 100, 0)
+	if again > 0 {
+		m = append(m, 255, byte(again), byte(len(pics)))
+	} else {
+		m = append(m, byte(len(pics)))
+	}
+	var bits []byte
+	var acc uint64
+	n := 0
+	put := func(v uint32, w int) {
+		acc = acc<<w | uint64(v)&(1<<w-1)
+		n += w
+		for n >= 8 {
+			bits = append(bits, byte(acc>>(n-8)))
+			n -= 8
+		}
+	}
+	for _, p := range pics {
+		put(uint32(p.PictID), 14)
+		put(uint32(p.H), 11)
+		put(uint32(p.V), 11)
+	}
+	if n > 0 {
+		bits = append(bits, byte(acc<<(8-n)))
+	}
+	m = append(m, bits...)
+	m = append(m, 1, mob.Index, mob.State)
+	m = binary.BigEndian.AppendUint16(m, uint16(mob.H))
+	m = binary.BigEndian.AppendUint16(m, uint16(mob.V))
+	m = append(m, mob.Colors)
+	m = binary.BigEndian.AppendUint16(m, 4)
+	return append(m, 0, 0, 0, 0)
+}
+
+// Test that background checkpoints match the state seeking would reach by
+// replaying frames, and that the global state is left alone.
+func TestPrecomputeCheckpoints(t *testing.T) {
+	origEncrypted := drawStateEncrypted
+	drawStateEncrypted = false
+	defer func() { drawStateEncrypted = origEncrypted }()
+	pixelCountMu.Lock()
+	origCache := pixelCountCache
+	pixelCountCache = map[uint16]int{7: 5000, 100: 1000}
+	pixelCountMu.Unlock()
+	defer func() {
+		pixelCountMu.Lock()
+		pixelCountCache = origCache
+		pixelCountMu.Unlock()
+	}()
+
+	var frames []movieFrame
+	for i := 0; i < 2*checkpointInterval+10; i++ {
+		pics := []framePicture{{PictID: 100, H: int16(i % 50), V: -4}}
+		again := 1
+		if i == 0 {
+			pics = append([]framePicture{{PictID: 7, H: 10, V: 10}}, pics...)
+			again = 0
+		}
+		mob := frameMobile{Index: 3, State: byte(i % 4), H: int16(i % 90), V: 5, Colors: 2}
+		frames = append(frames, movieFrame{index: int32(i + 1), data: testDrawStatePacket(int32(i+1), byte(i%100), again, pics, mob)})
+	}
+
+	resetDrawState()
+	initialState = cloneDrawState(state)
+	stateMu.Lock()
+	state.hp = 42
+	stateMu.Unlock()
+
+	p := &moviePlayer{frames: frames, checkpoints: []movieCheckpoint{{idx: 0, state: cloneDrawState(initialState)}}}
+	p.precompute(context.Background())
+	if len(p.checkpoints) != 3 || p.checkpoints[2].idx != 2*checkpointInterval {
+		t.Fatalf("checkpoints at %v", len(p.checkpoints))
+	}
+	if state.hp != 42 {
+		t.Fatalf("precompute changed global state")
+	}
+
+	movieMode, seekingMov = true, true
+	defer func() { movieMode, seekingMov = false, false }()
+	resetDrawState()
+	for _, m := range frames[:checkpointInterval] {
+		handleDrawState(m.data, false)
+	}
+	got := p.checkpoints[1].state
+	stateMu.Lock()
+	want := cloneDrawState(state)
+	stateMu.Unlock()
+	if got.hp != want.hp || got.sp != want.sp || got.prevHP != want.prevHP {
+		t.Fatalf("stats %d/%d/%d, want %d/%d/%d", got.hp, got.sp, got.prevHP, want.hp, want.sp, want.prevHP)
+	}
+	if len(got.pictures) != len(want.pictures) {
+		t.Fatalf("pictures %v, want %v", got.pictures, want.pictures)
+	}
+	for i := range got.pictures {
+		g, w := got.pictures[i], want.pictures[i]
+		if g.PictID != w.PictID || g.H != w.H || g.V != w.V || g.Again != w.Again {
+			t.Fatalf("picture %d = %+v, want %+v", i, g, w)
+		}
+	}
+	if g, w := got.mobiles[3], want.mobiles[3]; g.H != w.H || g.State != w.State || len(got.mobiles) != len(want.mobiles) {
+		t.Fatalf("mobile %+v, want %+v", g, w)
+	}
+	if g, w := got.descriptors[3], want.descriptors[3]; g.PictID != w.PictID || string(g.Colors) != string(w.Colors) {
+		t.Fatalf("descriptor %+v, want %+v", g, w)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replaySnapInterval is the number of draw states between replay buffer
+// snapshots. A saved replay starts at the oldest snapshot still buffered.
+const replaySnapInterval = 100
+
+// replayRing keeps the last few minutes of server messages in memory, with
+// periodic draw state snapshots to start from, so a moment can be saved as
+// a movie after it happened.
+type replayRing struct {
+	mu     sync.Mutex
+	frames []replayFrame
+	base   int // sequence number of frames[0]
+	snaps  []replaySnap
+}
+
+type replayFrame struct {
+	data  []byte
+	flags uint16
+}
+
+// replaySnap is the draw state just before frame seq arrived.
+type replaySnap struct {
+	seq   int
+	at    time.Time
+	state drawState
+}
+
+var (
+	replayBuf replayRing
+	// autoRecordUntil is when a recording started by an auto-record rule
+	// stops. It is zero for recordings started by hand. Only Update
+	// touches it.
+	autoRecordUntil time.Time
+
+	// autoRecordReason is the latest auto-record rule the network loops
+	// saw fire, waiting for Update to act on it.
+	autoRecordMu     sync.Mutex
+	autoRecordReason string
+)
+
+func (r *replayRing) reset() {
+	r.mu.Lock()
+	r.frames = nil
+	r.base = 0
+	r.snaps = nil
+	r.mu.Unlock()
+}
+
+// add appends a server message, keeping at most max frames. Called from
+// the network loop before the message is processed, so the global draw
+// state still reflects the frames before it.
+func (r *replayRing) add(m []byte, flags uint16, max int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seq := r.base + len(r.frames)
+	if len(m) >= 2 && m[0] == 0 && m[1] == 2 {
+		if n := len(r.snaps); n == 0 || seq-r.snaps[n-1].seq >= replaySnapInterval {
+			stateMu.Lock()
+			snap := replaySnap{seq: seq, at: time.Now(), state: cloneDrawState(state)}
+			stateMu.Unlock()
+			r.snaps = append(r.snaps, snap)
+		}
+	}
+	r.frames = append(r.frames, replayFrame{data: append([]byte(nil), m...), flags: flags})
+
+	// Trim in batches so the buffer isn't shifted on every frame.
+	if max > 0 && len(r.frames) > max+max/8 {
+		drop := len(r.frames) - max
+		r.frames = append([]replayFrame(nil), r.frames[drop:]...)
+		r.base += drop
+		i := 0
+		for i < len(r.snaps) && r.snaps[i].seq < r.base {
+			i++
+		}
+		r.snaps = append([]replaySnap(nil), r.snaps[i:]...)
+	}
+}
+
+// writeTo writes the buffered frames from the oldest snapshot on to mr. The
+// snapshot's descriptors and pictures are written as MobileData and
+// PictureTable blocks, along with the login GameState block, so the
+// movie decodes on its own. It returns the number of frames written.
+func (r *replayRing) writeTo(mr *movieRecorder, gameState []byte) (int, error) {
+	r.mu.Lock()
+	if len(r.snaps) == 0 {
+		r.mu.Unlock()
+		return 0, errors.New("instant replay is empty")
+	}
+	snap := r.snaps[0]
+	frames := r.frames[snap.seq-r.base:]
+	r.mu.Unlock()
+
+	if l := len(gameState); l > 0 {
+		mr.AddBlock(gameStateBlock(0, 0, 0, l, l, l, gameState), flagGameState)
+	}
+	if err := mr.WriteBlock(encodeMobileTable(snap.state.descriptors, uint16(clVersion)), flagMobileData); err != nil {
+		return 0, err
+	}
+	if err := mr.WriteBlock(encodePictureTable(snap.state.pictures), flagPictureTable); err != nil {
+		return 0, err
+	}
+	for _, f := range frames {
+		if err := mr.WriteFrame(f.data, f.flags); err != nil {
+			return 0, err
+		}
+	}
+	mr.head.StartTime = uint32(snap.at.Unix() + macEpochDelta)
+	return len(frames), nil
+}
+
+// replayFrames returns the replay buffer length in frames.
+func replayFrames() int {
+	min := gs.InstantReplayMinutes
+	if min < 1 {
+		min = 1
+	}
+	return min * 60 * clMovFPS
+}
+
+// instantReplayFrame records a server message in the replay buffer. It is
+// called by the network loops for every message.
+func instantReplayFrame(m []byte, flags uint16) {
+	if gs.InstantReplay {
+		replayBuf.add(m, flags, replayFrames())
+	}
+}
+
+// saveInstantReplay writes the replay buffer to a new movie in the Movies
+// folder.
+func saveInstantReplay() {
+	if isWASM {
+		consoleMessage("instant replay unavailable in browser build")
+		return
+	}
+	if !gs.InstantReplay {
+		consoleMessage("instant replay is off; enable it in Settings")
+		return
+	}
+	dir := filepath.Join(dataDirPath, "Movies")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logError("instant replay: %v", err)
+		return
+	}
+	base := gs.LastCharacter
+	if base == "" {
+		base = "movie"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s__replay__%s.clMov", base, time.Now().Format("2006-01-02-15-04-05")))
+	mr, err := newMovieRecorder(path, clVersion, int(movieRevision))
+	if err != nil {
+		logError("instant replay: %v", err)
+		return
+	}
+	n, err := replayBuf.writeTo(mr, loginGameState)
+	if cerr := mr.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		consoleMessage("instant replay: " + err.Error())
+		return
+	}
+	d := time.Duration(n) * time.Second / time.Duration(clMovFPS)
+	consoleMessage(fmt.Sprintf("saved instant replay: %s (%v)", filepath.Base(path), d.Round(time.Second)))
+}
+
+// autoRecordTrigger notes that reason happened. It is called from the
+// network loops, so the recording is started by runAutoRecord in Update.
+func autoRecordTrigger(reason string) {
+	autoRecordMu.Lock()
+	autoRecordReason = reason
+	autoRecordMu.Unlock()
+}
+
+// runAutoRecord starts or extends a recording for the last rule that fired
+// and stops an automatic recording whose time is up. Update calls it once
+// per tick.
+func runAutoRecord(now time.Time) {
+	autoRecordMu.Lock()
+	reason := autoRecordReason
+	autoRecordReason = ""
+	autoRecordMu.Unlock()
+	if reason != "" {
+		startAutoRecording(reason, now)
+	}
+	if recorder != nil && !autoRecordUntil.IsZero() && now.After(autoRecordUntil) {
+		consoleMessage("auto-recording stopped")
+		stopRecording()
+	}
+}
+
+// startAutoRecording starts recording because reason happened, or extends
+// a recording an earlier rule started. The replay buffer becomes the start
+// of the movie so the lead-up is kept.
+func startAutoRecording(reason string, now time.Time) {
+	if tcpConn == nil || playingMovie || clmov != "" || pcapPath != "" || fake || isWASM {
+		return
+	}
+	stop := gs.AutoRecordStopMinutes
+	if stop < 1 {
+		stop = 1
+	}
+	if recorder != nil {
+		if !autoRecordUntil.IsZero() {
+			autoRecordUntil = now.Add(time.Duration(stop) * time.Minute)
+		}
+		return
+	}
+	mr := newRecording()
+	if mr == nil {
+		return
+	}
+	// Write the buffer before the network loops see the recorder, so
+	// their frames follow it. A frame arriving in between is not kept.
+	wroteLoginBlocks = false
+	if gs.InstantReplay {
+		if _, err := replayBuf.writeTo(mr, loginGameState); err == nil {
+			wroteLoginBlocks = true
+		}
+	}
+	recorder = mr
+	autoRecordUntil = now.Add(time.Duration(stop) * time.Minute)
+	consoleMessage(fmt.Sprintf("recording to %s", filepath.Base(recordPath)))
+	consoleMessage("auto-recording: " + reason)
+	updateRecordButton()
+}
+
+// autoRecordText checks an info or chat line against the text rules in
+// gs.AutoRecordTriggers, e.g. the name of an area being entered.
+func autoRecordText(s string) {
+	if gs.AutoRecordTriggers == "" {
+		return
+	}
+	lower := strings.ToLower(s)
+	for _, t := range parseTags(gs.AutoRecordTriggers) {
+		if strings.Contains(lower, strings.ToLower(t)) {
+			autoRecordTrigger(fmt.Sprintf("%q", t))
+			return
+		}
+	}
+}
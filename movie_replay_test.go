@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// Test that the instant replay buffer trims to its length and saves a movie
+// that starts at a snapshot and carries the state from before it.
+func TestInstantReplay(t *testing.T) {
+	stateMu.Lock()
+	origState := state
+	state = drawState{
+		descriptors: map[uint8]frameDescriptor{3: {Index: 3, Type: kDescPlayer, PictID: 447, Name: "Alice"}},
+		pictures:    []framePicture{{PictID: 7, H: 10, V: -4}},
+	}
+	stateMu.Unlock()
+	defer func() {
+		stateMu.Lock()
+		state = origState
+		stateMu.Unlock()
+	}()
+
+	var r replayRing
+	var sent [][]byte
+	for i := 0; i < 200; i++ {
+		m := testDrawStatePacket(int32(i+1), 30, 0, nil, frameMobile{Index: 3, H: int16(i), V: 2})
+		sent = append(sent, m)
+		r.add(m, 0, 150)
+	}
+	if r.base+len(r.frames) != 200 || len(r.frames) < 150 {
+		t.Fatalf("base %d, %d frames", r.base, len(r.frames))
+	}
+	if len(r.snaps) != 1 || r.snaps[0].seq != 100 {
+		t.Fatalf("snapshots %+v", r.snaps)
+	}
+
+	path := filepath.Join(t.TempDir(), "replay.clMov")
+	mr, err := newMovieRecorder(path, clVersion, 0)
+	if err != nil {
+		t.Fatalf("newMovieRecorder: %v", err)
+	}
+	n, err := r.writeTo(mr, []byte("game state"))
+	if err != nil {
+		t.Fatalf("writeTo: %v", err)
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if n != 100 {
+		t.Fatalf("wrote %d frames, want 100", n)
+	}
+	if err := verifyClmov(path, clVersion); err != nil {
+		t.Fatalf("verifyClmov: %v", err)
+	}
+	frames, err := parseMovie(path, clVersion)
+	if err != nil {
+		t.Fatalf("parseMovie: %v", err)
+	}
+	var s drawState
+	var data [][]byte
+	for _, fr := range frames {
+		applyMovieBlocksTo(&s, fr)
+		if len(fr.data) > 0 {
+			data = append(data, fr.data)
+		}
+	}
+	if len(data) != 100 || !bytes.Equal(data[0], sent[100]) || !bytes.Equal(data[99], sent[199]) {
+		t.Fatalf("got %d frames", len(data))
+	}
+	if s.descriptors[3].Name != "Alice" || len(s.pictures) != 1 || s.pictures[0].PictID != 7 {
+		t.Fatalf("state %+v %+v", s.descriptors, s.pictures)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// stepFrame pauses playback and moves exactly one server frame forward
+// (dir > 0) or back (dir < 0). Steps are dropped while a seek or earlier
+// step is still running so holding a key doesn't queue them up.
+func (p *moviePlayer) stepFrame(dir int) {
+	if seekingMov || !seekLock.TryLock() {
+		return
+	}
+	p.pause()
+	go func() {
+		defer seekLock.Unlock()
+		if dir < 0 {
+			p.stepBack()
+		} else if p.cur < len(p.frames) {
+			p.step()
+		}
+		p.updateUI()
+	}()
+}
+
+// playReverse starts or stops playing backwards at the current speed.
+func (p *moviePlayer) playReverse(on bool) {
+	if !on || p.cur == 0 {
+		p.pause()
+		p.updateUI()
+		return
+	}
+	p.setReverse(true)
+	p.playing = true
+	playingMovie = true
+	p.updateUI()
+}
+
+func (p *moviePlayer) setReverse(on bool) {
+	p.reverse = on
+	reversePlayback = on
+}
+
+// stepBack moves back one frame. The states of the span replayed to get
+// there are kept in p.rewind, so only the first step back past a checkpoint
+// replays frames and the following ones just restore. Call with seekLock
+// held.
+func (p *moviePlayer) stepBack() {
+	idx := p.cur - 1
+	if idx < 0 {
+		return
+	}
+	if n := len(p.rewind); n > 0 && idx >= p.rewind[0].idx && idx < p.rewind[0].idx+n {
+		cp := p.rewind[idx-p.rewind[0].idx]
+		stateMu.Lock()
+		state = cloneDrawState(cp.state)
+		prepareRenderCacheLocked()
+		stateMu.Unlock()
+		frameCounter = cp.idx
+		p.cur = cp.idx
+		resetInterpolation()
+		suppressInterpOnce = true
+		p.updateUI()
+		return
+	}
+	p.seekCapture(idx, true)
+}
+
+// handleKeys applies the playback shortcuts: comma and period step one
+// frame (repeating while held), J plays backwards, K pauses and L plays.
+func (p *moviePlayer) handleKeys() {
+	held := func(k ebiten.Key) bool {
+		return inpututil.IsKeyJustPressed(k) || inpututil.KeyPressDuration(k) > 30
+	}
+	switch {
+	case held(ebiten.KeyComma):
+		p.stepFrame(-1)
+	case held(ebiten.KeyPeriod):
+		p.stepFrame(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyJ):
+		p.playReverse(true)
+	case inpututil.IsKeyJustPressed(ebiten.KeyK):
+		p.pause()
+		p.updateUI()
+	case inpututil.IsKeyJustPressed(ebiten.KeyL):
+		p.play()
+		p.updateUI()
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// netHealthSamples is how much history the network health window keeps:
+// five minutes at one sample per second.
+const netHealthSamples = 300
+
+// netSample summarizes one second of the connection.
+type netSample struct {
+	At          time.Time
+	Frames      int     // draw states received
+	TCPFrames   int     // of those, received over TCP
+	Dropped     int     // draw states the server sent that never arrived
+	IntervalMS  float64 // mean time between draw states
+	MaxInterval float64 // longest gap between draw states
+	LatencyMS   float64 // smoothed input to draw state round trip
+	JitterMS    float64
+	KeepAlives  int     // reliable keep-alive inputs sent over TCP
+	PingMS      float64 // TCP connect time when pinged this second
+}
+
+// netHealthLog collects network events into per-second samples and, when
+// gs.NetQualityLog is set, appends them to a CSV file per session.
+type netHealthLog struct {
+	mu      sync.Mutex
+	cur     netSample
+	ivSum   time.Duration
+	ivCount int
+	samples []netSample
+
+	csvFile *os.File
+	csv     *csv.Writer
+	csvPath string
+}
+
+var netHealth netHealthLog
+
+var netSampleCSVHeader = []string{
+	"time", "frames", "tcp_frames", "dropped", "interval_ms", "max_interval_ms",
+	"latency_ms", "jitter_ms", "keepalives", "ping_ms",
+}
+
+// frame counts a received draw state.
+func (h *netHealthLog) frame(viaTCP bool) {
+	h.mu.Lock()
+	h.cur.Frames++
+	if viaTCP {
+		h.cur.TCPFrames++
+	}
+	h.mu.Unlock()
+}
+
+// interval records the time since the previous draw state.
+func (h *netHealthLog) interval(dt time.Duration) {
+	h.mu.Lock()
+	h.ivSum += dt
+	h.ivCount++
+	if ms := float64(dt) / float64(time.Millisecond); ms > h.cur.MaxInterval {
+		h.cur.MaxInterval = ms
+	}
+	h.mu.Unlock()
+}
+
+func (h *netHealthLog) dropped(n int) {
+	if n <= 0 {
+		return
+	}
+	h.mu.Lock()
+	h.cur.Dropped += n
+	h.mu.Unlock()
+}
+
+func (h *netHealthLog) keepAlive() {
+	h.mu.Lock()
+	h.cur.KeepAlives++
+	h.mu.Unlock()
+}
+
+func (h *netHealthLog) ping(d time.Duration) {
+	h.mu.Lock()
+	h.cur.PingMS = float64(d) / float64(time.Millisecond)
+	h.mu.Unlock()
+}
+
+// sample closes the current second and adds it to the history.
+func (h *netHealthLog) sample(now time.Time, latency, jitter time.Duration) netSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.cur
+	s.At = now
+	if h.ivCount > 0 {
+		s.IntervalMS = float64(h.ivSum) / float64(h.ivCount) / float64(time.Millisecond)
+	}
+	s.LatencyMS = float64(latency) / float64(time.Millisecond)
+	s.JitterMS = float64(jitter) / float64(time.Millisecond)
+	h.cur = netSample{}
+	h.ivSum, h.ivCount = 0, 0
+
+	h.samples = append(h.samples, s)
+	if n := len(h.samples); n > netHealthSamples+netHealthSamples/4 {
+		h.samples = append(h.samples[:0], h.samples[n-netHealthSamples:]...)
+	}
+	if h.csv != nil {
+		h.csv.Write(netSampleCSVRow(s))
+		h.csv.Flush()
+	}
+	return s
+}
+
+// history returns up to the last netHealthSamples samples, oldest first.
+func (h *netHealthLog) history() []netSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.samples
+	if len(s) > netHealthSamples {
+		s = s[len(s)-netHealthSamples:]
+	}
+	return append([]netSample(nil), s...)
+}
+
+// reset clears the history for a new session.
+func (h *netHealthLog) reset() {
+	h.mu.Lock()
+	h.cur = netSample{}
+	h.ivSum, h.ivCount = 0, 0
+	h.samples = nil
+	h.mu.Unlock()
+}
+
+// openLog starts a CSV file in dir for the session of character char.
+func (h *netHealthLog) openLog(dir, char string, now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.csvFile != nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := sanitizeName(char)
+	if base == "" {
+		base = "session"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("net-%s-%s.csv", base, now.Format("20060102-150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	h.csvFile, h.csvPath = f, path
+	h.csv = csv.NewWriter(f)
+	h.csv.Write(netSampleCSVHeader)
+	h.csv.Flush()
+	return h.csv.Error()
+}
+
+// closeLog finishes the session's CSV file and returns its path.
+func (h *netHealthLog) closeLog() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.csvFile == nil {
+		return ""
+	}
+	h.csv.Flush()
+	h.csvFile.Close()
+	path := h.csvPath
+	h.csvFile, h.csv, h.csvPath = nil, nil, ""
+	return path
+}
+
+func netSampleCSVRow(s netSample) []string {
+	ms := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	itoa := strconv.Itoa
+	return []string{
+		s.At.Format(time.RFC3339), itoa(s.Frames), itoa(s.TCPFrames), itoa(s.Dropped),
+		ms(s.IntervalMS), ms(s.MaxInterval), ms(s.LatencyMS), ms(s.JitterMS),
+		itoa(s.KeepAlives), ms(s.PingMS),
+	}
+}
+
+// writeNetSamplesCSV writes samples with a header line.
+func writeNetSamplesCSV(w io.Writer, samples []netSample) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(netSampleCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if err := cw.Write(netSampleCSVRow(s)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// netHealthSummary describes samples for the window's status line.
+type netHealthSummary struct {
+	Frames, TCPFrames, Dropped, KeepAlives int
+	IntervalMS, MaxInterval                float64
+	LatencyMS, JitterMS                    float64
+}
+
+func summarizeNetSamples(samples []netSample) netHealthSummary {
+	var sum netHealthSummary
+	var ivWeighted float64
+	for _, s := range samples {
+		sum.Frames += s.Frames
+		sum.TCPFrames += s.TCPFrames
+		sum.Dropped += s.Dropped
+		sum.KeepAlives += s.KeepAlives
+		ivWeighted += s.IntervalMS * float64(s.Frames)
+		if s.MaxInterval > sum.MaxInterval {
+			sum.MaxInterval = s.MaxInterval
+		}
+	}
+	if sum.Frames > 0 {
+		sum.IntervalMS = ivWeighted / float64(sum.Frames)
+	}
+	if n := len(samples); n > 0 {
+		sum.LatencyMS = samples[n-1].LatencyMS
+		sum.JitterMS = samples[n-1].JitterMS
+	}
+	return sum
+}
+
+// lastNetSample is when updateNetHealth last closed a sample.
+var lastNetSample time.Time
+
+// updateNetHealth is called every tick. Once a second while connected it
+// samples the connection, logs the sample and refreshes the window.
+func updateNetHealth(now time.Time) {
+	if now.Sub(lastNetSample) < time.Second {
+		return
+	}
+	lastNetSample = now
+	// Keep sampling through a reconnect so the gap shows in the graphs and
+	// the session's log.
+	if (tcpConn == nil && !reconnectPending()) || playingMovie {
+		if path := netHealth.closeLog(); path != "" {
+			logDebug("network quality log saved to %s", path)
+		}
+		return
+	}
+	if gs.NetQualityLog && !isWASM {
+		char := playerName
+		if char == "" {
+			char = gs.LastCharacter
+		}
+		if err := netHealth.openLog("logs", char, now); err != nil {
+			logError("network quality log: %v", err)
+			gs.NetQualityLog = false
+		}
+	} else {
+		netHealth.closeLog()
+	}
+	latencyMu.Lock()
+	latency, jitter := netLatency, netJitter
+	latencyMu.Unlock()
+	netHealth.sample(now, latency, jitter)
+	if netHealthWin != nil && netHealthWin.IsOpen() {
+		refreshNetHealthWindow()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetHealthSample(t *testing.T) {
+	var h netHealthLog
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	h.frame(false)
+	h.frame(false)
+	h.frame(true)
+	h.interval(180 * time.Millisecond)
+	h.interval(220 * time.Millisecond)
+	h.dropped(2)
+	h.dropped(0)
+	h.keepAlive()
+	s := h.sample(start, 90*time.Millisecond, 12*time.Millisecond)
+	if s.Frames != 3 || s.TCPFrames != 1 || s.Dropped != 2 || s.KeepAlives != 1 {
+		t.Fatalf("counts %+v", s)
+	}
+	if s.IntervalMS != 200 || s.MaxInterval != 220 || s.LatencyMS != 90 || s.JitterMS != 12 {
+		t.Fatalf("timings %+v", s)
+	}
+	if next := h.sample(start.Add(time.Second), 0, 0); next.Frames != 0 || next.IntervalMS != 0 {
+		t.Fatalf("sample not reset: %+v", next)
+	}
+
+	for i := 0; i < 2*netHealthSamples; i++ {
+		h.sample(start.Add(time.Duration(i+2)*time.Second), 0, 0)
+	}
+	hist := h.history()
+	if len(hist) != netHealthSamples {
+		t.Fatalf("history has %d samples", len(hist))
+	}
+	if want := start.Add(time.Duration(2*netHealthSamples+1) * time.Second); !hist[len(hist)-1].At.Equal(want) {
+		t.Fatalf("last sample at %v, want %v", hist[len(hist)-1].At, want)
+	}
+}
+
+func TestNetHealthCSV(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	samples := []netSample{
+		{At: at, Frames: 5, TCPFrames: 1, Dropped: 1, IntervalMS: 200, MaxInterval: 250, LatencyMS: 80.26, JitterMS: 4},
+		{At: at.Add(time.Second), Frames: 3, KeepAlives: 1, IntervalMS: 300, MaxInterval: 400, PingMS: 40},
+	}
+	var buf bytes.Buffer
+	if err := writeNetSamplesCSV(&buf, samples); err != nil {
+		t.Fatal(err)
+	}
+	want := "time,frames,tcp_frames,dropped,interval_ms,max_interval_ms,latency_ms,jitter_ms,keepalives,ping_ms\n" +
+		"2024-05-01T12:00:00Z,5,1,1,200.0,250.0,80.3,4.0,0,0.0\n" +
+		"2024-05-01T12:00:01Z,3,0,0,300.0,400.0,0.0,0.0,1,40.0\n"
+	if buf.String() != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	sum := summarizeNetSamples(samples)
+	if sum.Frames != 8 || sum.TCPFrames != 1 || sum.Dropped != 1 || sum.KeepAlives != 1 || sum.MaxInterval != 400 {
+		t.Fatalf("summary %+v", sum)
+	}
+	if sum.IntervalMS != 237.5 {
+		t.Fatalf("mean interval %v", sum.IntervalMS)
+	}
+}
+
+func TestNetHealthLogFile(t *testing.T) {
+	var h netHealthLog
+	dir := t.TempDir()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := h.openLog(dir, "Tester", at); err != nil {
+		t.Fatal(err)
+	}
+	h.frame(false)
+	h.sample(at, 0, 0)
+	path := h.closeLog()
+	if filepath.Base(path) != "net-Tester-20240501-120000.csv" {
+		t.Fatalf("log path %q", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "2024-05-01T12:00:00Z,1,") {
+		t.Fatalf("log contents %q", data)
+	}
+}
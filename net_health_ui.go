@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"gothoom/eui"
+)
+
+const (
+	netGraphW = 480
+	netGraphH = 56
+)
+
+// netGraph is one labelled graph of the network health window.
+type netGraph struct {
+	label *eui.ItemData
+	item  *eui.ItemData
+	img   *ebiten.Image
+}
+
+var (
+	netHealthWin    *eui.WindowData
+	netHealthStatus *eui.ItemData
+	netGraphs       struct{ interval, latency, jitter, dropped, share netGraph }
+)
+
+var (
+	netGraphBG        = color.NRGBA{24, 24, 24, 255}
+	netGraphGrid      = color.NRGBA{70, 70, 70, 255}
+	netGraphLine      = color.NRGBA{80, 200, 255, 255}
+	netGraphMaxLine   = color.NRGBA{80, 110, 160, 255}
+	netGraphDropped   = color.NRGBA{230, 70, 60, 255}
+	netGraphUDP       = color.NRGBA{70, 190, 90, 255}
+	netGraphTCP       = color.NRGBA{240, 150, 40, 255}
+	netGraphKeepAlive = color.NRGBA{240, 230, 90, 255}
+)
+
+// makeNetHealthWindow opens rolling graphs of the connection: frame
+// interval, latency, jitter, dropped frames and how draw states arrive.
+func makeNetHealthWindow() {
+	if netHealthWin != nil {
+		netHealthWin.MarkOpen()
+		refreshNetHealthWindow()
+		return
+	}
+	win := eui.NewWindow()
+	win.Title = "Network Health"
+	win.Closable = true
+	win.Movable = true
+	win.Resizable = false
+	win.AutoSize = true
+	win.SetZone(eui.HZoneCenter, eui.VZoneMiddleTop)
+
+	flow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL}
+
+	netHealthStatus, _ = eui.NewText()
+	netHealthStatus.Size = eui.Point{X: netGraphW, Y: 20}
+	netHealthStatus.FontSize = 11
+	flow.AddItem(netHealthStatus)
+
+	controls := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL, Size: eui.Point{X: netGraphW, Y: 28}}
+
+	pingBtn, pingEvents := eui.NewButton()
+	pingBtn.Text = "Ping"
+	pingBtn.Size = eui.Point{X: 80, Y: 24}
+	pingBtn.SetTooltip("Time a new TCP connection to the server, apart from the game's traffic")
+	pingEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick && tcpConn != nil {
+			go func() {
+				if d := pingServer(); d > 0 {
+					netHealth.ping(d)
+				}
+			}()
+		}
+	}
+	controls.AddItem(pingBtn)
+
+	logCB, logEvents := eui.NewCheckbox()
+	logCB.Text = "Log sessions to CSV"
+	logCB.Size = eui.Point{X: 200, Y: 24}
+	logCB.Checked = gs.NetQualityLog
+	logCB.SetTooltip("Write one line per second of every session to logs/net-<character>-<time>.csv")
+	logEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventCheckboxChanged {
+			SettingsLock.Lock()
+			gs.NetQualityLog = ev.Checked
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	controls.AddItem(logCB)
+
+	exportBtn, exportEvents := eui.NewButton()
+	exportBtn.Text = "Export"
+	exportBtn.Size = eui.Point{X: 80, Y: 24}
+	exportBtn.SetTooltip("Save the graphed history as CSV")
+	exportEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			exportNetHealth()
+		}
+	}
+	controls.AddItem(exportBtn)
+	flow.AddItem(controls)
+
+	for _, g := range []*netGraph{&netGraphs.interval, &netGraphs.latency, &netGraphs.jitter, &netGraphs.dropped, &netGraphs.share} {
+		g.label, _ = eui.NewText()
+		g.label.Size = eui.Point{X: netGraphW, Y: 16}
+		g.label.FontSize = 10
+		flow.AddItem(g.label)
+		g.item, g.img = eui.NewImageItem(netGraphW, netGraphH)
+		flow.AddItem(g.item)
+	}
+
+	win.AddItem(flow)
+	netHealthWin = win
+	win.AddWindow(false)
+	refreshNetHealthWindow()
+	win.MarkOpen()
+}
+
+// refreshNetHealthWindow redraws the graphs from the sample history.
+func refreshNetHealthWindow() {
+	if netHealthWin == nil || netHealthStatus == nil {
+		return
+	}
+	samples := netHealth.history()
+	sum := summarizeNetSamples(samples)
+	var ping float64
+	for i := len(samples) - 1; i >= 0; i-- {
+		if samples[i].PingMS > 0 {
+			ping = samples[i].PingMS
+			break
+		}
+	}
+
+	status := "Not connected"
+	switch {
+	case reconnectPending():
+		status = "Reconnecting..."
+	case tcpConn != nil:
+		status = fmt.Sprintf("%s, last %v", tcpConn.RemoteAddr(), time.Duration(len(samples))*time.Second)
+	}
+	netHealthStatus.Text = status
+	netHealthStatus.Dirty = true
+
+	n := len(samples)
+	interval := make([]float64, n)
+	maxInterval := make([]float64, n)
+	latency := make([]float64, n)
+	jitter := make([]float64, n)
+	dropped := make([]float64, n)
+	udp := make([]float64, n)
+	tcp := make([]float64, n)
+	keep := make([]float64, n)
+	for i, s := range samples {
+		interval[i], maxInterval[i] = s.IntervalMS, s.MaxInterval
+		latency[i], jitter[i] = s.LatencyMS, s.JitterMS
+		dropped[i] = float64(s.Dropped)
+		udp[i], tcp[i] = float64(s.Frames-s.TCPFrames), float64(s.TCPFrames)
+		keep[i] = float64(s.KeepAlives)
+	}
+
+	g := &netGraphs.interval
+	setNetGraphLabel(g, fmt.Sprintf("Frame interval: avg %.0f ms, max %.0f ms", sum.IntervalMS, sum.MaxInterval))
+	top := netGraphTop(maxInterval, 2*framems)
+	beginNetGraph(g, top, framems)
+	drawNetLine(g.img, maxInterval, top, netGraphMaxLine)
+	drawNetLine(g.img, interval, top, netGraphLine)
+
+	g = &netGraphs.latency
+	label := fmt.Sprintf("Latency: %.0f ms", sum.LatencyMS)
+	if ping > 0 {
+		label += fmt.Sprintf(" (ping %.0f ms)", ping)
+	}
+	setNetGraphLabel(g, label)
+	top = netGraphTop(latency, 100)
+	beginNetGraph(g, top, 0)
+	drawNetLine(g.img, latency, top, netGraphLine)
+
+	g = &netGraphs.jitter
+	setNetGraphLabel(g, fmt.Sprintf("Jitter: %.0f ms", sum.JitterMS))
+	top = netGraphTop(jitter, 50)
+	beginNetGraph(g, top, 0)
+	drawNetLine(g.img, jitter, top, netGraphLine)
+
+	g = &netGraphs.dropped
+	lossPct := 0.0
+	if total := sum.Frames + sum.Dropped; total > 0 {
+		lossPct = float64(sum.Dropped) * 100 / float64(total)
+	}
+	setNetGraphLabel(g, fmt.Sprintf("Dropped frames: %d (%.1f%%)", sum.Dropped, lossPct))
+	top = netGraphTop(dropped, 5)
+	beginNetGraph(g, top, 0)
+	drawNetBars(g.img, dropped, nil, top, netGraphDropped)
+
+	g = &netGraphs.share
+	tcpPct := 0.0
+	if sum.Frames > 0 {
+		tcpPct = float64(sum.TCPFrames) * 100 / float64(sum.Frames)
+	}
+	setNetGraphLabel(g, fmt.Sprintf("Frames: %.0f%% UDP, %.0f%% TCP; keep-alives: %d", 100-tcpPct, tcpPct, sum.KeepAlives))
+	top = netGraphTop(udp, 1000/framems+1)
+	beginNetGraph(g, top, 0)
+	drawNetBars(g.img, udp, nil, top, netGraphUDP)
+	drawNetBars(g.img, tcp, udp, top, netGraphTCP)
+	drawNetMarks(g.img, keep, netGraphKeepAlive)
+
+	netHealthWin.Refresh()
+}
+
+func setNetGraphLabel(g *netGraph, text string) {
+	g.label.Text = text
+	g.label.Dirty = true
+	g.item.Dirty = true
+}
+
+// netGraphTop is the value at the top of a graph: the largest value with
+// some headroom, but at least floor so quiet graphs stay flat.
+func netGraphTop(vals []float64, floor float64) float64 {
+	top := floor
+	for _, v := range vals {
+		if v > top {
+			top = v
+		}
+	}
+	return top * 1.1
+}
+
+// beginNetGraph clears a graph and draws a reference line at ref, if set.
+func beginNetGraph(g *netGraph, top, ref float64) {
+	g.img.Fill(netGraphBG)
+	if ref > 0 && ref < top {
+		y := netGraphY(ref, top)
+		vector.StrokeLine(g.img, 0, y, netGraphW, y, 1, netGraphGrid, false)
+	}
+}
+
+func netGraphX(i, n int) float32 {
+	step := float32(netGraphW) / netHealthSamples
+	return netGraphW - float32(n-i)*step
+}
+
+func netGraphY(v, top float64) float32 {
+	return float32(netGraphH - v/top*netGraphH)
+}
+
+func drawNetLine(img *ebiten.Image, vals []float64, top float64, col color.Color) {
+	for i := 1; i < len(vals); i++ {
+		vector.StrokeLine(img,
+			netGraphX(i-1, len(vals)), netGraphY(vals[i-1], top),
+			netGraphX(i, len(vals)), netGraphY(vals[i], top),
+			1.5, col, true)
+	}
+}
+
+// drawNetBars draws a bar per sample, stacked on base when given.
+func drawNetBars(img *ebiten.Image, vals, base []float64, top float64, col color.Color) {
+	w := float32(netGraphW) / netHealthSamples
+	for i, v := range vals {
+		if v <= 0 {
+			continue
+		}
+		b := 0.0
+		if base != nil {
+			b = base[i]
+		}
+		y0, y1 := netGraphY(b, top), netGraphY(b+v, top)
+		vector.FillRect(img, netGraphX(i, len(vals)), y1, w, y0-y1, col, false)
+	}
+}
+
+// drawNetMarks draws a full height tick for every sample with an event.
+func drawNetMarks(img *ebiten.Image, vals []float64, col color.Color) {
+	for i, v := range vals {
+		if v > 0 {
+			x := netGraphX(i, len(vals))
+			vector.StrokeLine(img, x, 0, x, netGraphH, 1, col, false)
+		}
+	}
+}
+
+// exportNetHealth saves the sample history to the data directory.
+func exportNetHealth() {
+	samples := netHealth.history()
+	if len(samples) == 0 {
+		consoleMessage("network export: no samples")
+		return
+	}
+	path := filepath.Join(dataDirPath, fmt.Sprintf("network__%s.csv", time.Now().Format("2006-01-02-15-04-05")))
+	f, err := os.Create(path)
+	if err != nil {
+		logError("network export: %v", err)
+		return
+	}
+	err = writeNetSamplesCSV(f, samples)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logError("network export: %v", err)
+		return
+	}
+	consoleMessage(fmt.Sprintf("exported %d seconds of network samples to %s", len(samples), path))
+}
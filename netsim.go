@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Network condition simulator: a debug mode that adds latency, jitter,
+// packet loss, reordering and duplication to the server connection and to
+// movie playback, so interpolation and input handling can be tried on a
+// bad network at will. A fixed seed makes the packet fates repeatable.
+
+// netSimConfig describes the simulated conditions.
+type netSimConfig struct {
+	Latency   time.Duration // one-way delay added to every packet
+	Jitter    time.Duration // random extra delay, up to this much
+	Loss      float64       // fraction of datagrams dropped
+	Reorder   float64       // fraction of datagrams held back a frame
+	Duplicate float64       // fraction of datagrams delivered twice
+	Seed      int64
+}
+
+// netSimCfg is the active configuration, from -netsim or the debug window.
+var (
+	netSimMu  sync.Mutex
+	netSimCfg netSimConfig
+)
+
+// netSimReorderDelay holds a reordered datagram back long enough for the
+// next frame to overtake it.
+const netSimReorderDelay = (framems + 50) * time.Millisecond
+
+func (c netSimConfig) enabled() bool {
+	return c.Latency > 0 || c.Jitter > 0 || c.Loss > 0 || c.Reorder > 0 || c.Duplicate > 0
+}
+
+func (c netSimConfig) String() string {
+	if !c.enabled() {
+		return "off"
+	}
+	var parts []string
+	if c.Latency > 0 {
+		parts = append(parts, "latency="+c.Latency.String())
+	}
+	if c.Jitter > 0 {
+		parts = append(parts, "jitter="+c.Jitter.String())
+	}
+	pct := func(name string, v float64) {
+		if v > 0 {
+			parts = append(parts, name+"="+strconv.FormatFloat(v*100, 'f', -1, 64)+"%")
+		}
+	}
+	pct("loss", c.Loss)
+	pct("reorder", c.Reorder)
+	pct("dup", c.Duplicate)
+	if c.Seed != 0 {
+		parts = append(parts, "seed="+strconv.FormatInt(c.Seed, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseNetSim parses a comma separated list such as
+// "latency=150ms,jitter=40ms,loss=5%,reorder=1%,dup=1%,seed=7". Fractions
+// may be written as percentages or as 0-1. An empty string or "off"
+// disables the simulator.
+func parseNetSim(s string) (netSimConfig, error) {
+	var c netSimConfig
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "off") {
+		return c, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return c, fmt.Errorf("netsim: %q is not key=value", part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		var err error
+		switch key {
+		case "latency", "delay":
+			c.Latency, err = time.ParseDuration(val)
+		case "jitter":
+			c.Jitter, err = time.ParseDuration(val)
+		case "loss":
+			c.Loss, err = parseNetSimFraction(val)
+		case "reorder":
+			c.Reorder, err = parseNetSimFraction(val)
+		case "dup", "duplicate":
+			c.Duplicate, err = parseNetSimFraction(val)
+		case "seed":
+			c.Seed, err = strconv.ParseInt(val, 10, 64)
+		default:
+			return c, fmt.Errorf("netsim: unknown setting %q", key)
+		}
+		if err != nil {
+			return c, fmt.Errorf("netsim: %s: %w", key, err)
+		}
+		if c.Latency < 0 || c.Jitter < 0 {
+			return c, fmt.Errorf("netsim: %s must not be negative", key)
+		}
+	}
+	return c, nil
+}
+
+func parseNetSimFraction(s string) (float64, error) {
+	pct := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if pct {
+		v /= 100
+	}
+	if v < 0 || v > 1 {
+		return 0, fmt.Errorf("%s is not between 0 and 100%%", s)
+	}
+	return v, nil
+}
+
+func setNetSim(c netSimConfig) {
+	netSimMu.Lock()
+	netSimCfg = c
+	netSimMu.Unlock()
+}
+
+func currentNetSim() netSimConfig {
+	netSimMu.Lock()
+	defer netSimMu.Unlock()
+	return netSimCfg
+}
+
+// netSim decides what happens to each packet. Its random choices come from
+// the configured seed, so the same packets meet the same fates.
+type netSim struct {
+	mu   sync.Mutex
+	cfg  netSimConfig
+	rng  *rand.Rand
+	last time.Duration // latest in-order delivery, for streams
+}
+
+func newNetSim(cfg netSimConfig) *netSim {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &netSim{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// delays returns how long after sending a packet each copy of it arrives:
+// none when it is lost, two when duplicated. Only lossy packets (UDP game
+// messages) can be lost, reordered or duplicated; the rest arrive once and
+// in order, like bytes on a TCP stream. now is the send time relative to
+// any fixed origin.
+func (s *netSim) delays(now time.Duration, lossy bool) []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		d += time.Duration(s.rng.Int63n(int64(s.cfg.Jitter) + 1))
+	}
+	if !lossy {
+		// Streams never overtake themselves.
+		if now+d < s.last {
+			d = s.last - now
+		}
+		s.last = now + d
+		return []time.Duration{d}
+	}
+	if s.cfg.Loss > 0 && s.rng.Float64() < s.cfg.Loss {
+		return nil
+	}
+	if s.cfg.Reorder > 0 && s.rng.Float64() < s.cfg.Reorder {
+		d += netSimReorderDelay
+	}
+	out := []time.Duration{d}
+	if s.cfg.Duplicate > 0 && s.rng.Float64() < s.cfg.Duplicate {
+		extra := s.cfg.Latency
+		if s.cfg.Jitter > 0 {
+			extra += time.Duration(s.rng.Int63n(int64(s.cfg.Jitter) + 1))
+		}
+		out = append(out, extra)
+	}
+	return out
+}
+
+// isGameDatagram reports whether a UDP payload is a length prefixed game
+// message. Only those are subject to loss, so the login handshake
+// (ff ff id) always gets through.
+func isGameDatagram(b []byte) bool {
+	return len(b) >= 4 && int(binary.BigEndian.Uint16(b)) == len(b)-2
+}
+
+// netSimQueue delivers packets at their scheduled times, in time order and
+// in push order for equal times.
+type netSimQueue struct {
+	mu      sync.Mutex
+	pkts    []netSimPacket
+	wake    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	deliver func([]byte)
+}
+
+type netSimPacket struct {
+	at   time.Time
+	data []byte
+}
+
+func newNetSimQueue(deliver func([]byte)) *netSimQueue {
+	q := &netSimQueue{wake: make(chan struct{}, 1), done: make(chan struct{}), deliver: deliver}
+	go q.run()
+	return q
+}
+
+func (q *netSimQueue) push(at time.Time, data []byte) {
+	q.mu.Lock()
+	p := netSimPacket{at: at, data: data}
+	i := sort.Search(len(q.pkts), func(i int) bool { return q.pkts[i].at.After(at) })
+	q.pkts = append(q.pkts, netSimPacket{})
+	copy(q.pkts[i+1:], q.pkts[i:])
+	q.pkts[i] = p
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *netSimQueue) close() {
+	q.once.Do(func() { close(q.done) })
+}
+
+func (q *netSimQueue) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		var due []netSimPacket
+		now := time.Now()
+		for len(q.pkts) > 0 && !q.pkts[0].at.After(now) {
+			due = append(due, q.pkts[0])
+			q.pkts = q.pkts[1:]
+		}
+		wait := time.Hour
+		if len(q.pkts) > 0 {
+			wait = time.Until(q.pkts[0].at)
+		}
+		q.mu.Unlock()
+		for _, p := range due {
+			q.deliver(p.data)
+		}
+		if len(due) > 0 {
+			continue
+		}
+		timer.Reset(wait)
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// netSimConn wraps a connection with simulated conditions in both
+// directions. Datagram connections may lose, reorder and duplicate game
+// messages; stream connections only add delay. Read deadlines are honored
+// locally; writes are queued and never block.
+type netSimConn struct {
+	net.Conn
+	stream bool
+	start  time.Time
+	in     chan []byte
+	simIn  *netSim
+	simOut *netSim
+	inq    *netSimQueue
+	outq   *netSimQueue
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	readErr      error
+	writeErr     error
+	pending      []byte
+}
+
+// wrapNetSim returns conn under the active simulated conditions, or conn
+// itself when the simulator is off.
+func wrapNetSim(conn net.Conn, network string) net.Conn {
+	cfg := currentNetSim()
+	if !cfg.enabled() {
+		return conn
+	}
+	return newNetSimConn(conn, !strings.HasPrefix(network, "udp"), cfg)
+}
+
+func newNetSimConn(conn net.Conn, stream bool, cfg netSimConfig) *netSimConn {
+	c := &netSimConn{Conn: conn, stream: stream, start: time.Now(), in: make(chan []byte, 1024)}
+	c.simIn = newNetSim(cfg)
+	if cfg.Seed != 0 {
+		cfg.Seed++
+	}
+	c.simOut = newNetSim(cfg)
+	c.inq = newNetSimQueue(func(b []byte) {
+		if b == nil {
+			close(c.in)
+			return
+		}
+		if c.stream {
+			select {
+			case c.in <- b:
+			case <-c.inq.done:
+			}
+			return
+		}
+		select {
+		case c.in <- b:
+		default: // a full receive buffer drops like a socket's would
+		}
+	})
+	c.outq = newNetSimQueue(func(b []byte) {
+		if _, err := c.Conn.Write(b); err != nil {
+			c.mu.Lock()
+			if c.writeErr == nil {
+				c.writeErr = err
+			}
+			c.mu.Unlock()
+		}
+	})
+	go c.readLoop()
+	return c
+}
+
+func (c *netSimConn) schedule(sim *netSim, q *netSimQueue, b []byte) {
+	now := time.Now()
+	lossy := !c.stream && isGameDatagram(b)
+	for _, d := range sim.delays(now.Sub(c.start), lossy) {
+		q.push(now.Add(d), b)
+	}
+}
+
+func (c *netSimConn) readLoop() {
+	buf := make([]byte, 65536)
+	for {
+		n, err := c.Conn.Read(buf)
+		if n > 0 {
+			c.schedule(c.simIn, c.inq, append([]byte(nil), buf[:n]...))
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.readErr = err
+			c.mu.Unlock()
+			// Report the error once the packets before it arrived.
+			cfg := c.simIn.cfg
+			c.inq.push(time.Now().Add(cfg.Latency+cfg.Jitter+netSimReorderDelay), nil)
+			return
+		}
+	}
+}
+
+func (c *netSimConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		c.mu.Unlock()
+		return n, nil
+	}
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case p, ok := <-c.in:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return 0, c.readErr
+		}
+		n := copy(b, p)
+		if c.stream && n < len(p) {
+			c.mu.Lock()
+			c.pending = p[n:]
+			c.mu.Unlock()
+		}
+		return n, nil
+	case <-c.inq.done:
+		return 0, net.ErrClosed
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *netSimConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	err := c.writeErr
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	c.schedule(c.simOut, c.outq, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *netSimConn) Close() error {
+	c.inq.close()
+	c.outq.close()
+	return c.Conn.Close()
+}
+
+func (c *netSimConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *netSimConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is a no-op: writes are queued and never block.
+func (c *netSimConn) SetWriteDeadline(time.Time) error { return nil }
+
+// movieNetSim passes movie frames through the simulator. It runs on movie
+// time: frame i is sent at i*framems, so a seeded simulation drops and
+// delays the same frames at any playback speed. Only plain draw states can
+// be lost, reordered or duplicated, as they would be over UDP; frames
+// carrying other messages or game state blocks arrive in order, like the
+// TCP stream they come from, since losing one would corrupt the rest of
+// the playback.
+type movieNetSim struct {
+	mu      sync.Mutex // step and seek may run on different goroutines
+	cfg     netSimConfig
+	sim     *netSim
+	pending []movieNetFrame // in arrival order
+}
+
+type movieNetFrame struct {
+	at time.Duration
+	m  movieFrame
+}
+
+var movieSim movieNetSim
+
+func movieFrameTime(idx int) time.Duration {
+	return time.Duration(idx) * framems * time.Millisecond
+}
+
+// movieFrameLossy reports whether m may be lost like a UDP draw state.
+func movieFrameLossy(m movieFrame) bool {
+	if m.flags&(flagGameState|flagMobileData|flagPictureTable) != 0 {
+		return false
+	}
+	return len(m.data) >= 2 && binary.BigEndian.Uint16(m.data[:2]) == 2
+}
+
+// send schedules frame idx and returns the frames that have arrived by the
+// time it was sent, in arrival order.
+func (s *movieNetSim) send(idx int, m movieFrame) []movieFrame {
+	cfg := currentNetSim()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg != s.cfg || s.sim == nil {
+		s.cfg, s.sim, s.pending = cfg, newNetSim(cfg), nil
+	}
+	now := movieFrameTime(idx)
+	for _, d := range s.sim.delays(now, movieFrameLossy(m)) {
+		at := now + d
+		i := sort.Search(len(s.pending), func(i int) bool { return s.pending[i].at > at })
+		s.pending = append(s.pending, movieNetFrame{})
+		copy(s.pending[i+1:], s.pending[i:])
+		s.pending[i] = movieNetFrame{at: at, m: m}
+	}
+	var due []movieFrame
+	for len(s.pending) > 0 && s.pending[0].at <= now {
+		due = append(due, s.pending[0].m)
+		s.pending = s.pending[1:]
+	}
+	return due
+}
+
+// reset forgets frames in flight, e.g. after a seek, and restarts the
+// simulation.
+func (s *movieNetSim) reset() {
+	s.mu.Lock()
+	s.sim, s.pending = nil, nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gothoom/internal/fakeserver"
+)
+
+func TestParseNetSim(t *testing.T) {
+	cfg, err := parseNetSim("latency=150ms, jitter=40ms,loss=5%,reorder=0.02,dup=1%,seed=7")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := netSimConfig{Latency: 150 * time.Millisecond, Jitter: 40 * time.Millisecond, Loss: 0.05, Reorder: 0.02, Duplicate: 0.01, Seed: 7}
+	if cfg != want {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+	if again, err := parseNetSim(cfg.String()); err != nil || again != cfg {
+		t.Errorf("round trip of %q: %+v, %v", cfg.String(), again, err)
+	}
+	if cfg, err := parseNetSim("off"); err != nil || cfg.enabled() {
+		t.Errorf("off: %+v, %v", cfg, err)
+	}
+	for _, bad := range []string{"latency", "loss=150%", "jitter=-1s", "speed=2"} {
+		if _, err := parseNetSim(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestNetSimDeterministic(t *testing.T) {
+	cfg := netSimConfig{Latency: 100 * time.Millisecond, Jitter: 50 * time.Millisecond, Loss: 0.2, Reorder: 0.1, Duplicate: 0.1, Seed: 42}
+	run := func() [][]time.Duration {
+		s := newNetSim(cfg)
+		var out [][]time.Duration
+		for i := 0; i < 200; i++ {
+			out = append(out, s.delays(movieFrameTime(i), true))
+		}
+		return out
+	}
+	a, b := run(), run()
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed gave different fates")
+	}
+	var lost, dup int
+	for _, d := range a {
+		switch len(d) {
+		case 0:
+			lost++
+		case 2:
+			dup++
+		}
+	}
+	if lost == 0 || dup == 0 {
+		t.Errorf("lost %d, duplicated %d of 200", lost, dup)
+	}
+
+	// Streams arrive once, in order.
+	s := newNetSim(cfg)
+	var last time.Duration
+	for i := 0; i < 200; i++ {
+		now := time.Duration(i) * time.Millisecond
+		d := s.delays(now, false)
+		if len(d) != 1 || now+d[0] < last {
+			t.Fatalf("stream packet %d: %v", i, d)
+		}
+		last = now + d[0]
+	}
+}
+
+func TestMovieNetSim(t *testing.T) {
+	orig := currentNetSim()
+	defer setNetSim(orig)
+	setNetSim(netSimConfig{Latency: 2 * framems * time.Millisecond, Reorder: 0.3, Seed: 3})
+
+	var s movieNetSim
+	var got []int32
+	for i := 0; i < 100; i++ {
+		for _, f := range s.send(i, movieFrame{index: int32(i), data: []byte{0, 2, 0}}) {
+			got = append(got, f.index)
+		}
+	}
+	if len(got) == 0 || got[0] != 0 {
+		t.Fatalf("arrivals %v", got)
+	}
+	reordered := false
+	for i := 1; i < len(got); i++ {
+		if got[i] < got[i-1] {
+			reordered = true
+		}
+	}
+	if !reordered {
+		t.Errorf("no frame arrived out of order: %v", got)
+	}
+	if len(got)+len(s.pending) != 100 {
+		t.Errorf("%d arrived, %d in flight, want 100 in all", len(got), len(s.pending))
+	}
+}
+
+// Test that movie frames carrying game state blocks or messages other than
+// draw states are never lost, while draw states are.
+func TestMovieNetSimReliableFrames(t *testing.T) {
+	orig := currentNetSim()
+	defer setNetSim(orig)
+	setNetSim(netSimConfig{Loss: 1, Seed: 3})
+
+	var s movieNetSim
+	frames := []movieFrame{
+		{index: 1, data: []byte{0, 2, 0}},
+		{index: 2, data: []byte{0, 2, 0}, flags: flagPictureTable},
+		{index: 3, data: []byte{0, 5, 0}},
+		{index: 4, flags: flagGameState},
+	}
+	var got []int32
+	for i, m := range frames {
+		for _, f := range s.send(i, m) {
+			got = append(got, f.index)
+		}
+	}
+	if !reflect.DeepEqual(got, []int32{2, 3, 4}) {
+		t.Fatalf("arrived %v, want [2 3 4]", got)
+	}
+}
+
+func TestNetSimConn(t *testing.T) {
+	cfg := netSimConfig{Latency: 50 * time.Millisecond, Seed: 1}
+	client, server := net.Pipe()
+	defer server.Close()
+	c := newNetSimConn(client, true, cfg)
+	defer c.Close()
+
+	go server.Write([]byte("hello"))
+	c.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := c.Read(make([]byte, 8)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("read before latency: %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 3)
+	n, err := c.Read(buf)
+	if err != nil || string(buf[:n]) != "hel" {
+		t.Fatalf("read %q, %v", buf[:n], err)
+	}
+	n, err = c.Read(buf)
+	if err != nil || string(buf[:n]) != "lo" {
+		t.Fatalf("rest %q, %v", buf[:n], err)
+	}
+
+	start := time.Now()
+	if _, err := c.Write([]byte("out")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	n, err = server.Read(buf)
+	if err != nil || string(buf[:n]) != "out" {
+		t.Fatalf("server read %q, %v", buf[:n], err)
+	}
+	if d := time.Since(start); d < cfg.Latency {
+		t.Errorf("write arrived after %v, want at least %v", d, cfg.Latency)
+	}
+}
+
+func TestIsGameDatagram(t *testing.T) {
+	if isGameDatagram([]byte{0xff, 0xff, 1, 2, 3, 4}) {
+		t.Error("handshake treated as a game message")
+	}
+	if !isGameDatagram([]byte{0, 2, 0, 2}) {
+		t.Error("game message not recognized")
+	}
+}
+
+// Log in to the stand-in server over a slow network that reorders and
+// duplicates datagrams; a queued command must still get through.
+func TestLoginWithNetSim(t *testing.T) {
+	srv, err := fakeserver.Listen(fakeserver.Config{Password: "secret", FrameInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go srv.Serve(ctx)
+
+	orig := currentNetSim()
+	origName, origPass, origHash := name, pass, passHash
+	defer func() {
+		setNetSim(orig)
+		name, pass, passHash = origName, origPass, origHash
+	}()
+	setNetSim(netSimConfig{Latency: 30 * time.Millisecond, Jitter: 20 * time.Millisecond, Reorder: 0.1, Duplicate: 0.1, Seed: 5})
+	name, pass, passHash = "Tester", "secret", ""
+
+	enqueueCommand("/who")
+	loginCtx, logout := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- runLoginAttempt(loginCtx, serverTarget{addr: srv.Addr(), display: srv.Addr()}, clVersion, 0, 0)
+	}()
+	for {
+		select {
+		case in := <-srv.Inputs():
+			if in.Command != "/who" {
+				continue
+			}
+			logout()
+			if err := <-done; err != nil {
+				t.Fatalf("runLoginAttempt: %v", err)
+			}
+			return
+		case err := <-done:
+			t.Fatalf("login ended early: %v", err)
+		case <-ctx.Done():
+			t.Fatalf("command never reached the server")
+		}
+	}
+}
@@ -306,6 +306,7 @@ func processServerMessage(msg []byte) {
 	if len(msg) < 2 {
 		return
 	}
+	inspectMessage(msg, "server")
 	tag := binary.BigEndian.Uint16(msg[:2])
 	if tag == 2 {
 		noteFrame()
@@ -2,10 +2,12 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/google/gopacket"
@@ -14,132 +16,228 @@ import (
 	"github.com/google/gopacket/tcpassembly"
 )
 
-func replayPCAP(ctx context.Context, path string) error {
-	// Ebiten must be running before ReadPixels is invoked, so wait for the game
-	// to start before opening the PCAP. Propagate context cancellation so that
-	// shutdown does not deadlock while waiting.
-	select {
-	case <-gameStarted:
-	case <-ctx.Done():
-		return ctx.Err()
+// pcapFilter selects the server side of a capture. Only packets sent from
+// a matching address are treated as server messages; a nil ip or zero port
+// matches any.
+type pcapFilter struct {
+	ip   net.IP
+	port uint16
+}
+
+// parsePCAPFilter parses "host", "host:port" or ":port". An empty string
+// matches the default server port.
+func parsePCAPFilter(s string) (pcapFilter, error) {
+	if s == "" {
+		s = ":5010"
+	}
+	host, port := s, ""
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, port = h, p
+	}
+	var f pcapFilter
+	if host != "" {
+		if f.ip = net.ParseIP(host); f.ip == nil {
+			addrs, err := net.LookupIP(host)
+			if err != nil || len(addrs) == 0 {
+				return f, fmt.Errorf("pcap server %q: unknown host", host)
+			}
+			f.ip = addrs[0]
+		}
+	}
+	if port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return f, fmt.Errorf("pcap server %q: bad port", s)
+		}
+		f.port = uint16(n)
 	}
+	return f, nil
+}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return err
+func (f pcapFilter) match(ip net.IP, port uint16) bool {
+	if f.ip != nil && !f.ip.Equal(ip) {
+		return false
 	}
-	defer f.Close()
+	return f.port == 0 || f.port == port
+}
+
+// pcapMessage is a server message extracted from a capture.
+type pcapMessage struct {
+	ts   time.Time
+	data []byte
+}
 
-	var (
-		source *gopacket.PacketSource
-	)
-	if ng, err := pcapgo.NewNgReader(f, pcapgo.NgReaderOptions{}); err == nil {
+// readPCAPMessages extracts the server messages sent from the address
+// selected by filter. TCP streams are reassembled; UDP datagrams are
+// buffered per flow and split the way readUDPMessage does live.
+func readPCAPMessages(r io.ReadSeeker, filter pcapFilter) ([]pcapMessage, error) {
+	var source *gopacket.PacketSource
+	if ng, err := pcapgo.NewNgReader(r, pcapgo.NgReaderOptions{}); err == nil {
 		source = gopacket.NewPacketSource(ng, ng.LinkType())
 	} else {
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return err
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
 		}
-		r, err := pcapgo.NewReader(f)
+		pr, err := pcapgo.NewReader(r)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		source = gopacket.NewPacketSource(r, r.LinkType())
+		source = gopacket.NewPacketSource(pr, pr.LinkType())
 	}
 
-	factory := &pcapStreamFactory{}
-	pool := tcpassembly.NewStreamPool(factory)
-	assembler := tcpassembly.NewAssembler(pool)
-
-	var prevTS time.Time
+	var msgs []pcapMessage
+	emit := func(ts time.Time, buf *bytes.Buffer) {
+		for {
+			b := buf.Bytes()
+			if len(b) < 2 {
+				return
+			}
+			l := int(binary.BigEndian.Uint16(b[:2]))
+			if len(b) < 2+l {
+				return
+			}
+			if l >= 2 {
+				msgs = append(msgs, pcapMessage{ts: ts, data: append([]byte(nil), b[2:2+l]...)})
+			}
+			buf.Next(2 + l)
+		}
+	}
+	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(&pcapStreamFactory{emit: emit}))
+	udpBufs := map[gopacket.Flow]*bytes.Buffer{}
 
 	for {
-		select {
-		case <-ctx.Done():
-			assembler.FlushAll()
-			return ctx.Err()
-		default:
-		}
 		pkt, err := source.NextPacket()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
-		}
-
-		ts := pkt.Metadata().CaptureInfo.Timestamp
-		if !prevTS.IsZero() {
-			if d := ts.Sub(prevTS); d > 0 {
-				time.Sleep(d)
-			}
-		}
-
-		net := pkt.NetworkLayer()
-		if net == nil {
-			continue
+			return nil, err
 		}
-		transport := pkt.TransportLayer()
-		if transport == nil {
+		nl := pkt.NetworkLayer()
+		if nl == nil {
 			continue
 		}
-		switch t := transport.(type) {
+		src := net.IP(nl.NetworkFlow().Src().Raw())
+		ts := pkt.Metadata().CaptureInfo.Timestamp
+		switch t := pkt.TransportLayer().(type) {
 		case *layers.UDP:
-			handlePayload(t.Payload)
+			if !filter.match(src, uint16(t.SrcPort)) || len(t.Payload) == 0 {
+				continue
+			}
+			buf := udpBufs[nl.NetworkFlow()]
+			if buf == nil {
+				buf = &bytes.Buffer{}
+				udpBufs[nl.NetworkFlow()] = buf
+			}
+			buf.Write(t.Payload)
+			emit(ts, buf)
 		case *layers.TCP:
-			assembler.AssembleWithTimestamp(net.NetworkFlow(), t, ts)
+			if !filter.match(src, uint16(t.SrcPort)) {
+				continue
+			}
+			assembler.AssembleWithTimestamp(nl.NetworkFlow(), t, ts)
 		}
-
-		prevTS = ts
 	}
 	assembler.FlushAll()
-	return nil
+	return msgs, nil
 }
 
-func handlePayload(p []byte) {
-	if len(p) < 2 {
-		return
-	}
-	sz := int(binary.BigEndian.Uint16(p[:2]))
-	if len(p) < 2+sz {
-		return
-	}
-	msg := p[2 : 2+sz]
-	dispatchMessage(msg)
+type pcapStreamFactory struct {
+	emit func(time.Time, *bytes.Buffer)
 }
 
-type pcapStreamFactory struct{}
-
 func (f *pcapStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
-	return &pcapStream{}
+	return &pcapStream{emit: f.emit}
 }
 
 type pcapStream struct {
-	buf bytes.Buffer
+	buf  bytes.Buffer
+	emit func(time.Time, *bytes.Buffer)
 }
 
 func (s *pcapStream) Reassembled(rs []tcpassembly.Reassembly) {
 	for _, r := range rs {
+		if r.Skip != 0 {
+			// Bytes were lost; framing can't be trusted past the gap.
+			s.buf.Reset()
+		}
 		if len(r.Bytes) > 0 {
 			s.buf.Write(r.Bytes)
+			s.emit(r.Seen, &s.buf)
 		}
 	}
-	for {
-		b := s.buf.Bytes()
-		if len(b) < 2 {
-			return
-		}
-		l := int(binary.BigEndian.Uint16(b[:2]))
-		if len(b) < 2+l {
-			return
-		}
-		msg := append([]byte(nil), b[2:2+l]...)
-		dispatchMessage(msg)
-		s.buf.Next(2 + l)
-	}
 }
 
 func (s *pcapStream) ReassemblyComplete() {}
 
-func dispatchMessage(msg []byte) {
-	processServerMessage(msg)
+// pcapToClmov writes the server messages of the capture at path as a
+// .clMov through movieRecorder, the way a live recording would be made:
+// login GameState, MobileData and PictureTable messages become blocks ahead
+// of the first draw state, and every later message is a frame. It returns
+// the number of frames written.
+func pcapToClmov(path, out string, filter pcapFilter) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	msgs, err := readPCAPMessages(f, filter)
+	if err != nil {
+		return 0, err
+	}
+	mr, err := newMovieRecorder(out, clVersion, int(movieRevision))
+	if err != nil {
+		return 0, err
+	}
+	n, err := writePCAPMovie(mr, msgs)
+	if cerr := mr.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n == 0 {
+		err = fmt.Errorf("no draw states from the server in %s", path)
+	}
+	if err != nil {
+		os.Remove(out)
+		return 0, err
+	}
+	return n, nil
+}
+
+func writePCAPMovie(mr *movieRecorder, msgs []pcapMessage) (int, error) {
+	var gameState, mobileData, pictureTable []byte
+	started := false
+	n := 0
+	for _, msg := range msgs {
+		m := msg.data
+		flags := frameFlags(m)
+		if !started {
+			if binary.BigEndian.Uint16(m[:2]) != 2 {
+				switch {
+				case flags&flagGameState != 0:
+					gameState = m[2:]
+				case flags&flagMobileData != 0:
+					mobileData = m[2:]
+				case flags&flagPictureTable != 0:
+					pictureTable = m[2:]
+				}
+				continue
+			}
+			if l := len(gameState); l > 0 {
+				mr.AddBlock(gameStateBlock(0, 0, 0, l, l, l, gameState), flagGameState)
+			}
+			if err := mr.WriteBlock(mobileData, flagMobileData); err != nil {
+				return n, err
+			}
+			if err := mr.WriteBlock(pictureTable, flagPictureTable); err != nil {
+				return n, err
+			}
+			mr.head.StartTime = uint32(msg.ts.Unix() + macEpochDelta)
+			started = true
+		}
+		if err := mr.WriteFrame(m, flags); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
 }
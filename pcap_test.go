@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Test that server messages are pulled from both TCP streams and UDP
+// datagrams, client packets are filtered out, and the capture converts to
+// a movie.
+func TestPCAPToClmov(t *testing.T) {
+	server := net.IPv4(10, 0, 0, 1).To4()
+	client := net.IPv4(10, 0, 0, 2).To4()
+	var capture bytes.Buffer
+	w := pcapgo.NewWriter(&capture)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	ts := time.Unix(1700000000, 0)
+	write := func(src, dst net.IP, sport, dport uint16, tcp *layers.TCP, payload []byte) {
+		t.Helper()
+		eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+		ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: src, DstIP: dst}
+		var tl gopacket.SerializableLayer
+		if tcp != nil {
+			ip.Protocol = layers.IPProtocolTCP
+			tcp.SrcPort, tcp.DstPort = layers.TCPPort(sport), layers.TCPPort(dport)
+			tcp.Window = 65535
+			tcp.SetNetworkLayerForChecksum(ip)
+			tl = tcp
+		} else {
+			ip.Protocol = layers.IPProtocolUDP
+			udp := &layers.UDP{SrcPort: layers.UDPPort(sport), DstPort: layers.UDPPort(dport)}
+			udp.SetNetworkLayerForChecksum(ip)
+			tl = udp
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, eth, ip, tl, gopacket.Payload(payload)); err != nil {
+			t.Fatalf("serialize: %v", err)
+		}
+		b := buf.Bytes()
+		ts = ts.Add(200 * time.Millisecond)
+		if err := w.WritePacket(gopacket.CaptureInfo{Timestamp: ts, CaptureLength: len(b), Length: len(b)}, b); err != nil {
+			t.Fatalf("write packet: %v", err)
+		}
+	}
+	framed := func(msgs ...[]byte) []byte {
+		var out []byte
+		for _, m := range msgs {
+			out = binary.BigEndian.AppendUint16(out, uint16(len(m)))
+			out = append(out, m...)
+		}
+		return out
+	}
+
+	mob := frameMobile{Index: 1, H: 3, V: 4}
+	ds1 := testDrawStatePacket(1, 30, 0, nil, mob)
+	ds2 := testDrawStatePacket(2, 31, 0, nil, mob)
+	ds3 := testDrawStatePacket(3, 32, 0, nil, mob)
+	info := append([]byte{0, 5}, "hello\x00"...)
+
+	// TCP: handshake, then the info message split across two segments.
+	write(server, client, 5010, 40000, &layers.TCP{SYN: true, ACK: true, Seq: 100}, nil)
+	tcpData := framed(info)
+	write(server, client, 5010, 40000, &layers.TCP{ACK: true, PSH: true, Seq: 101}, tcpData[:4])
+	// UDP: the first draw state, then a datagram holding two.
+	write(server, client, 5010, 40001, nil, framed(ds1))
+	write(server, client, 5010, 40000, &layers.TCP{ACK: true, PSH: true, Seq: 105}, tcpData[4:])
+	// Client input must be ignored.
+	write(client, server, 40001, 5010, nil, framed([]byte{0, 3, 1, 2, 3, 4}))
+	write(server, client, 5010, 40001, nil, framed(ds2, ds3))
+
+	filter, err := parsePCAPFilter("10.0.0.1:5010")
+	if err != nil {
+		t.Fatalf("parsePCAPFilter: %v", err)
+	}
+	msgs, err := readPCAPMessages(bytes.NewReader(capture.Bytes()), filter)
+	if err != nil {
+		t.Fatalf("readPCAPMessages: %v", err)
+	}
+	want := [][]byte{ds1, info, ds2, ds3}
+	if len(msgs) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(msgs), len(want))
+	}
+	for i, m := range msgs {
+		if !bytes.Equal(m.data, want[i]) {
+			t.Fatalf("message %d = %x, want %x", i, m.data, want[i])
+		}
+	}
+	if other, _ := parsePCAPFilter("10.0.0.9"); len(mustPCAPMessages(t, capture.Bytes(), other)) != 0 {
+		t.Fatalf("filter on another host matched")
+	}
+
+	out := filepath.Join(t.TempDir(), "capture.clMov")
+	mr, err := newMovieRecorder(out, clVersion, 0)
+	if err != nil {
+		t.Fatalf("newMovieRecorder: %v", err)
+	}
+	n, err := writePCAPMovie(mr, msgs)
+	if err != nil {
+		t.Fatalf("writePCAPMovie: %v", err)
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("wrote %d frames, want 4", n)
+	}
+	frames, err := parseMovie(out, clVersion)
+	if err != nil {
+		t.Fatalf("parseMovie: %v", err)
+	}
+	if len(frames) != 4 || !bytes.Equal(frames[0].data, ds1) || !bytes.Equal(frames[3].data, ds3) {
+		t.Fatalf("frames %+v", frames)
+	}
+}
+
+func mustPCAPMessages(t *testing.T, capture []byte, f pcapFilter) []pcapMessage {
+	t.Helper()
+	msgs, err := readPCAPMessages(bytes.NewReader(capture), f)
+	if err != nil {
+		t.Fatalf("readPCAPMessages: %v", err)
+	}
+	return msgs
+}
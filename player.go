@@ -152,7 +152,8 @@ func notifyPlayerHandlers(p Player) {
 	}
 	for _, h := range plug {
 		scriptLogEvent(h.owner, "PlayerHandler", p.Name)
-		go h.fn(p)
+		fn := h.fn
+		scriptGo(h.owner, "PlayerHandler", func() { fn(p) })
 	}
 }
 
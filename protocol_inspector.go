@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInspectedMessages caps the protocol inspector's history.
+const maxInspectedMessages = 2000
+
+// inspectedMessage is one server message seen by the protocol inspector.
+type inspectedMessage struct {
+	seq    int
+	at     time.Time
+	source string // "server", "movie" or "pcap"
+	tag    uint16
+	data   []byte // including the tag
+}
+
+// inspectNode is a line of a message's decoded tree.
+type inspectNode struct {
+	label    string
+	children []inspectNode
+}
+
+// inspector collects messages while the inspector window is open.
+var inspector struct {
+	mu      sync.Mutex
+	enabled bool
+	seq     int
+	msgs    []inspectedMessage
+	dirty   bool
+}
+
+func setInspectorEnabled(on bool) {
+	inspector.mu.Lock()
+	inspector.enabled = on
+	inspector.mu.Unlock()
+}
+
+// inspectMessage records msg for the protocol inspector. It is called for
+// live messages from processServerMessage and for frames from the movie
+// player, which also plays pcaps after converting them to a .clMov.
+func inspectMessage(msg []byte, source string) {
+	if len(msg) < 2 {
+		return
+	}
+	inspector.mu.Lock()
+	defer inspector.mu.Unlock()
+	if !inspector.enabled {
+		return
+	}
+	inspector.seq++
+	inspector.msgs = append(inspector.msgs, inspectedMessage{
+		seq:    inspector.seq,
+		at:     time.Now(),
+		source: source,
+		tag:    binary.BigEndian.Uint16(msg[:2]),
+		data:   append([]byte(nil), msg...),
+	})
+	if n := len(inspector.msgs); n > maxInspectedMessages+maxInspectedMessages/8 {
+		inspector.msgs = append([]inspectedMessage(nil), inspector.msgs[n-maxInspectedMessages:]...)
+	}
+	inspector.dirty = true
+}
+
+func clearInspectedMessages() {
+	inspector.mu.Lock()
+	inspector.msgs = nil
+	inspector.dirty = true
+	inspector.mu.Unlock()
+}
+
+// inspectorFilter selects messages by tag and by the BEPP prefixes of
+// their text. Empty lists match everything.
+type inspectorFilter struct {
+	tags map[uint16]bool
+	bepp []string
+}
+
+// parseInspectorFilter parses comma separated tag numbers and BEPP prefixes.
+func parseInspectorFilter(tags, bepp string) (inspectorFilter, error) {
+	var f inspectorFilter
+	for _, t := range parseTags(tags) {
+		n, err := strconv.ParseUint(t, 10, 16)
+		if err != nil {
+			return f, fmt.Errorf("bad tag %q", t)
+		}
+		if f.tags == nil {
+			f.tags = map[uint16]bool{}
+		}
+		f.tags[uint16(n)] = true
+	}
+	for _, p := range parseTags(bepp) {
+		f.bepp = append(f.bepp, strings.ToLower(p))
+	}
+	return f, nil
+}
+
+func (f inspectorFilter) match(m inspectedMessage) bool {
+	if f.tags != nil && !f.tags[m.tag] {
+		return false
+	}
+	if len(f.bepp) == 0 {
+		return true
+	}
+	for _, line := range bytes.Split(messageInfoText(m.data), []byte{'\r'}) {
+		if len(line) < 3 || line[0] != 0xC2 {
+			continue
+		}
+		prefix := strings.ToLower(string(line[1:3]))
+		for _, p := range f.bepp {
+			if prefix == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filteredInspectedMessages returns the recorded messages matching f.
+func filteredInspectedMessages(f inspectorFilter) []inspectedMessage {
+	inspector.mu.Lock()
+	defer inspector.mu.Unlock()
+	var out []inspectedMessage
+	for _, m := range inspector.msgs {
+		if f.match(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// decryptedDrawState returns the draw state body of a tag 2 message,
+// decrypted into a copy when drawStateEncrypted is set.
+func decryptedDrawState(msg []byte) []byte {
+	data := msg[2:]
+	if drawStateEncrypted {
+		data = append([]byte(nil), data...)
+		simpleEncrypt(data)
+	}
+	return data
+}
+
+// messageInfoText returns the info text of a message: the info string of a
+// draw state, or the text after the 16 byte header of other messages,
+// decrypted the way decodeMessage does when it isn't readable as is.
+func messageInfoText(msg []byte) []byte {
+	if binary.BigEndian.Uint16(msg[:2]) == 2 {
+		info, _ := drawStateText(decryptedDrawState(msg), map[uint8]string{})
+		return info
+	}
+	text, _ := messageText(msg)
+	return text
+}
+
+func messageText(msg []byte) (text []byte, decrypted bool) {
+	if len(msg) <= 16 {
+		return nil, false
+	}
+	readable := func(d []byte) bool {
+		if len(d) > 0 && d[0] == 0xC2 {
+			return true
+		}
+		if _, s, _, _, _, _, _ := decodeBubble(d); s != "" {
+			return true
+		}
+		if i := bytes.IndexByte(d, 0); i >= 0 {
+			d = d[:i]
+		}
+		return len([]rune(strings.TrimSpace(decodeMacRoman(d)))) >= 4
+	}
+	data := msg[16:]
+	if readable(data) {
+		return data, false
+	}
+	data = append([]byte(nil), data...)
+	simpleEncrypt(data)
+	if readable(data) {
+		return data, true
+	}
+	return nil, false
+}
+
+// inspectMessageTree decodes msg into a tree for display: draw states are
+// split into their sections, and info text into BEPP lines and bubbles.
+func inspectMessageTree(msg []byte) inspectNode {
+	tag := binary.BigEndian.Uint16(msg[:2])
+	root := inspectNode{label: fmt.Sprintf("tag %d, %d bytes", tag, len(msg))}
+	if tag == 2 {
+		root.label = fmt.Sprintf("draw state, %d bytes", len(msg))
+		root.children = drawStateTree(decryptedDrawState(msg))
+		return root
+	}
+	if len(msg) >= 16 {
+		root.children = append(root.children, inspectNode{label: "header " + hex.EncodeToString(msg[2:16])})
+	}
+	text, decrypted := messageText(msg)
+	if text != nil {
+		label := "text"
+		if decrypted {
+			label = "text (decrypted)"
+		}
+		root.children = append(root.children, inspectNode{label: label, children: infoTextTree(text)})
+	}
+	return root
+}
+
+// drawStateTree shows a draw state body section by section, as decoded
+// by decodeDrawState. A truncated or malformed message ends with an error
+// node after the sections decoded before it.
+func drawStateTree(data []byte) []inspectNode {
+	var msg drawStateMsg
+	err := decodeDrawState(&msg, data)
+	var out []inspectNode
+	done := func() []inspectNode {
+		if err != nil {
+			out = append(out, inspectNode{label: "error: truncated at " + strings.TrimPrefix(err.Error(), "truncated ")})
+		}
+		return out
+	}
+	if msg.sections < drawSectionHeader {
+		return done()
+	}
+	out = append(out, inspectNode{label: fmt.Sprintf("header: ack cmd %d, ack frame %d, resend frame %d", msg.ackCmd, msg.ack, msg.resend)})
+
+	names := map[uint8]string{}
+	descs := inspectNode{label: fmt.Sprintf("descriptors (%d)", msg.descCount)}
+	for _, d := range msg.descs {
+		names[d.Index] = d.Name
+		descs.children = append(descs.children, inspectNode{label: fmt.Sprintf("#%d %q type %d pict %d, %d colors", d.Index, d.Name, d.Type, d.PictID, len(d.Colors))})
+	}
+	out = append(out, descs)
+	if msg.sections < drawSectionDescriptors {
+		return done()
+	}
+
+	out = append(out, inspectNode{label: fmt.Sprintf("stats: hp %d/%d, sp %d/%d, balance %d/%d, lighting 0x%02x",
+		msg.hp, msg.hpMax, msg.sp, msg.spMax, msg.bal, msg.balMax, msg.lighting)})
+	if msg.sections < drawSectionStats {
+		return done()
+	}
+
+	pics := inspectNode{label: fmt.Sprintf("pictures (%d new, %d repeated)", msg.pictCount, msg.pictAgain)}
+	for _, pic := range msg.pics {
+		pics.children = append(pics.children, inspectNode{label: fmt.Sprintf("pict %d at %d,%d", pic.PictID, pic.H, pic.V)})
+	}
+	out = append(out, pics)
+	if msg.sections < drawSectionPictures {
+		return done()
+	}
+
+	mobs := inspectNode{label: fmt.Sprintf("mobiles (%d)", msg.mobileCount)}
+	for _, m := range msg.mobiles {
+		label := fmt.Sprintf("#%d at %d,%d state %d colors %d", m.Index, m.H, m.V, m.State, m.Colors)
+		if n := names[m.Index]; n != "" {
+			label += fmt.Sprintf(" %q", n)
+		}
+		mobs.children = append(mobs.children, inspectNode{label: label})
+	}
+	out = append(out, mobs)
+	if msg.sections < drawSectionStateData {
+		return done()
+	}
+
+	out = append(out, inspectNode{label: fmt.Sprintf("state data: %d bytes", len(msg.stateData))})
+	infos, bubbles, _, _ := splitDrawStateText(msg.stateData)
+	if info := bytes.Join(infos, []byte{'\r'}); len(info) > 0 {
+		out = append(out, inspectNode{label: "info", children: infoTextTree(info)})
+	}
+	if len(bubbles) > 0 {
+		bn := inspectNode{label: fmt.Sprintf("bubbles (%d)", len(bubbles))}
+		for _, b := range bubbles {
+			bn.children = append(bn.children, bubbleNode(b.data, names[b.index]))
+		}
+		out = append(out, bn)
+	}
+	return out
+}
+
+// infoTextTree splits info text into lines, naming BEPP prefixes and
+// decoding bubbles.
+func infoTextTree(text []byte) []inspectNode {
+	var out []inspectNode
+	for _, line := range bytes.Split(text, []byte{'\r'}) {
+		if len(line) == 0 || line[0] == 0 {
+			continue
+		}
+		if line[0] == 0xC2 && len(line) >= 3 {
+			prefix, raw, txt := splitBEPP(line)
+			out = append(out, inspectNode{label: fmt.Sprintf("BEPP %s: %q", prefix, txt), children: []inspectNode{{label: fmt.Sprintf("raw %q", decodeMacRoman(raw))}}})
+			continue
+		}
+		if _, txt, _, _, _, _, _ := decodeBubble(line); txt != "" {
+			out = append(out, bubbleNode(line, ""))
+			continue
+		}
+		if i := bytes.IndexByte(line, 0); i >= 0 {
+			line = line[:i]
+		}
+		out = append(out, inspectNode{label: fmt.Sprintf("%q", decodeMacRoman(line))})
+	}
+	return out
+}
+
+func bubbleNode(b []byte, speaker string) inspectNode {
+	verb, txt, name, lang, code, typ, _ := decodeBubble(b)
+	if name == "" {
+		name = speaker
+	}
+	label := fmt.Sprintf("bubble #%d type %d: %s %s %q", b[0], typ, name, verb, txt)
+	if lang != "" {
+		label += " in " + lang
+	}
+	if code != kBubbleCodeKnown {
+		label += fmt.Sprintf(" (code %d)", code)
+	}
+	return inspectNode{label: label}
+}
+
+// inspectNodeLines flattens a tree into indented lines.
+func inspectNodeLines(n inspectNode, depth int, out []string) []string {
+	out = append(out, strings.Repeat("  ", depth)+n.label)
+	for _, c := range n.children {
+		out = inspectNodeLines(c, depth+1, out)
+	}
+	return out
+}
+
+// inspectedSummary is the one line list entry for m.
+func inspectedSummary(m inspectedMessage) string {
+	s := fmt.Sprintf("#%d %s %s tag %d, %d bytes", m.seq, m.at.Format("15:04:05.000"), m.source, m.tag, len(m.data))
+	for _, line := range bytes.Split(messageInfoText(m.data), []byte{'\r'}) {
+		if kind, txt := movieInfoText(line); txt != "" {
+			if len(txt) > 60 {
+				txt = txt[:60] + "..."
+			}
+			return s + fmt.Sprintf(" [%s] %s", kind, txt)
+		}
+	}
+	return s
+}
+
+// writeInspectedMessages writes msgs as text for offline study: a summary
+// line, the decoded tree and a hex dump of each.
+func writeInspectedMessages(w io.Writer, msgs []inspectedMessage) error {
+	for _, m := range msgs {
+		lines := inspectNodeLines(inspectMessageTree(m.data), 1, []string{inspectedSummary(m)})
+		if _, err := fmt.Fprintf(w, "%s\n%s\n", strings.Join(lines, "\n"), hex.Dump(m.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
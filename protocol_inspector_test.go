@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test that the inspector decodes draw state sections, BEPP lines and
+// bubbles, and filters by tag and BEPP prefix.
+func TestInspectMessageTree(t *testing.T) {
+	origEncrypted := drawStateEncrypted
+	drawStateEncrypted = false
+	defer func() { drawStateEncrypted = origEncrypted }()
+
+	ds := []byte{0, 2, 0, 0, 0, 0, 7, 0, 0, 0, 0} // tag, ack and resend
+	ds = append(ds, 1, 5, 0, 0, 1)                // one descriptor, index 5
+	ds = append(ds, "Bob\x00"...)
+	ds = append(ds, 0)                       // colors
+	ds = append(ds, 30, 100, 4, 10, 0, 0, 0) // stats
+	ds = append(ds, 0)                       // pictures
+	ds = append(ds, 1, 5, 2, 0, 10, 0xff, 0xfd, 0)
+	st := []byte("\xC2hfBob has fallen\x00")
+	st = append(st, 1, 5, kBubbleNormal)
+	st = append(st, "heal me\x00"...)
+	ds = append(ds, byte(len(st)>>8), byte(len(st)))
+	ds = append(ds, st...)
+
+	lines := strings.Join(inspectNodeLines(inspectMessageTree(ds), 0, nil), "\n")
+	for _, want := range []string{
+		"header: ack cmd 0, ack frame 7, resend frame 0",
+		`#5 "Bob" type 0 pict 1, 0 colors`,
+		"stats: hp 30/100, sp 4/10",
+		"pictures (0 new, 0 repeated)",
+		`#5 at 10,-3 state 2 colors 0 "Bob"`,
+		`BEPP hf: "Bob has fallen"`,
+		`Bob says "heal me"`,
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("missing %q in\n%s", want, lines)
+		}
+	}
+
+	// A truncated draw state still shows what was decoded.
+	lines = strings.Join(inspectNodeLines(inspectMessageTree(ds[:20]), 0, nil), "\n")
+	if !strings.Contains(lines, "descriptors (1)") || !strings.Contains(lines, "error: truncated at") {
+		t.Errorf("truncated tree:\n%s", lines)
+	}
+
+	info := append(make([]byte, 16), "\xC2thhmm\x00"...)
+	info[1] = 5
+	msgs := []inspectedMessage{{seq: 1, tag: 2, data: ds}, {seq: 2, tag: 5, data: info}}
+	for _, tc := range []struct {
+		tags, bepp string
+		want       []int
+	}{
+		{"", "", []int{1, 2}},
+		{"5", "", []int{2}},
+		{"", "HF", []int{1}},
+		{"", "th, yk", []int{2}},
+		{"5", "hf", nil},
+	} {
+		f, err := parseInspectorFilter(tc.tags, tc.bepp)
+		if err != nil {
+			t.Fatalf("parseInspectorFilter(%q, %q): %v", tc.tags, tc.bepp, err)
+		}
+		var got []int
+		for _, m := range msgs {
+			if f.match(m) {
+				got = append(got, m.seq)
+			}
+		}
+		if len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("filter %q %q matched %v, want %v", tc.tags, tc.bepp, got, tc.want)
+		}
+	}
+	if _, err := parseInspectorFilter("x", ""); err == nil {
+		t.Errorf("bad tag accepted")
+	}
+
+	var out bytes.Buffer
+	if err := writeInspectedMessages(&out, msgs[1:]); err != nil {
+		t.Fatalf("writeInspectedMessages: %v", err)
+	}
+	if !strings.Contains(out.String(), `BEPP th: "hmm"`) || !strings.Contains(out.String(), "00000000  00 05") {
+		t.Errorf("export:\n%s", out.String())
+	}
+}
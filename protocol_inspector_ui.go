@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gothoom/eui"
+)
+
+// maxInspectorRows caps the rows listed at once; the filters narrow them.
+const maxInspectorRows = 300
+
+var (
+	inspectorWin        *eui.WindowData
+	inspectorList       *eui.ItemData
+	inspectorDetail     *eui.ItemData
+	inspectorTags       string
+	inspectorBEPP       string
+	inspectorPaused     bool
+	inspectorSelected   = map[int]bool{}
+	inspectorShown      inspectedMessage
+	lastInspectorUpdate time.Time
+)
+
+// makeInspectorWindow opens the protocol inspector: a list of the server
+// messages handled while it is open, with the decoded tree and hex dump of
+// the clicked one. Checked rows are exported.
+func makeInspectorWindow() {
+	if inspectorWin != nil {
+		setInspectorEnabled(true)
+		inspectorWin.MarkOpen()
+		return
+	}
+	win := eui.NewWindow()
+	win.Title = "Protocol Inspector"
+	win.Size = eui.Point{X: 760, Y: 560}
+	win.Closable = true
+	win.Movable = true
+	win.Resizable = true
+	win.NoScroll = true
+	win.SetZone(eui.HZoneCenter, eui.VZoneMiddleTop)
+
+	flow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Fixed: true}
+	win.AddItem(flow)
+
+	controls := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL, Fixed: true, Size: eui.Point{X: 740, Y: 28}}
+	flow.AddItem(controls)
+
+	tagsInput, tagsEvents := eui.NewInput()
+	tagsInput.Label = "Tags"
+	tagsInput.Text = inspectorTags
+	tagsInput.Size = eui.Point{X: 150, Y: 24}
+	tagsInput.SetTooltip("Comma separated message tags to show, e.g. 2,5")
+	tagsEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventInputChanged {
+			inspectorTags = ev.Text
+			refreshInspectorWindow()
+		}
+	}
+	controls.AddItem(tagsInput)
+
+	beppInput, beppEvents := eui.NewInput()
+	beppInput.Label = "BEPP"
+	beppInput.Text = inspectorBEPP
+	beppInput.Size = eui.Point{X: 150, Y: 24}
+	beppInput.SetTooltip("Comma separated BEPP prefixes; show only messages with such a line, e.g. hf,be")
+	beppEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventInputChanged {
+			inspectorBEPP = ev.Text
+			refreshInspectorWindow()
+		}
+	}
+	controls.AddItem(beppInput)
+
+	pauseCB, pauseEvents := eui.NewCheckbox()
+	pauseCB.Text = "Pause"
+	pauseCB.Size = eui.Point{X: 90, Y: 24}
+	pauseCB.SetTooltip("Stop collecting messages")
+	pauseEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventCheckboxChanged {
+			inspectorPaused = ev.Checked
+			setInspectorEnabled(!ev.Checked)
+		}
+	}
+	controls.AddItem(pauseCB)
+
+	clearBtn, clearEvents := eui.NewButton()
+	clearBtn.Text = "Clear"
+	clearBtn.Size = eui.Point{X: 80, Y: 24}
+	clearEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			clearInspectedMessages()
+			inspectorSelected = map[int]bool{}
+			inspectorShown = inspectedMessage{}
+			refreshInspectorWindow()
+		}
+	}
+	controls.AddItem(clearBtn)
+
+	exportBtn, exportEvents := eui.NewButton()
+	exportBtn.Text = "Export"
+	exportBtn.Size = eui.Point{X: 80, Y: 24}
+	exportBtn.SetTooltip("Write the checked messages, or all listed ones, to a text file")
+	exportEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			exportInspectedMessages()
+		}
+	}
+	controls.AddItem(exportBtn)
+
+	inspectorList = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true, Fixed: true}
+	flow.AddItem(inspectorList)
+	inspectorDetail = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true, Fixed: true}
+	flow.AddItem(inspectorDetail)
+
+	win.OnResize = func() { refreshInspectorWindow() }
+	win.OnClose = func() { setInspectorEnabled(false) }
+	inspectorWin = win
+	win.AddWindow(false)
+	setInspectorEnabled(!inspectorPaused)
+	refreshInspectorWindow()
+	win.MarkOpen()
+}
+
+// updateInspectorWindow refreshes the list when new messages arrived,
+// at most a few times a second.
+func updateInspectorWindow(now time.Time) {
+	if now.Sub(lastInspectorUpdate) < 250*time.Millisecond {
+		return
+	}
+	inspector.mu.Lock()
+	dirty := inspector.dirty
+	inspector.dirty = false
+	inspector.mu.Unlock()
+	if dirty {
+		lastInspectorUpdate = now
+		refreshInspectorWindow()
+	}
+}
+
+func inspectorRows() ([]inspectedMessage, error) {
+	f, err := parseInspectorFilter(inspectorTags, inspectorBEPP)
+	if err != nil {
+		return nil, err
+	}
+	return filteredInspectedMessages(f), nil
+}
+
+func refreshInspectorWindow() {
+	if inspectorWin == nil || inspectorList == nil {
+		return
+	}
+	win := inspectorWin
+	pad := (win.Padding + win.BorderPad) * eui.UIScale()
+	width := win.GetSize().X - 2*pad
+	avail := win.GetSize().Y - win.GetTitleSize() - 2*pad - 28*eui.UIScale()
+	inspectorList.Size = eui.Point{X: width, Y: avail * 0.45}
+	inspectorDetail.Size = eui.Point{X: width, Y: avail * 0.55}
+	rowWidth := width - eui.ScrollbarWidth()
+
+	scrollit := inspectorList.ScrollAtBottom()
+	inspectorList.Contents = inspectorList.Contents[:0]
+	msgs, err := inspectorRows()
+	if err != nil {
+		t, _ := eui.NewText()
+		t.Text = err.Error()
+		t.Size = eui.Point{X: rowWidth, Y: 20}
+		t.FontSize = 10
+		inspectorList.AddItem(t)
+	}
+	if len(msgs) > maxInspectorRows {
+		msgs = msgs[len(msgs)-maxInspectorRows:]
+	}
+	for _, m := range msgs {
+		m := m
+		row := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL, Size: eui.Point{X: rowWidth, Y: 20}}
+		cb, cbEvents := eui.NewCheckbox()
+		cb.Size = eui.Point{X: 20, Y: 20}
+		cb.Checked = inspectorSelected[m.seq]
+		cbEvents.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventCheckboxChanged {
+				if ev.Checked {
+					inspectorSelected[m.seq] = true
+				} else {
+					delete(inspectorSelected, m.seq)
+				}
+			}
+		}
+		row.AddItem(cb)
+		btn, events := eui.NewButton()
+		btn.Text = inspectedSummary(m)
+		if m.seq == inspectorShown.seq {
+			btn.Text = "> " + btn.Text
+		}
+		btn.Size = eui.Point{X: rowWidth - 24, Y: 20}
+		btn.FontSize = 10
+		events.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventClick {
+				inspectorShown = m
+				refreshInspectorWindow()
+			}
+		}
+		row.AddItem(btn)
+		inspectorList.AddItem(row)
+	}
+	if scrollit {
+		inspectorList.Scroll.Y = 1e9
+	}
+
+	inspectorDetail.Contents = inspectorDetail.Contents[:0]
+	if inspectorShown.data != nil {
+		var sb strings.Builder
+		if err := writeInspectedMessages(&sb, []inspectedMessage{inspectorShown}); err == nil {
+			for _, line := range strings.Split(strings.TrimRight(sb.String(), "\n"), "\n") {
+				t, _ := eui.NewText()
+				t.Text = line
+				t.Size = eui.Point{X: rowWidth, Y: 14}
+				t.FontSize = 9
+				inspectorDetail.AddItem(t)
+			}
+		}
+	}
+	win.Refresh()
+}
+
+// exportInspectedMessages writes the checked messages, or every listed one
+// when none are checked, to the data directory.
+func exportInspectedMessages() {
+	msgs, err := inspectorRows()
+	if err != nil {
+		consoleMessage("inspector export: " + err.Error())
+		return
+	}
+	if len(inspectorSelected) > 0 {
+		var sel []inspectedMessage
+		for _, m := range msgs {
+			if inspectorSelected[m.seq] {
+				sel = append(sel, m)
+			}
+		}
+		msgs = sel
+	}
+	if len(msgs) == 0 {
+		consoleMessage("inspector export: no messages")
+		return
+	}
+	path := filepath.Join(dataDirPath, fmt.Sprintf("messages__%s.txt", time.Now().Format("2006-01-02-15-04-05")))
+	f, err := os.Create(path)
+	if err != nil {
+		logError("inspector export: %v", err)
+		return
+	}
+	err = writeInspectedMessages(f, msgs)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logError("inspector export: %v", err)
+		return
+	}
+	consoleMessage(fmt.Sprintf("exported %d messages to %s", len(msgs), path))
+}
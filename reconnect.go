@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Automatic reconnect: when gs.AutoReconnect is set and the connection
+// drops, login is retried with exponential backoff instead of returning to
+// the login window. Console and chat history, open windows and a running
+// recording carry over; the recording gets a bookmark at the gap.
+
+const (
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = time.Minute
+)
+
+var (
+	reconnectMu     sync.Mutex
+	reconnectCancel context.CancelFunc // set while reconnecting
+	reconnectLostAt time.Time
+	reconnectMovie  string // recording running when the connection dropped
+	reconnectFrame  int32  // its next frame at that point
+)
+
+// connectionLost is called by the network loops when a read fails. ctx is
+// the loop's session; failures after it ended are ignored.
+func connectionLost(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	if !canAutoReconnect() {
+		handleDisconnect()
+		return
+	}
+	loginMu.Lock()
+	cancel := loginCancel
+	loginCancel = nil
+	loginMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	resetFrameStats()
+
+	rctx, stop := context.WithCancel(gameCtx)
+	reconnectMu.Lock()
+	reconnectCancel = stop
+	reconnectLostAt = time.Now()
+	reconnectMovie = ""
+	if recorder != nil {
+		reconnectMovie = recordPath
+		reconnectFrame = recorder.head.Frames
+		// Store the new session's login blocks ahead of its first frame.
+		wroteLoginBlocks = false
+	}
+	reconnectMu.Unlock()
+
+	consoleMessage("Connection lost; reconnecting...")
+	go reconnectLoop(rctx, stop)
+}
+
+// canAutoReconnect reports whether a dropped connection should be retried:
+// only live sessions whose password is still known.
+func canAutoReconnect() bool {
+	return gs.AutoReconnect && (pass != "" || passHash != "") &&
+		!fake && clmov == "" && pcapPath == "" && !playingMovie
+}
+
+// reconnectLoop retries login until it succeeds, the attempts run out or
+// ctx is canceled by handleDisconnect. After a successful login it runs
+// the session like startLogin does.
+func reconnectLoop(ctx context.Context, stop context.CancelFunc) {
+	defer stop()
+	for attempt := 0; ; attempt++ {
+		if limit := gs.ReconnectAttempts; limit > 0 && attempt >= limit {
+			consoleMessage(fmt.Sprintf("Giving up after %d reconnect attempts.", attempt))
+			closeConnectDialog()
+			handleDisconnect()
+			return
+		}
+		delay := reconnectDelay(attempt)
+		showConnectDialog(fmt.Sprintf("Connection lost; reconnecting in %v (attempt %d)...",
+			delay.Round(time.Second), attempt+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		sessCtx, cancel := context.WithCancel(gameCtx)
+		loginMu.Lock()
+		loginCancel = cancel
+		loginMu.Unlock()
+		err := login(sessCtx, clVersion)
+		if err == nil {
+			// The session ran and ended; whoever ended it takes over.
+			return
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		loginMu.Lock()
+		loginCancel = nil
+		loginMu.Unlock()
+		logWarn("reconnect: %v", err)
+		if !retryableLoginError(err) {
+			consoleMessage("Reconnect failed: " + err.Error())
+			closeConnectDialog()
+			handleDisconnect()
+			makeErrorWindow("Error: Login: " + err.Error())
+			return
+		}
+	}
+}
+
+// reconnectDelay returns the wait before the given attempt, starting at
+// reconnectBaseDelay and doubling up to reconnectMaxDelay, plus up to a
+// quarter of random jitter so clients dropped together don't retry in
+// step.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectBaseDelay
+	for i := 0; i < attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	if d > reconnectMaxDelay {
+		d = reconnectMaxDelay
+	}
+	return d + time.Duration(rand.Int63n(int64(d/4)+1))
+}
+
+// retryableLoginError reports whether reconnecting should continue after
+// err. Refusals that waiting won't fix, like a wrong password, end it;
+// others, such as the character still being online, are retried.
+func retryableLoginError(err error) bool {
+	var le loginError
+	if !errors.As(err, &le) {
+		return true
+	}
+	switch le {
+	case -30999, -30998, -30996, -30988, -30987, -30984:
+		return false
+	}
+	return true
+}
+
+// stopReconnect abandons a pending reconnect and reports whether one was
+// running.
+func stopReconnect() bool {
+	reconnectMu.Lock()
+	defer reconnectMu.Unlock()
+	if reconnectCancel == nil {
+		return false
+	}
+	reconnectCancel()
+	reconnectCancel = nil
+	return true
+}
+
+// reconnectPending reports whether a reconnect is waiting or logging in.
+func reconnectPending() bool {
+	reconnectMu.Lock()
+	defer reconnectMu.Unlock()
+	return reconnectCancel != nil
+}
+
+// reconnected is called once login succeeds. When it ends a reconnect the
+// gap is reported and bookmarked in the recording.
+func reconnected() {
+	reconnectMu.Lock()
+	if reconnectCancel == nil {
+		reconnectMu.Unlock()
+		return
+	}
+	// The loop's context only covers the waits; it is released when the
+	// session ends.
+	reconnectCancel = nil
+	gap := time.Since(reconnectLostAt).Round(time.Second)
+	movie, frame := reconnectMovie, reconnectFrame
+	reconnectMu.Unlock()
+
+	consoleMessage(fmt.Sprintf("Reconnected after %v.", gap))
+	if movie != "" {
+		if err := markRecordingGap(movie, frame, fmt.Sprintf("Reconnected after %v", gap)); err != nil {
+			logError("movie notes: %v", err)
+		}
+	}
+}
+
+// markRecordingGap adds a "Connection lost" bookmark at frame to the notes
+// of the movie being recorded at path.
+func markRecordingGap(path string, frame int32, text string) error {
+	notesPath := movieNotesPath(path)
+	notes, err := loadMovieNotes(notesPath)
+	if err != nil {
+		return err
+	}
+	notes = append(notes, movieNote{Frame: frame, Name: "Connection lost", Text: text})
+	sortMovieNotes(notes)
+	return saveMovieNotes(notesPath, notes)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gothoom/internal/fakeserver"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		d := reconnectDelay(attempt)
+		if d < want || d > want+want/4 {
+			t.Errorf("attempt %d: delay %v, want %v plus jitter", attempt, d, want)
+		}
+	}
+	if d := reconnectDelay(30); d < reconnectMaxDelay || d > reconnectMaxDelay+reconnectMaxDelay/4 {
+		t.Errorf("delay %v not capped at %v", d, reconnectMaxDelay)
+	}
+}
+
+func TestRetryableLoginError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("tcp connect: connection refused"), true},
+		{loginError(-30981), true}, // kCharOnline
+		{loginError(-30992), true}, // kShuttingDown
+		{loginError(-30998), false},
+		{fmt.Errorf("attempt: %w", loginError(-30999)), false},
+	}
+	for _, c := range cases {
+		if got := retryableLoginError(c.err); got != c.want {
+			t.Errorf("retryableLoginError(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+	if got := loginError(-30998).Error(); got != "login failed: kBadCharPass (-30998)" {
+		t.Errorf("loginError text %q", got)
+	}
+}
+
+// Drop the connection from the server side and expect the client to log
+// back in on its own.
+func TestAutoReconnect(t *testing.T) {
+	logins := make(chan string, 8)
+	srv, err := fakeserver.Listen(fakeserver.Config{
+		Password:      "secret",
+		FrameInterval: 20 * time.Millisecond,
+		Logf: func(format string, args ...any) {
+			if msg := fmt.Sprintf(format, args...); strings.Contains(msg, "logged in") {
+				logins <- msg
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	go srv.Serve(ctx)
+
+	origHost, origName, origPass, origHash := host, name, pass, passHash
+	origCtx, origAuto := gameCtx, gs.AutoReconnect
+	defer func() {
+		stopReconnect()
+		loginMu.Lock()
+		if loginCancel != nil {
+			loginCancel()
+			loginCancel = nil
+		}
+		loginMu.Unlock()
+		host, name, pass, passHash = origHost, origName, origPass, origHash
+		gameCtx, gs.AutoReconnect = origCtx, origAuto
+	}()
+	host, name, pass, passHash = srv.Addr(), "Tester", "secret", ""
+	gameCtx, gs.AutoReconnect = ctx, true
+
+	sessCtx, logout := context.WithCancel(ctx)
+	loginMu.Lock()
+	loginCancel = logout
+	loginMu.Unlock()
+	go login(sessCtx, clVersion)
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-logins:
+		case <-ctx.Done():
+			t.Fatalf("no %s", what)
+		}
+	}
+	wait("login")
+	srv.DisconnectAll()
+	wait("reconnect")
+	if pass != "secret" {
+		t.Fatalf("password cleared by reconnect")
+	}
+}
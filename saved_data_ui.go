@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"gothoom/eui"
 
@@ -25,7 +27,7 @@ func makeSavedDataWindow() {
 	}
 	savedDataWin = eui.NewWindow()
 	savedDataWin.Title = "Saved Data"
-	savedDataWin.Size = eui.Point{X: 320, Y: 240}
+	savedDataWin.Size = eui.Point{X: 460, Y: 240}
 	savedDataWin.Closable = true
 	savedDataWin.Movable = true
 	savedDataWin.Resizable = true
@@ -61,43 +63,119 @@ func refreshSavedDataList() {
 	scriptMu.RUnlock()
 	sort.Strings(owners)
 
-	for _, o := range owners {
-		path := scriptStoragePath(o)
-		fi, err := os.Stat(path)
-		if err != nil || fi.Size() == 0 {
-			continue
+	// Shared data first, then each character's.
+	chars := append([]string{""}, scriptStorageChars()...)
+	sort.Strings(chars[1:])
+	for _, char := range chars {
+		for _, o := range owners {
+			addSavedDataRow(o, char)
 		}
-		ps := getscriptStore(o)
-		ps.mu.Lock()
-		count := len(ps.data)
-		ps.mu.Unlock()
-		if count == 0 {
-			continue
-		}
-		disp := getscriptDisplayName(o)
-		row := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL}
-		txt, _ := eui.NewText()
-		txt.Text = fmt.Sprintf("%s (%d entries, %s)", disp, count, humanize.Bytes(uint64(fi.Size())))
-		txt.Size = eui.Point{X: 240, Y: 24}
-		row.AddItem(txt)
-		viewBtn, vh := eui.NewButton()
-		viewBtn.Text = "View"
-		viewBtn.Size = eui.Point{X: 64, Y: 24}
-		owner := o
-		vh.Handle = func(ev eui.UIEvent) {
+	}
+	if savedDataWin != nil {
+		savedDataWin.Refresh()
+	}
+}
+
+// savedDataStore returns owner's shared store, or its store for char.
+func savedDataStore(owner, char string) *scriptStore {
+	if char == "" {
+		return getscriptStore(owner)
+	}
+	return getscriptCharStore(owner, char)
+}
+
+func savedDataTitle(owner, char string) string {
+	disp := getscriptDisplayName(owner)
+	if char != "" {
+		disp += " [" + char + "]"
+	}
+	return disp
+}
+
+func addSavedDataRow(owner, char string) {
+	path := scriptStoragePath(owner)
+	if char != "" {
+		path = scriptCharStoragePath(owner, char)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() == 0 {
+		return
+	}
+	ps := savedDataStore(owner, char)
+	ps.mu.Lock()
+	count := len(ps.data)
+	ps.mu.Unlock()
+	if count == 0 {
+		return
+	}
+	row := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL}
+	txt, _ := eui.NewText()
+	txt.Text = fmt.Sprintf("%s (%d entries, %s)", savedDataTitle(owner, char), count, humanize.Bytes(uint64(fi.Size())))
+	txt.Size = eui.Point{X: 240, Y: 24}
+	row.AddItem(txt)
+	button := func(label string, fn func()) {
+		b, h := eui.NewButton()
+		b.Text = label
+		b.Size = eui.Point{X: 64, Y: 24}
+		h.Handle = func(ev eui.UIEvent) {
 			if ev.Type == eui.EventClick {
-				showSavedDataEntries(owner)
+				fn()
 			}
 		}
-		row.AddItem(viewBtn)
-		savedDataList.AddItem(row)
+		row.AddItem(b)
 	}
-	if savedDataWin != nil {
-		savedDataWin.Refresh()
+	button("View", func() { showSavedDataEntries(owner, char) })
+	button("Export", func() { exportSavedData(owner, char) })
+	button("Clear", func() { confirmClearSavedData(owner, char) })
+	savedDataList.AddItem(row)
+}
+
+// exportSavedData writes a copy of the store to the Exports folder.
+func exportSavedData(owner, char string) {
+	ps := savedDataStore(owner, char)
+	ps.mu.Lock()
+	data, err := json.MarshalIndent(ps.data, "", "  ")
+	ps.mu.Unlock()
+	if err != nil {
+		logError("export script data: %v", err)
+		return
+	}
+	dir := filepath.Join(dataDirPath, "Exports")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logError("export script data: create %v: %v", dir, err)
+		return
 	}
+	name := sanitizeName(getscriptDisplayName(owner))
+	if char != "" {
+		name += "-" + char
+	}
+	fn := filepath.Join(dir, fmt.Sprintf("%s__%s.json", name, time.Now().Format("2006-01-02-15-04-05")))
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		logError("export script data: %v", err)
+		return
+	}
+	consoleMessage("Exported " + savedDataTitle(owner, char) + " data to " + fn)
+}
+
+func confirmClearSavedData(owner, char string) {
+	showPopup(
+		"Clear Data",
+		fmt.Sprintf("Delete all saved data for %s?", savedDataTitle(owner, char)),
+		[]popupButton{
+			{Text: "Cancel"},
+			{Text: "Clear", Color: &eui.ColorDarkRed, HoverColor: &eui.ColorRed, Action: func() {
+				savedDataStore(owner, char).clear()
+				savescriptStores()
+				refreshSavedDataList()
+				if dataEntriesWin != nil && dataEntriesWin.IsOpen() {
+					refreshSavedDataEntries(owner, char)
+				}
+			}},
+		},
+	)
 }
 
-func showSavedDataEntries(owner string) {
+func showSavedDataEntries(owner, char string) {
 	if dataEntriesWin == nil {
 		dataEntriesWin = eui.NewWindow()
 		dataEntriesWin.Size = eui.Point{X: 320, Y: 240}
@@ -110,25 +188,25 @@ func showSavedDataEntries(owner string) {
 		dataEntriesWin.AddItem(flow)
 		dataEntriesList = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true, Fixed: true}
 		flow.AddItem(dataEntriesList)
-		dataEntriesWin.OnResize = func() {
-			refreshSavedDataEntries(owner)
-			if dataEntriesWin != nil {
-				dataEntriesWin.Refresh()
-			}
-		}
 		dataEntriesWin.AddWindow(false)
 	}
-	dataEntriesWin.Title = getscriptDisplayName(owner) + " Data"
-	refreshSavedDataEntries(owner)
+	dataEntriesWin.OnResize = func() {
+		refreshSavedDataEntries(owner, char)
+		if dataEntriesWin != nil {
+			dataEntriesWin.Refresh()
+		}
+	}
+	dataEntriesWin.Title = savedDataTitle(owner, char) + " Data"
+	refreshSavedDataEntries(owner, char)
 	dataEntriesWin.MarkOpen()
 }
 
-func refreshSavedDataEntries(owner string) {
+func refreshSavedDataEntries(owner, char string) {
 	if dataEntriesList == nil {
 		return
 	}
 	dataEntriesList.Contents = dataEntriesList.Contents[:0]
-	ps := getscriptStore(owner)
+	ps := savedDataStore(owner, char)
 	ps.mu.Lock()
 	keys := make([]string, 0, len(ps.data))
 	for k := range ps.data {
@@ -78,6 +78,14 @@ var basescriptExports = interp.Exports{
 		"LastClick":        reflect.ValueOf(scriptLastClick),
 		"ClickInfo":        reflect.ValueOf((*ClickInfo)(nil)),
 		"Mobile":           reflect.ValueOf((*Mobile)(nil)),
+		"Mobiles":          reflect.ValueOf(scriptMobiles),
+		"Picture":          reflect.ValueOf((*Picture)(nil)),
+		"Pictures":         reflect.ValueOf(scriptPictures),
+		"Descriptor":       reflect.ValueOf((*Descriptor)(nil)),
+		"Descriptors":      reflect.ValueOf(scriptDescriptors),
+		"SelfInfo":         reflect.ValueOf((*SelfInfo)(nil)),
+		"FrameInfo":        reflect.ValueOf((*FrameInfo)(nil)),
+		"Self":             reflect.ValueOf(scriptSelf),
 		"EquippedItems":    reflect.ValueOf(scriptEquippedItems),
 		"HasItem":          reflect.ValueOf(scriptHasItem),
 		"IsEquipped":       reflect.ValueOf(scriptIsEquipped),
@@ -103,6 +111,10 @@ var basescriptExports = interp.Exports{
 		"ChatCreature": reflect.ValueOf(ChatCreature),
 		"ChatSelf":     reflect.ValueOf(ChatSelf),
 		"ChatOther":    reflect.ValueOf(ChatOther),
+		// Descriptor types
+		"DescPlayer":  reflect.ValueOf(kDescPlayer),
+		"DescMonster": reflect.ValueOf(kDescMonster),
+		"DescNPC":     reflect.ValueOf(kDescNPC),
 	},
 }
 
@@ -131,6 +143,25 @@ func exportsForscript(owner string) interp.Exports {
 		// Chat/Console (simple, no slices)
 		// Simple DSL aliases
 		m["Print"] = reflect.ValueOf(scriptConsole)
+		// Windows
+		m["NewWindow"] = reflect.ValueOf(func(title string) int { return scriptNewWindow(owner, title) })
+		m["ShowWindow"] = reflect.ValueOf(func(w int) { scriptShowWindow(owner, w) })
+		m["HideWindow"] = reflect.ValueOf(func(w int) { scriptHideWindow(owner, w) })
+		m["ToggleWindow"] = reflect.ValueOf(func(w int) { scriptToggleWindow(owner, w) })
+		m["ClearWindow"] = reflect.ValueOf(func(w int) { scriptClearWindow(owner, w) })
+		m["AddText"] = reflect.ValueOf(func(w int, txt string) int { return scriptAddText(owner, w, txt) })
+		m["AddButton"] = reflect.ValueOf(func(w int, label string, fn func()) int { return scriptAddButton(owner, w, label, fn) })
+		m["AddCheckbox"] = reflect.ValueOf(func(w int, label string, checked bool, fn func(bool)) int {
+			return scriptAddCheckbox(owner, w, label, checked, fn)
+		})
+		m["AddSlider"] = reflect.ValueOf(func(w int, label string, min, max, value float64, fn func(float64)) int {
+			return scriptAddSlider(owner, w, label, min, max, value, fn)
+		})
+		m["AddList"] = reflect.ValueOf(func(w int, lines []string) int { return scriptAddList(owner, w, lines) })
+		m["SetText"] = reflect.ValueOf(func(id int, txt string) { scriptSetText(owner, id, txt) })
+		m["SetChecked"] = reflect.ValueOf(func(id int, on bool) { scriptSetChecked(owner, id, on) })
+		m["SetValue"] = reflect.ValueOf(func(id int, v float64) { scriptSetValue(owner, id, v) })
+		m["SetList"] = reflect.ValueOf(func(id int, lines []string) { scriptSetList(owner, id, lines) })
 		m["Notify"] = reflect.ValueOf(scriptShowNotification)
 		m["Cmd"] = reflect.ValueOf(func(text string) { scriptEnqueueCommand(owner, strings.TrimSpace(text)) })
 		m["Run"] = reflect.ValueOf(func(text string) { scriptRunCommand(owner, strings.TrimSpace(text)) })
@@ -144,6 +175,15 @@ func exportsForscript(owner string) interp.Exports {
 			return ""
 		})
 		m["Delete"] = reflect.ValueOf(func(key string) { scriptStorageDelete(owner, key) })
+		m["CharSave"] = reflect.ValueOf(func(key, value string) { scriptCharStorageSet(owner, key, value) })
+		m["CharLoad"] = reflect.ValueOf(func(key string) string {
+			if v, ok := scriptCharStorageGet(owner, key).(string); ok {
+				return v
+			}
+			return ""
+		})
+		m["CharDelete"] = reflect.ValueOf(func(key string) { scriptCharStorageDelete(owner, key) })
+		m["MoveToChar"] = reflect.ValueOf(func(key string) bool { return scriptMoveToChar(owner, key) })
 		m["Input"] = reflect.ValueOf(scriptInputText)
 		m["SetInput"] = reflect.ValueOf(scriptSetInputText)
 		// (Removed explicit Thank/Curse/Share/Unshare helpers to avoid duplicating
@@ -245,6 +285,16 @@ func exportsForscript(owner string) interp.Exports {
 		m["RegisterPlayerHandler"] = reflect.ValueOf(func(fn func(Player)) { scriptRegisterPlayerHandler(owner, fn) })
 		m["RegisterInputHandler"] = reflect.ValueOf(func(fn func(string) string) { scriptRegisterInputHandler(owner, fn) })
 		m["RegisterChatHandler"] = reflect.ValueOf(func(fn func(string)) { scriptRegisterChatHandler(owner, fn) })
+		m["OnFrame"] = reflect.ValueOf(func(fn func(FrameInfo)) { scriptRegisterFrameHandler(owner, fn) })
+		m["OnVitals"] = reflect.ValueOf(func(fn func(hp, hpMax, sp, spMax, bal, balMax int)) {
+			scriptRegisterVitalsHandler(owner, fn)
+		})
+		m["OnCharacter"] = reflect.ValueOf(func(fn func(string)) { scriptRegisterCharacterHandler(owner, fn) })
+		m["WalkTo"] = reflect.ValueOf(func(h, v int) { scriptWalkTo(owner, h, v) })
+		m["ClickMobile"] = reflect.ValueOf(func(name string) bool { return scriptClickMobile(owner, name) })
+		m["StopWalking"] = reflect.ValueOf(func() { scriptStopWalking(owner) })
+		m["Publish"] = reflect.ValueOf(func(topic string, payload any) { scriptPublish(owner, topic, payload) })
+		m["Subscribe"] = reflect.ValueOf(func(topic string, fn func(any)) { scriptSubscribe(owner, topic, fn) })
 		// Simple world overlay drawing (top-left origin, world units)
 		m["OverlayClear"] = reflect.ValueOf(func() { scriptOverlayClear(owner) })
 		m["OverlayRect"] = reflect.ValueOf(func(x, y, w, h int, r, g, b, a uint8) {
@@ -276,7 +326,7 @@ func exportsForscript(owner string) interp.Exports {
 			if fn == nil || ms <= 0 {
 				return
 			}
-			t := time.AfterFunc(time.Duration(ms)*time.Millisecond, fn)
+			t := time.AfterFunc(time.Duration(ms)*time.Millisecond, func() { scriptCall(owner, "After", fn) })
 			scriptMu.Lock()
 			scriptTimers[owner] = append(scriptTimers[owner], t)
 			scriptMu.Unlock()
@@ -285,7 +335,7 @@ func exportsForscript(owner string) interp.Exports {
 			if fn == nil || d <= 0 {
 				return
 			}
-			t := time.AfterFunc(d, fn)
+			t := time.AfterFunc(d, func() { scriptCall(owner, "After", fn) })
 			scriptMu.Lock()
 			scriptTimers[owner] = append(scriptTimers[owner], t)
 			scriptMu.Unlock()
@@ -305,7 +355,7 @@ func exportsForscript(owner string) interp.Exports {
 				for {
 					select {
 					case <-ticker.C:
-						fn()
+						scriptCall(owner, "Every", fn)
 					case <-stop:
 						return
 					}
@@ -326,7 +376,7 @@ func exportsForscript(owner string) interp.Exports {
 				for {
 					select {
 					case <-ticker.C:
-						fn()
+						scriptCall(owner, "Every", fn)
 					case <-stop:
 						return
 					}
@@ -344,6 +394,7 @@ func exportsForscript(owner string) interp.Exports {
 			scriptRegisterCommand(owner, cmd, func(args string) { handler() })
 			scriptAddHotkey(owner, c, "/"+cmd)
 		})
+		filterscriptExports(owner, m)
 		ex[pkg] = m
 	}
 	return ex
@@ -489,6 +540,7 @@ func init() {
 
 func scriptGoroutineWatchdog() {
 	for {
+		scriptCheckBudgets()
 		if runtime.NumGoroutine() > scriptGoroutineLimit {
 			log.Printf("[script] goroutine limit exceeded; stopping all scripts")
 			consoleMessage("[script] goroutine limit exceeded; stopping scripts")
@@ -530,6 +582,10 @@ func scriptLogEvent(owner, ev, data string) {
 }
 
 func scriptShowNotification(msg string) {
+	if scriptTestRec != nil {
+		scriptTestRec.notify(msg)
+		return
+	}
 	showNotification(msg)
 }
 
@@ -733,6 +789,7 @@ var (
 	scriptCommandOwners = map[string]string{}
 	scriptSendHistory   = map[string][]time.Time{}
 	scriptModTime       time.Time
+	scriptLibMod        time.Time
 	scriptModCheck      time.Time
 	// timers per script owner
 	scriptTimers      = map[string][]*time.Timer{}
@@ -770,7 +827,9 @@ func scriptSleepTicks(owner string, ticks int) {
 	scriptMu.Lock()
 	scriptTickWaiters[owner] = append(scriptTickWaiters[owner], w)
 	scriptMu.Unlock()
+	start := time.Now()
 	<-w.done
+	scriptAddSleep(owner, start)
 }
 
 func scriptAdvanceTick() {
@@ -849,6 +908,10 @@ func scriptRunCommand(owner, cmd string) {
 	if cmd == "" {
 		return
 	}
+	if scriptTestRec != nil {
+		scriptTestRec.command(cmd)
+		return
+	}
 	consoleMessage("> " + cmd)
 	enqueueCommand(cmd)
 	nextCommand()
@@ -866,14 +929,30 @@ func scriptEnqueueCommand(owner, cmd string) {
 	if cmd == "" {
 		return
 	}
+	if scriptTestRec != nil {
+		scriptTestRec.command(cmd)
+		return
+	}
 	enqueueCommand(cmd)
 }
 
 func loadscriptSource(owner, name, path string, src []byte, restricted interp.Exports) {
 	scriptRemoveConfig(owner)
-	i := interp.New(interp.Options{})
+	// Imports of "lib/..." resolve to the lib folder next to the script.
+	i := interp.New(interp.Options{
+		GoPath:               ".",
+		BuildTags:            []string{"script"},
+		SourcecodeFilesystem: scriptLibFS{dir: filepath.Join(filepath.Dir(path), scriptLibDirName)},
+	})
 	if len(restricted) > 0 {
 		i.Use(restricted)
+		// Route time.Sleep through the accounting so sleeps are not billed
+		// against the script's handler budget.
+		if _, ok := restricted["time/time"]; ok {
+			i.Use(interp.Exports{"time/time": {
+				"Sleep": reflect.ValueOf(func(d time.Duration) { scriptSleep(owner, d) }),
+			}})
+		}
 	}
 	i.Use(exportsForscript(owner))
 	scriptMu.Lock()
@@ -884,6 +963,7 @@ func loadscriptSource(owner, name, path string, src []byte, restricted interp.Ex
 	if _, err := i.Eval(string(src)); err != nil {
 		log.Printf("script %s: %v", path, err)
 		consoleMessage("[script] load error for " + path + ": " + err.Error())
+		scriptRecordError(owner, "load error: "+err.Error())
 		disablescript(owner, "load error")
 		return
 	}
@@ -896,7 +976,7 @@ func loadscriptSource(owner, name, path string, src []byte, restricted interp.Ex
 	}
 	if v, err := i.Eval("Init"); err == nil {
 		if fn, ok := v.Interface().(func()); ok {
-			go fn()
+			scriptGo(owner, "Init", fn)
 		}
 	}
 	log.Printf("loaded script %s", path)
@@ -937,6 +1017,10 @@ func enablescript(owner string) {
 	if path == "" {
 		return
 	}
+	if !scriptConsented(owner) {
+		requestscriptConsent(owner)
+		return
+	}
 	src, err := os.ReadFile(path)
 	if err != nil {
 		log.Printf("read script %s: %v", path, err)
@@ -986,7 +1070,7 @@ func disablescript(owner, reason string) {
 	delete(scriptTerminators, owner)
 	scriptMu.Unlock()
 	if term != nil {
-		go term()
+		scriptGo(owner, "Terminate", term)
 	}
 	for _, hk := range scriptHotkeys(owner) {
 		scriptRemoveHotkey(owner, hk.Combo)
@@ -1055,6 +1139,11 @@ func disablescript(owner, reason string) {
 		}
 	}
 	chatHandlersMu.Unlock()
+	scriptRemoveFrameHandlers(owner)
+	scriptRemoveCharacterHandlers(owner)
+	scriptRemoveSubscriptions(owner)
+	scriptRemoveMovement(owner)
+	scriptRemoveWindows(owner)
 	// Clear overlay ops
 	overlayMu.Lock()
 	delete(scriptOverlayOps, owner)
@@ -1545,7 +1634,8 @@ func runInputHandlers(txt string) string {
 	for _, h := range handlers {
 		if h.fn != nil {
 			scriptLogEvent(h.owner, "InputHandler", txt)
-			txt = h.fn(txt)
+			in := txt
+			scriptCall(h.owner, "InputHandler", func() { txt = h.fn(in) })
 		}
 	}
 	return txt
@@ -1609,7 +1699,7 @@ func runChatTriggers(msg string) {
 					owner := h.owner
 					fn := h.fn
 					scriptLogEvent(owner, "ChatTrigger", fmt.Sprintf("%q %q", phrase, msg))
-					go fn(msg)
+					scriptGo(owner, "ChatTrigger", func() { fn(msg) })
 				}
 			}
 		}
@@ -1623,7 +1713,8 @@ func runChatTriggers(msg string) {
 	for _, h := range handlers {
 		if h.fn != nil {
 			scriptLogEvent(h.owner, "ChatHandler", msg)
-			go h.fn(msg)
+			fn := h.fn
+			scriptGo(h.owner, "ChatHandler", func() { fn(msg) })
 		}
 	}
 }
@@ -1652,7 +1743,7 @@ func runConsoleTriggers(msg string) {
 			for _, h := range hs {
 				scriptLogEvent(h.owner, "ConsoleTrigger", fmt.Sprintf("%q %q", phrase, msg))
 				fn := h.fn
-				go fn(msg)
+				scriptGo(h.owner, "ConsoleTrigger", func() { fn(msg) })
 			}
 		}
 	}
@@ -1674,6 +1765,9 @@ func scriptOverlayRect(owner string, x, y, w, h int, r, g, b, a uint8) {
 	if w <= 0 || h <= 0 {
 		return
 	}
+	if !scriptOverlayRoom(owner) {
+		return
+	}
 	overlayMu.Lock()
 	scriptOverlayOps[owner] = append(scriptOverlayOps[owner], overlayOp{kind: 0, x: x, y: y, w: w, h: h, r: r, g: g, b: b, a: a})
 	overlayMu.Unlock()
@@ -1683,6 +1777,9 @@ func scriptOverlayText(owner string, x, y int, txt string, r, g, b, a uint8) {
 	if txt == "" {
 		return
 	}
+	if !scriptOverlayRoom(owner) {
+		return
+	}
 	overlayMu.Lock()
 	scriptOverlayOps[owner] = append(scriptOverlayOps[owner], overlayOp{kind: 1, x: x, y: y, text: txt, r: r, g: g, b: b, a: a})
 	overlayMu.Unlock()
@@ -1692,11 +1789,26 @@ func scriptOverlayImage(owner string, id uint16, x, y int) {
 	if id == 0xffff || id == 0 {
 		return
 	}
+	if !scriptOverlayRoom(owner) {
+		return
+	}
 	overlayMu.Lock()
 	scriptOverlayOps[owner] = append(scriptOverlayOps[owner], overlayOp{kind: 2, x: x, y: y, id: id, a: 255, r: 255, g: 255, b: 255})
 	overlayMu.Unlock()
 }
 
+// scriptOverlayRoom reports whether owner may queue another overlay op.
+func scriptOverlayRoom(owner string) bool {
+	overlayMu.RLock()
+	n := len(scriptOverlayOps[owner])
+	overlayMu.RUnlock()
+	if n >= scriptOverlayMaxOps {
+		scriptRecordError(owner, fmt.Sprintf("overlay limit of %d draw calls reached", scriptOverlayMaxOps))
+		return false
+	}
+	return true
+}
+
 func refreshscriptMod() {
 	if isWASM {
 		return
@@ -1730,6 +1842,8 @@ type scriptInfo struct {
 	src         []byte
 	invalid     bool
 	apiVer      int
+	perms       []string
+	permsDecl   bool
 }
 
 func scanscripts(scriptDirs []string, dup func(name, path string)) map[string]scriptInfo {
@@ -1810,6 +1924,17 @@ func scanscripts(scriptDirs []string, dup func(name, path string)) map[string]sc
 					apiVer = n
 				}
 			}
+			perms, permsDecl, unknown := parsescriptPerms(src)
+			if len(unknown) > 0 {
+				consoleMessage("[script] unknown permission " + strings.Join(unknown, ", ") + ": " + path)
+				invalid = true
+			}
+			if permsDecl {
+				if missing := scriptMissingPerms(src, perms); len(missing) > 0 {
+					consoleMessage("[script] undeclared permission " + strings.Join(missing, ", ") + ": " + path)
+					invalid = true
+				}
+			}
 			lower := strings.ToLower(name)
 			if seenNames[lower] {
 				if dup != nil {
@@ -1828,6 +1953,8 @@ func scanscripts(scriptDirs []string, dup func(name, path string)) map[string]sc
 				src:         src,
 				invalid:     invalid,
 				apiVer:      apiVer,
+				perms:       perms,
+				permsDecl:   permsDecl,
 			}
 		}
 	}
@@ -1865,9 +1992,13 @@ func rescanscripts() {
 	scriptSubCategories = make(map[string]string, len(scanned))
 	scriptInvalid = make(map[string]bool, len(scanned))
 	scriptDisabled = make(map[string]bool, len(scanned))
+	scriptPerms = make(map[string][]string, len(scanned))
 	newEnabled := map[string]scriptScope{}
 	for o, info := range scanned {
 		scriptDisplayNames[o] = info.name
+		if info.permsDecl {
+			scriptPerms[o] = info.perms
+		}
 		scriptPaths[o] = info.path
 		scriptAuthors[o] = info.author
 		scriptCategories[o] = info.category
@@ -1917,6 +2048,11 @@ func checkForScriptEdit() {
 	if scriptModTime.After(old) {
 		rescanscripts()
 	}
+	oldLib := scriptLibMod
+	scriptLibMod = scriptLibModTime()
+	if scriptLibMod.After(oldLib) {
+		reloadLibscripts()
+	}
 }
 
 func loadScripts() {
@@ -1930,6 +2066,13 @@ func loadScripts() {
 		consoleMessage("[script] duplicate name: " + name)
 	})
 
+	// Scripts enabled before permissions were tracked keep running
+	// without asking.
+	migrate := gs.ScriptConsent == nil
+	if migrate {
+		gs.ScriptConsent = map[string]string{}
+		settingsDirty = true
+	}
 	scriptNames = make(map[string]bool, len(scanned))
 	for o, info := range scanned {
 		scriptNames[strings.ToLower(info.name)] = true
@@ -1954,9 +2097,24 @@ func loadScripts() {
 			scriptEnabledFor[o] = s
 		}
 		scriptAuthors[o] = info.author
+		if info.permsDecl {
+			scriptPerms[o] = info.perms
+		} else {
+			delete(scriptPerms, o)
+		}
 		scriptInvalid[o] = invalid
 		scriptDisabled[o] = disabled
 		scriptMu.Unlock()
+		if migrate && !s.empty() {
+			gs.ScriptConsent[o] = scriptPermString(o)
+		}
+		if !disabled && !scriptConsented(o) {
+			scriptMu.Lock()
+			scriptDisabled[o] = true
+			scriptMu.Unlock()
+			requestscriptConsent(o)
+			continue
+		}
 		if !disabled {
 			loadscriptSource(o, info.name, info.path, info.src, restrictedStdlib())
 		}
@@ -1971,6 +2129,7 @@ func loadScripts() {
 	refreshHotkeysList()
 	refreshscriptsWindow()
 	refreshscriptMod()
+	scriptLibMod = scriptLibModTime()
 }
 
 // scopeFromSettingValue converts a settings value into a scriptScope.
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Scripts talk to each other through named topics. Payloads are copied by
+// a JSON round trip so no script receives another interpreter's values.
+
+type busHandler struct {
+	owner string
+	fn    func(any)
+}
+
+var (
+	scriptBusMu   sync.RWMutex
+	scriptBusSubs = map[string][]busHandler{}
+)
+
+func scriptSubscribe(owner, topic string, fn func(any)) {
+	topic = strings.TrimSpace(topic)
+	if scriptIsDisabled(owner) || topic == "" || fn == nil {
+		return
+	}
+	scriptBusMu.Lock()
+	scriptBusSubs[topic] = append(scriptBusSubs[topic], busHandler{owner: owner, fn: fn})
+	scriptBusMu.Unlock()
+}
+
+// scriptPublish delivers payload to every subscriber of topic, including
+// owner itself. Payloads that cannot be encoded as JSON are dropped.
+func scriptPublish(owner, topic string, payload any) {
+	topic = strings.TrimSpace(topic)
+	if scriptIsDisabled(owner) || topic == "" {
+		return
+	}
+	scriptBusMu.RLock()
+	subs := append([]busHandler{}, scriptBusSubs[topic]...)
+	scriptBusMu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		scriptRecordError(owner, "publish "+topic+": "+err.Error())
+		return
+	}
+	for _, h := range subs {
+		if scriptIsDisabled(h.owner) {
+			continue
+		}
+		// Decode per subscriber so they cannot share maps or slices.
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			continue
+		}
+		scriptLogEvent(h.owner, "Subscribe", topic)
+		fn := h.fn
+		scriptGo(h.owner, "Subscribe", func() { fn(v) })
+	}
+}
+
+// scriptRemoveSubscriptions drops every subscription owned by owner.
+func scriptRemoveSubscriptions(owner string) {
+	scriptBusMu.Lock()
+	for topic, hs := range scriptBusSubs {
+		n := 0
+		for _, h := range hs {
+			if h.owner != owner {
+				hs[n] = h
+				n++
+			}
+		}
+		if n == 0 {
+			delete(scriptBusSubs, topic)
+		} else {
+			scriptBusSubs[topic] = hs[:n]
+		}
+	}
+	scriptBusMu.Unlock()
+}
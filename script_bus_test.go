@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that published payloads reach each subscriber as a separate JSON
+// copy and that subscriptions end with their script.
+func TestScriptBus(t *testing.T) {
+	scriptMu = sync.RWMutex{}
+	scriptDisabled = map[string]bool{}
+	scriptBusSubs = map[string][]busHandler{}
+
+	got := make(chan map[string]any, 2)
+	recv := func(v any) {
+		m, _ := v.(map[string]any)
+		got <- m
+	}
+	scriptSubscribe("a", "rank", recv)
+	scriptSubscribe("b", "rank", recv)
+	scriptSubscribe("b", "other", recv)
+
+	scriptPublish("pub", "rank", map[string]any{"rank": "10-19", "n": 3})
+	var first map[string]any
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-got:
+			if m["rank"] != "10-19" || m["n"] != float64(3) {
+				t.Fatalf("payload %v", m)
+			}
+			if first == nil {
+				first = m
+				m["rank"] = "changed"
+			} else if m["rank"] != "10-19" {
+				t.Fatalf("subscribers share a payload")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %d not called", i)
+		}
+	}
+
+	scriptRemoveSubscriptions("b")
+	if len(scriptBusSubs["rank"]) != 1 || scriptBusSubs["other"] != nil {
+		t.Fatalf("subscriptions left: %v", scriptBusSubs)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// Test that character storage is separate per character and that shared
+// values can be moved into it once.
+func TestScriptCharStorage(t *testing.T) {
+	origDir, origPlayer := dataDirPath, playerName
+	dataDirPath = t.TempDir()
+	t.Cleanup(func() { dataDirPath, playerName = origDir, origPlayer })
+
+	owner := "plug_chars"
+	scriptMu.Lock()
+	scriptDisplayNames[owner] = "Chars"
+	scriptAuthors[owner] = "Auth"
+	scriptMu.Unlock()
+	scriptStoreMu.Lock()
+	scriptStores = map[string]*scriptStore{}
+	scriptStoreMu.Unlock()
+
+	scriptStorageSet(owner, "total", "12")
+	playerName = "Alice Smith"
+	if !scriptMoveToChar(owner, "total") {
+		t.Fatalf("shared value not moved")
+	}
+	if scriptMoveToChar(owner, "total") {
+		t.Fatalf("value moved twice")
+	}
+	if v := scriptCharStorageGet(owner, "total"); v != "12" {
+		t.Fatalf("Alice total = %v", v)
+	}
+	if scriptStorageGet(owner, "total") != nil {
+		t.Fatalf("shared value kept after move")
+	}
+
+	playerName = "Bob"
+	if v := scriptCharStorageGet(owner, "total"); v != nil {
+		t.Fatalf("Bob sees Alice's total %v", v)
+	}
+	scriptCharStorageSet(owner, "total", "3")
+
+	savescriptStores()
+	if _, err := os.Stat(scriptCharStoragePath(owner, "Alice_Smith")); err != nil {
+		t.Fatalf("Alice store not saved: %v", err)
+	}
+	chars := scriptStorageChars()
+	if len(chars) != 2 {
+		t.Fatalf("chars = %q", chars)
+	}
+}
+
+// Test that OnCharacter handlers run when the character changes, but not
+// when the same character logs in again.
+func TestScriptCharacterHandler(t *testing.T) {
+	origPlayer := playerName
+	t.Cleanup(func() {
+		playerName = origPlayer
+		scriptRemoveCharacterHandlers("plug_charev")
+		characterHandlersMu.Lock()
+		lastScriptCharNotified = ""
+		characterHandlersMu.Unlock()
+	})
+
+	got := make(chan string, 4)
+	scriptRegisterCharacterHandler("plug_charev", func(name string) { got <- name })
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case name := <-got:
+			if name != want {
+				t.Fatalf("handler got %q, want %q", name, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("handler not run for %q", want)
+		}
+	}
+
+	playerName = "Alice"
+	runCharacterHandlers()
+	expect("Alice")
+	runCharacterHandlers()
+	playerName = "Bob"
+	runCharacterHandlers()
+	expect("Bob")
+	select {
+	case name := <-got:
+		t.Fatalf("extra call for %q", name)
+	default:
+	}
+}
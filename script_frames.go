@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FrameInfo describes a draw state passed to OnFrame handlers.
+type FrameInfo struct {
+	Frame    int   // local frame counter
+	Ack      int32 // server frame number
+	Dropped  int   // frames lost since the previous one
+	Mobiles  int
+	Pictures int
+}
+
+type frameHandler struct {
+	owner string
+	fn    func(FrameInfo)
+}
+
+type vitalsHandler struct {
+	owner string
+	fn    func(hp, hpMax, sp, spMax, bal, balMax int)
+}
+
+var (
+	frameHandlersMu      sync.RWMutex
+	scriptFrameHandlers  []frameHandler
+	scriptVitalsHandlers []vitalsHandler
+	// lastVitals holds the values last sent to vitals handlers.
+	lastVitals [6]int
+)
+
+func scriptRegisterFrameHandler(owner string, fn func(FrameInfo)) {
+	if scriptIsDisabled(owner) || fn == nil {
+		return
+	}
+	frameHandlersMu.Lock()
+	scriptFrameHandlers = append(scriptFrameHandlers, frameHandler{owner: owner, fn: fn})
+	frameHandlersMu.Unlock()
+}
+
+func scriptRegisterVitalsHandler(owner string, fn func(hp, hpMax, sp, spMax, bal, balMax int)) {
+	if scriptIsDisabled(owner) || fn == nil {
+		return
+	}
+	frameHandlersMu.Lock()
+	scriptVitalsHandlers = append(scriptVitalsHandlers, vitalsHandler{owner: owner, fn: fn})
+	frameHandlersMu.Unlock()
+}
+
+// scriptRemoveFrameHandlers drops all frame and vitals handlers for owner.
+func scriptRemoveFrameHandlers(owner string) {
+	frameHandlersMu.Lock()
+	for i := len(scriptFrameHandlers) - 1; i >= 0; i-- {
+		if scriptFrameHandlers[i].owner == owner {
+			scriptFrameHandlers = append(scriptFrameHandlers[:i], scriptFrameHandlers[i+1:]...)
+		}
+	}
+	for i := len(scriptVitalsHandlers) - 1; i >= 0; i-- {
+		if scriptVitalsHandlers[i].owner == owner {
+			scriptVitalsHandlers = append(scriptVitalsHandlers[:i], scriptVitalsHandlers[i+1:]...)
+		}
+	}
+	frameHandlersMu.Unlock()
+}
+
+// runFrameHandlers notifies scripts after a draw state has been parsed.
+// Vitals handlers only fire when a value changed. Nothing fires while a
+// movie is seeking.
+func runFrameHandlers(ack int32) {
+	if seekingMov {
+		return
+	}
+	frameHandlersMu.RLock()
+	frames := append([]frameHandler{}, scriptFrameHandlers...)
+	vitals := append([]vitalsHandler{}, scriptVitalsHandlers...)
+	frameHandlersMu.RUnlock()
+	if len(frames) == 0 && len(vitals) == 0 {
+		return
+	}
+
+	stateMu.Lock()
+	info := FrameInfo{
+		Frame:    frameCounter,
+		Ack:      ack,
+		Dropped:  state.dropped,
+		Mobiles:  len(state.mobiles),
+		Pictures: len(state.pictures),
+	}
+	v := [6]int{state.hp, state.hpMax, state.sp, state.spMax, state.balance, state.balanceMax}
+	stateMu.Unlock()
+
+	for _, h := range frames {
+		scriptLogEvent(h.owner, "FrameHandler", fmt.Sprint(info.Frame))
+		fn := h.fn
+		scriptGo(h.owner, "FrameHandler", func() { fn(info) })
+	}
+
+	frameHandlersMu.Lock()
+	changed := v != lastVitals
+	lastVitals = v
+	frameHandlersMu.Unlock()
+	if !changed {
+		return
+	}
+	for _, h := range vitals {
+		scriptLogEvent(h.owner, "VitalsHandler", fmt.Sprint(v))
+		fn := h.fn
+		scriptGo(h.owner, "VitalsHandler", func() { fn(v[0], v[1], v[2], v[3], v[4], v[5]) })
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scriptLibDirName is the folder inside a scripts directory that holds
+// shared packages. A script imports scripts/lib/combat as "lib/combat".
+const scriptLibDirName = "lib"
+
+// scriptLibFS exposes a scripts/lib folder to the interpreter as
+// GOPATH/src/lib. Nothing else is visible, so scripts cannot import source
+// from anywhere but their libraries.
+type scriptLibFS struct {
+	dir string
+}
+
+func (l scriptLibFS) Open(name string) (fs.File, error) {
+	name = filepath.ToSlash(name)
+	rest, ok := strings.CutPrefix(name, "src/"+scriptLibDirName)
+	if !ok || (rest != "" && rest[0] != '/') || !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	rest = strings.TrimPrefix(rest, "/")
+	if rest == "" {
+		rest = "."
+	}
+	return os.DirFS(l.dir).Open(rest)
+}
+
+// scriptImportsLib reports whether src imports a shared script library.
+func scriptImportsLib(src []byte) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+	if err != nil {
+		return false
+	}
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err == nil && strings.HasPrefix(p, scriptLibDirName+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptLibModTime returns the newest modification time of any .go file
+// under the lib folders of the script directories.
+func scriptLibModTime() time.Time {
+	latest := time.Time{}
+	for _, dir := range scriptSearchDirs() {
+		root := filepath.Join(dir, scriptLibDirName)
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".go") {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return latest
+}
+
+// reloadLibscripts restarts every running script that imports a library so
+// it picks up library edits.
+func reloadLibscripts() {
+	scriptMu.RLock()
+	paths := make(map[string]string, len(scriptPaths))
+	for o, p := range scriptPaths {
+		if !scriptDisabled[o] {
+			paths[o] = p
+		}
+	}
+	scriptMu.RUnlock()
+	for o, p := range paths {
+		src, err := os.ReadFile(p)
+		if err != nil || !scriptImportsLib(src) {
+			continue
+		}
+		disablescript(o, "reloaded")
+		enablescript(o)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// Test that scripts can import packages from the lib folder and that
+// libraries only see the allowed standard library.
+func TestScriptLibImport(t *testing.T) {
+	dir := t.TempDir()
+	libDir := filepath.Join(dir, scriptLibDirName, "mathx")
+	if err := os.MkdirAll(libDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lib := "//go:build script\n\npackage mathx\n\nimport \"strings\"\n\nfunc Shout(s string) string { return strings.ToUpper(s) + \"!\" }\n"
+	if err := os.WriteFile(filepath.Join(libDir, "mathx.go"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, scriptLibDirName, "bad")
+	if err := os.MkdirAll(bad, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bad, "bad.go"), []byte("package bad\n\nimport \"os\"\n\nvar Args = os.Args\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newInterp := func() *interp.Interpreter {
+		i := interp.New(interp.Options{
+			GoPath:               ".",
+			BuildTags:            []string{"script"},
+			SourcecodeFilesystem: scriptLibFS{dir: filepath.Join(dir, scriptLibDirName)},
+		})
+		i.Use(restrictedStdlib())
+		return i
+	}
+
+	src := []byte("package main\n\nimport \"lib/mathx\"\n\nfunc Hello() string { return mathx.Shout(\"hi\") }\n")
+	if !scriptImportsLib(src) {
+		t.Fatalf("import of lib/mathx not detected")
+	}
+	i := newInterp()
+	if _, err := i.Eval(string(src)); err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	v, err := i.Eval("Hello")
+	if err != nil {
+		t.Fatalf("eval Hello: %v", err)
+	}
+	if got := v.Interface().(func() string)(); got != "HI!" {
+		t.Fatalf("Hello() = %q", got)
+	}
+
+	if _, err := newInterp().Eval("package main\n\nimport \"lib/bad\"\n\nvar _ = bad.Args\n"); err == nil {
+		t.Fatalf("library importing os was allowed")
+	}
+	if scriptImportsLib([]byte("package main\n\nimport \"strings\"\n")) {
+		t.Fatalf("stdlib import reported as library")
+	}
+}
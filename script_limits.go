@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Per-script resource limits. Time is measured as wall time spent inside a
+// handler minus time that handler spent in time.Sleep or gt.SleepTicks, so
+// waiting on channels or locks still counts as busy.
+const (
+	// scriptHandlerBudget is the active time a single handler call may use
+	// before it counts as a strike.
+	scriptHandlerBudget = 100 * time.Millisecond
+	// scriptBudgetStrikes over-budget calls within scriptStrikeWindow
+	// disable the script.
+	scriptBudgetStrikes = 5
+	scriptStrikeWindow  = time.Minute
+	// scriptHandlerHardLimit disables a script whose handler stays busy this
+	// long. The goroutine cannot be stopped, but no new events are delivered.
+	scriptHandlerHardLimit = 3 * time.Second
+	// scriptMaxGoroutines caps concurrently running handlers per script;
+	// further events are dropped until some finish.
+	scriptMaxGoroutines = 32
+	// scriptStorageMaxBytes caps the encoded size of a script's storage.
+	scriptStorageMaxBytes = 256 << 10
+	// scriptOverlayMaxOps caps queued overlay draw calls per script.
+	scriptOverlayMaxOps = 1024
+	// scriptMaxWindows and scriptMaxWidgets cap the windows and widgets a
+	// script may create.
+	scriptMaxWindows = 8
+	scriptMaxWidgets = 512
+	// scriptUIMaxOps caps the window changes a script may have queued for
+	// the next frame. Further calls are dropped, and a frame with dropped
+	// calls counts as a strike.
+	scriptUIMaxOps = 256
+	// scriptMoveInterval is the minimum time between a script's WalkTo or
+	// ClickMobile calls; faster calls are ignored.
+	scriptMoveInterval = 100 * time.Millisecond
+)
+
+// scriptStats holds resource accounting for one script owner.
+type scriptStats struct {
+	busy       time.Duration // wall time, less sleeps
+	calls      int
+	running    int
+	dropped    int
+	strikes    []time.Time
+	overBudget bool
+	lastErr    string
+	lastErrAt  time.Time
+	inflight   map[*scriptCallInfo]struct{}
+}
+
+// scriptCallInfo tracks one in-flight handler call. Fields other than ev
+// and start are guarded by scriptStatsMu.
+type scriptCallInfo struct {
+	ev    string
+	start time.Time
+	slept time.Duration
+}
+
+// active returns the call's busy time as of now. Call with scriptStatsMu
+// held.
+func (c *scriptCallInfo) active(now time.Time) time.Duration {
+	d := now.Sub(c.start) - c.slept
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+var (
+	scriptStatsMu      sync.Mutex
+	scriptStatsByOwner = map[string]*scriptStats{}
+)
+
+// getscriptStats returns the stats for owner. Call with scriptStatsMu held.
+func getscriptStats(owner string) *scriptStats {
+	st := scriptStatsByOwner[owner]
+	if st == nil {
+		st = &scriptStats{inflight: map[*scriptCallInfo]struct{}{}}
+		scriptStatsByOwner[owner] = st
+	}
+	return st
+}
+
+// strike records an over-budget event at now. Call with scriptStatsMu held.
+func (st *scriptStats) strike(now time.Time) {
+	cutoff := now.Add(-scriptStrikeWindow)
+	n := 0
+	for _, t := range st.strikes {
+		if t.After(cutoff) {
+			st.strikes[n] = t
+			n++
+		}
+	}
+	st.strikes = append(st.strikes[:n], now)
+	if len(st.strikes) >= scriptBudgetStrikes {
+		// The watchdog disables the script on its next pass.
+		st.overBudget = true
+	}
+}
+
+// scriptRecordDrop counts a call of owner's dropped for going over a limit
+// and stores msg as its last error. strike also counts it against the
+// handler budget.
+func scriptRecordDrop(owner, msg string, strike bool) {
+	now := time.Now()
+	scriptStatsMu.Lock()
+	st := getscriptStats(owner)
+	st.dropped++
+	st.lastErr = msg
+	st.lastErrAt = now
+	if strike {
+		st.strike(now)
+	}
+	scriptStatsMu.Unlock()
+}
+
+// scriptRecordError stores msg as the last error for owner.
+func scriptRecordError(owner, msg string) {
+	scriptStatsMu.Lock()
+	st := getscriptStats(owner)
+	st.lastErr = msg
+	st.lastErrAt = time.Now()
+	scriptStatsMu.Unlock()
+}
+
+// scriptSleep blocks like time.Sleep and credits the time as idle to one of
+// owner's handler calls.
+func scriptSleep(owner string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	start := time.Now()
+	time.Sleep(d)
+	scriptAddSleep(owner, start)
+}
+
+// scriptAddSleep credits the time since start as idle to owner's handler
+// call that slept. Only a call in flight for the whole sleep can have made
+// it, which usually leaves one candidate; if several remain the sleep is
+// split between them. Sleeps in goroutines a script starts itself are
+// credited the same way, though their running time is not measured.
+func scriptAddSleep(owner string, start time.Time) {
+	d := time.Since(start)
+	scriptStatsMu.Lock()
+	defer scriptStatsMu.Unlock()
+	var calls []*scriptCallInfo
+	for call := range getscriptStats(owner).inflight {
+		if !call.start.After(start) {
+			calls = append(calls, call)
+		}
+	}
+	for _, call := range calls {
+		call.slept += d / time.Duration(len(calls))
+	}
+}
+
+// scriptCall runs fn for owner, accounting its active time, enforcing the
+// handler budget and recovering panics. A handler that runs another
+// synchronously, e.g. an input handler, is billed for that time as well.
+func scriptCall(owner, ev string, fn func()) {
+	if fn == nil {
+		return
+	}
+	call := &scriptCallInfo{ev: ev, start: time.Now()}
+	scriptStatsMu.Lock()
+	st := getscriptStats(owner)
+	st.inflight[call] = struct{}{}
+	scriptStatsMu.Unlock()
+
+	defer func() {
+		r := recover()
+		scriptStatsMu.Lock()
+		active := call.active(time.Now())
+		delete(st.inflight, call)
+		st.busy += active
+		st.calls++
+		if active > scriptHandlerBudget && ev != "Init" {
+			st.strike(time.Now())
+		}
+		scriptStatsMu.Unlock()
+
+		if r != nil {
+			log.Printf("[script] %s %s panic: %v", owner, ev, r)
+			scriptRecordError(owner, fmt.Sprintf("%s panic: %v", ev, r))
+		} else if active > scriptHandlerBudget && ev != "Init" {
+			scriptRecordError(owner, fmt.Sprintf("%s took %v", ev, active.Round(time.Millisecond)))
+		}
+	}()
+	fn()
+}
+
+// scriptGo runs fn on a new goroutine via scriptCall unless owner already
+// has scriptMaxGoroutines handlers running, in which case it is dropped.
+func scriptGo(owner, ev string, fn func()) {
+	scriptStatsMu.Lock()
+	st := getscriptStats(owner)
+	if st.running >= scriptMaxGoroutines {
+		st.dropped++
+		st.lastErr = fmt.Sprintf("%s dropped: %d handlers running", ev, st.running)
+		st.lastErrAt = time.Now()
+		scriptStatsMu.Unlock()
+		return
+	}
+	st.running++
+	scriptStatsMu.Unlock()
+	go func() {
+		defer func() {
+			scriptStatsMu.Lock()
+			st.running--
+			scriptStatsMu.Unlock()
+		}()
+		scriptCall(owner, ev, fn)
+	}()
+}
+
+// scriptLimitExceeded disables owner and reports why.
+func scriptLimitExceeded(owner, reason string) {
+	if scriptIsDisabled(owner) {
+		return
+	}
+	scriptRecordError(owner, reason)
+	consoleMessage("[script] " + owner + " " + reason)
+	disablescript(owner, reason)
+}
+
+// scriptCheckBudgets disables scripts that ran out of strikes or have a
+// handler that has been busy for longer than scriptHandlerHardLimit. Init is
+// exempt since it may host long-lived loops.
+func scriptCheckBudgets() {
+	now := time.Now()
+	var over, struck []string
+	scriptStatsMu.Lock()
+	for owner, st := range scriptStatsByOwner {
+		if st.overBudget {
+			st.overBudget = false
+			st.strikes = nil
+			struck = append(struck, owner)
+			continue
+		}
+		for call := range st.inflight {
+			if call.ev == "Init" {
+				continue
+			}
+			if call.active(now) > scriptHandlerHardLimit {
+				over = append(over, owner)
+				break
+			}
+		}
+	}
+	scriptStatsMu.Unlock()
+	for _, owner := range struck {
+		scriptLimitExceeded(owner, "exceeded handler time budget")
+	}
+	for _, owner := range over {
+		scriptLimitExceeded(owner, "handler ran too long")
+	}
+}
+
+// scriptStatsRow is a read-only copy of scriptStats for display.
+type scriptStatsRow struct {
+	owner     string
+	busy      time.Duration
+	calls     int
+	running   int
+	dropped   int
+	lastErr   string
+	lastErrAt time.Time
+}
+
+// scriptStatsSnapshot returns stats for every script, busiest first.
+func scriptStatsSnapshot() []scriptStatsRow {
+	scriptStatsMu.Lock()
+	rows := make([]scriptStatsRow, 0, len(scriptStatsByOwner))
+	for owner, st := range scriptStatsByOwner {
+		rows = append(rows, scriptStatsRow{
+			owner:     owner,
+			busy:      st.busy,
+			calls:     st.calls,
+			running:   st.running,
+			dropped:   st.dropped,
+			lastErr:   st.lastErr,
+			lastErrAt: st.lastErrAt,
+		})
+	}
+	scriptStatsMu.Unlock()
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].busy != rows[j].busy {
+			return rows[i].busy > rows[j].busy
+		}
+		return rows[i].owner < rows[j].owner
+	})
+	return rows
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func resetscriptStats() {
+	scriptStatsMu.Lock()
+	scriptStatsByOwner = map[string]*scriptStats{}
+	scriptStatsMu.Unlock()
+}
+
+// Test that a panicking handler is recovered and counted, and that handlers
+// beyond the goroutine cap are dropped.
+func TestScriptCallAndGoLimits(t *testing.T) {
+	resetscriptStats()
+	defer resetscriptStats()
+
+	scriptCall("plug", "After", func() { panic("boom") })
+	rows := scriptStatsSnapshot()
+	if len(rows) != 1 || rows[0].calls != 1 {
+		t.Fatalf("stats = %+v", rows)
+	}
+	if !strings.Contains(rows[0].lastErr, "boom") {
+		t.Fatalf("lastErr = %q", rows[0].lastErr)
+	}
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(scriptMaxGoroutines)
+	for i := 0; i < scriptMaxGoroutines+3; i++ {
+		scriptGo("plug", "FrameHandler", func() {
+			defer wg.Done()
+			<-release
+		})
+	}
+	rows = scriptStatsSnapshot()
+	if rows[0].running != scriptMaxGoroutines || rows[0].dropped != 3 {
+		t.Fatalf("running %d dropped %d", rows[0].running, rows[0].dropped)
+	}
+	close(release)
+	wg.Wait()
+}
+
+// Test that one handler's sleep is not credited to another handler of the
+// same script that is busy at the same time.
+func TestScriptSleepIsPerCall(t *testing.T) {
+	resetscriptStats()
+	defer resetscriptStats()
+
+	sleeping := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scriptCall("plug", "Every", func() {
+			close(sleeping)
+			scriptSleep("plug", 300*time.Millisecond)
+		})
+	}()
+	<-sleeping
+	scriptCall("plug", "After", func() {
+		for start := time.Now(); time.Since(start) < 2*scriptHandlerBudget; {
+		}
+	})
+	<-done
+
+	rows := scriptStatsSnapshot()
+	if len(rows) != 1 || !strings.Contains(rows[0].lastErr, "After took") {
+		t.Fatalf("busy handler not charged: %+v", rows)
+	}
+	if rows[0].busy < 2*scriptHandlerBudget {
+		t.Fatalf("busy = %v", rows[0].busy)
+	}
+}
+
+// Test that storage writes past the size cap are rejected.
+func TestScriptStorageLimit(t *testing.T) {
+	origDir := dataDirPath
+	dataDirPath = t.TempDir()
+	t.Cleanup(func() { dataDirPath = origDir })
+	resetscriptStats()
+	defer resetscriptStats()
+
+	owner := "plug_big"
+	scriptMu.Lock()
+	scriptDisplayNames[owner] = "Big"
+	scriptAuthors[owner] = "Auth"
+	scriptMu.Unlock()
+	scriptStoreMu.Lock()
+	delete(scriptStores, owner)
+	scriptStoreMu.Unlock()
+
+	half := strings.Repeat("x", scriptStorageMaxBytes/2)
+	scriptStorageSet(owner, "a", half)
+	scriptStorageSet(owner, "b", half)
+	if scriptStorageGet(owner, "b") != nil {
+		t.Fatalf("write past the limit was stored")
+	}
+	if rows := scriptStatsSnapshot(); len(rows) != 1 || rows[0].lastErr == "" {
+		t.Fatalf("limit not reported: %+v", rows)
+	}
+
+	// Replacing an entry only counts the new size.
+	scriptStorageSet(owner, "a", "small")
+	scriptStorageSet(owner, "b", half)
+	if scriptStorageGet(owner, "b") != half {
+		t.Fatalf("write within the limit was rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scripts move the character through the same input path as keyboard
+// walking: Update asks scriptMoveInput for a target each tick and queues it
+// with queueInput. Any walking by the user cancels script movement.
+//
+// Field positions are relative to the view, which follows the player. A
+// walk remembers how far the view had scrolled when it started and moves
+// its target along as the view scrolls, so the target stays on the same
+// spot of the map.
+
+// scriptWalkArrive is how close, in pixels, a walk gets to its target
+// before it stops.
+const scriptWalkArrive = 4
+
+type scriptMoveState struct {
+	owner string
+	walk  bool  // hold the mouse down at (x, y)
+	click int   // remaining click phases: 2 = press, 1 = release
+	x, y  int16 // field position, same coordinates as Mobile.H/V
+	// viewH, viewV are scriptViewH/V when the walk started.
+	viewH, viewV int
+}
+
+var (
+	scriptMoveMu   sync.Mutex
+	scriptMove     scriptMoveState
+	scriptMoveLast = map[string]time.Time{}
+
+	// scriptViewH and scriptViewV add up how far the pictures on screen
+	// have shifted since the client started.
+	scriptViewH, scriptViewV int
+)
+
+// scriptMoveAllowed applies the per-script rate limit for movement calls.
+func scriptMoveAllowed(owner string) bool {
+	if scriptIsDisabled(owner) {
+		return false
+	}
+	now := time.Now()
+	scriptMoveMu.Lock()
+	last := scriptMoveLast[owner]
+	ok := now.Sub(last) >= scriptMoveInterval
+	if ok {
+		scriptMoveLast[owner] = now
+	}
+	scriptMoveMu.Unlock()
+	if !ok {
+		scriptRecordError(owner, "movement rate limit reached")
+	}
+	return ok
+}
+
+// scriptWalkTo walks to field position (h, v) as it is now. The walk ends
+// within scriptWalkArrive pixels of it, or earlier on StopWalking, when the
+// user walks, when the view jumps, or when another script movement
+// replaces it.
+func scriptWalkTo(owner string, h, v int) {
+	if !scriptMoveAllowed(owner) {
+		return
+	}
+	scriptMoveMu.Lock()
+	scriptMove = scriptMoveState{owner: owner, walk: true, x: clampInt16(h), y: clampInt16(v), viewH: scriptViewH, viewV: scriptViewV}
+	scriptMoveMu.Unlock()
+}
+
+// scriptViewShift records that the pictures on screen moved by (dx, dy)
+// this frame. ok is false when the shift could not be found, as after a
+// teleport; a walk in progress then stops, since its target is lost.
+func scriptViewShift(dx, dy int, ok bool) {
+	scriptMoveMu.Lock()
+	defer scriptMoveMu.Unlock()
+	if !ok {
+		if scriptMove.walk {
+			scriptMove = scriptMoveState{}
+		}
+		return
+	}
+	scriptViewH += dx
+	scriptViewV += dy
+}
+
+// scriptClickMobile clicks the nearest mobile whose name matches, ignoring
+// case. It reports whether one was in view.
+func scriptClickMobile(owner, name string) bool {
+	name = strings.TrimSpace(name)
+	if name == "" || !scriptMoveAllowed(owner) {
+		return false
+	}
+	for _, m := range scriptMobiles() {
+		if strings.EqualFold(m.Name, name) {
+			scriptMoveMu.Lock()
+			scriptMove = scriptMoveState{owner: owner, click: 2, x: m.H, y: m.V}
+			scriptMoveMu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// scriptStopWalking ends movement started by owner.
+func scriptStopWalking(owner string) {
+	scriptMoveMu.Lock()
+	if scriptMove.owner == owner {
+		scriptMove = scriptMoveState{}
+	}
+	scriptMoveMu.Unlock()
+}
+
+// scriptRemoveMovement stops owner's movement and forgets its rate limit.
+func scriptRemoveMovement(owner string) {
+	scriptStopWalking(owner)
+	scriptMoveMu.Lock()
+	delete(scriptMoveLast, owner)
+	scriptMoveMu.Unlock()
+}
+
+// scriptMoveInput returns the input a script wants this tick. userMoved
+// cancels script movement so the player always keeps control.
+func scriptMoveInput(userMoved bool) (inputState, bool) {
+	scriptMoveMu.Lock()
+	defer scriptMoveMu.Unlock()
+	mv := scriptMove
+	if mv.owner == "" {
+		return inputState{}, false
+	}
+	if userMoved {
+		scriptMove = scriptMoveState{}
+		return inputState{}, false
+	}
+	switch {
+	case mv.click > 0:
+		scriptMove.click--
+		if scriptMove.click == 0 {
+			scriptMove = scriptMoveState{}
+		}
+		return inputState{mouseX: mv.x, mouseY: mv.y, mouseDown: mv.click == 2}, true
+	case mv.walk:
+		x := int(mv.x) + scriptViewH - mv.viewH
+		y := int(mv.y) + scriptViewV - mv.viewV
+		if x*x+y*y <= scriptWalkArrive*scriptWalkArrive {
+			scriptMove = scriptMoveState{}
+			return inputState{}, false
+		}
+		return inputState{mouseX: clampInt16(x), mouseY: clampInt16(y), mouseDown: true}, true
+	}
+	return inputState{}, false
+}
+
+func clampInt16(n int) int16 {
+	if n > 32767 {
+		return 32767
+	}
+	if n < -32768 {
+		return -32768
+	}
+	return int16(n)
+}
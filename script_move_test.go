@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that script movement is rate limited, yields to the user and ends
+// with the script.
+func TestScriptMovement(t *testing.T) {
+	scriptMu = sync.RWMutex{}
+	scriptDisabled = map[string]bool{}
+	scriptMoveLast = map[string]time.Time{}
+	scriptMove = scriptMoveState{}
+	defer func() { scriptMove = scriptMoveState{} }()
+
+	scriptWalkTo("plug", 10, -20)
+	s, ok := scriptMoveInput(false)
+	if !ok || s != (inputState{mouseX: 10, mouseY: -20, mouseDown: true}) {
+		t.Fatalf("walk input %+v %v", s, ok)
+	}
+	scriptWalkTo("plug", 50, 50)
+	if s, _ := scriptMoveInput(false); s.mouseX != 10 {
+		t.Fatalf("call inside the rate limit was not ignored")
+	}
+	// The target moves with the view and the walk ends on arrival.
+	scriptViewShift(-6, 18, true)
+	if s, ok := scriptMoveInput(false); !ok || s.mouseX != 4 || s.mouseY != -2 {
+		t.Fatalf("target did not follow the view: %+v %v", s, ok)
+	}
+	scriptViewShift(-2, 0, true)
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("walk did not stop at the target")
+	}
+
+	scriptMoveLast["plug"] = time.Time{}
+	scriptWalkTo("plug", 100, 0)
+	scriptViewShift(0, 0, false)
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("walk kept going after the view jumped")
+	}
+
+	scriptMoveLast["plug"] = time.Time{}
+	scriptWalkTo("plug", 10, -20)
+	if _, ok := scriptMoveInput(true); ok {
+		t.Fatalf("user movement did not win")
+	}
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("script movement resumed after the user moved")
+	}
+
+	// A click presses and then releases.
+	scriptMoveMu.Lock()
+	scriptMove = scriptMoveState{owner: "plug", click: 2, x: 3, y: 4}
+	scriptMoveMu.Unlock()
+	if s, _ := scriptMoveInput(false); !s.mouseDown {
+		t.Fatalf("click did not press")
+	}
+	if s, ok := scriptMoveInput(false); !ok || s.mouseDown || s.mouseX != 3 {
+		t.Fatalf("click did not release: %+v", s)
+	}
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("click repeated")
+	}
+
+	scriptMoveLast["plug"] = time.Time{}
+	scriptWalkTo("plug", 1, 1)
+	scriptRemoveMovement("plug")
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("movement kept after the script stopped")
+	}
+	scriptDisabled["plug"] = true
+	scriptWalkTo("plug", 1, 1)
+	if _, ok := scriptMoveInput(false); ok {
+		t.Fatalf("disabled script moved")
+	}
+}
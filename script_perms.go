@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Scripts declare what they need with a permissions block:
+//
+//	const scriptPermissions = "send,storage"
+//
+// Only the gt functions of granted permissions are exported to the script.
+// Scripts without a block are treated as legacy scripts with every
+// permission.
+
+type scriptPerm struct {
+	name    string
+	desc    string
+	symbols []string
+}
+
+// scriptPermList is every permission in display order.
+var scriptPermList = []scriptPerm{
+	{"send", "send commands and chat to the server and equip items", []string{
+		"Run", "Cmd", "RunCommand", "EnqueueCommand", "AddHotkey",
+		"Equip", "Unequip", "EquipPartial", "UnequipPartial", "EquipById", "UnequipById",
+	}},
+	{"move", "walk your character and click on others", []string{
+		"WalkTo", "ClickMobile", "StopWalking",
+	}},
+	{"input", "read and rewrite what you type", []string{
+		"Input", "InputText", "SetInput", "SetInputText", "RegisterInputHandler",
+		"AddShortcut", "AddShortcuts", "RegisterCommand", "AddHotkeyFn",
+	}},
+	{"overlay", "draw over the game view", []string{
+		"OverlayClear", "OverlayRect", "OverlayText", "OverlayImage",
+	}},
+	{"storage", "save data between sessions", []string{
+		"Save", "Load", "Delete", "StorageGet", "StorageSet", "StorageDelete",
+		"CharSave", "CharLoad", "CharDelete", "MoveToChar",
+	}},
+	{"window", "open its own windows", []string{
+		"NewWindow", "ShowWindow", "HideWindow", "ToggleWindow", "ClearWindow",
+		"AddText", "AddButton", "AddCheckbox", "AddSlider", "AddList",
+		"SetText", "SetChecked", "SetValue", "SetList",
+	}},
+}
+
+// scriptPermAll is the consent value recorded for scripts without a
+// permissions block.
+const scriptPermAll = "all"
+
+var (
+	scriptPermsRE = regexp.MustCompile(`(?m)^\s*(?:var|const)\s+scriptPermissions\s*=\s*"([^"]*)"`)
+	scriptGTUseRE = regexp.MustCompile(`\bgt\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+	// scriptPerms holds the declared permissions per owner. Owners without
+	// an entry did not declare any and may use the whole API. Guarded by
+	// scriptMu.
+	scriptPerms = map[string][]string{}
+)
+
+// parsescriptPerms returns the sorted permissions declared in src, whether
+// a block was present and any names that are not permissions.
+func parsescriptPerms(src []byte) (perms []string, declared bool, unknown []string) {
+	m := scriptPermsRE.FindSubmatch(src)
+	if len(m) < 2 {
+		return nil, false, nil
+	}
+	perms = []string{}
+	seen := map[string]bool{}
+	for _, p := range strings.Split(string(m[1]), ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if scriptPermByName(p) == nil {
+			unknown = append(unknown, p)
+			continue
+		}
+		perms = append(perms, p)
+	}
+	sort.Strings(perms)
+	return perms, true, unknown
+}
+
+func scriptPermByName(name string) *scriptPerm {
+	for i := range scriptPermList {
+		if scriptPermList[i].name == name {
+			return &scriptPermList[i]
+		}
+	}
+	return nil
+}
+
+// scriptPermForSymbol returns the permission guarding the gt symbol, or ""
+// if it is always available.
+func scriptPermForSymbol(sym string) string {
+	for _, p := range scriptPermList {
+		for _, s := range p.symbols {
+			if s == sym {
+				return p.name
+			}
+		}
+	}
+	return ""
+}
+
+// scriptMissingPerms lists the permissions src uses without declaring them.
+func scriptMissingPerms(src []byte, perms []string) []string {
+	granted := map[string]bool{}
+	for _, p := range perms {
+		granted[p] = true
+	}
+	var missing []string
+	for _, m := range scriptGTUseRE.FindAllSubmatch(src, -1) {
+		p := scriptPermForSymbol(string(m[1]))
+		if p != "" && !granted[p] {
+			granted[p] = true
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// filterscriptExports removes the symbols of permissions owner was not
+// granted from m.
+func filterscriptExports(owner string, m map[string]reflect.Value) {
+	scriptMu.RLock()
+	perms, declared := scriptPerms[owner]
+	scriptMu.RUnlock()
+	if !declared {
+		return
+	}
+	granted := map[string]bool{}
+	for _, p := range perms {
+		granted[p] = true
+	}
+	for _, p := range scriptPermList {
+		if granted[p.name] {
+			continue
+		}
+		for _, sym := range p.symbols {
+			delete(m, sym)
+		}
+	}
+}
+
+// scriptPermString returns the consent value for owner's permissions.
+func scriptPermString(owner string) string {
+	scriptMu.RLock()
+	perms, declared := scriptPerms[owner]
+	scriptMu.RUnlock()
+	if !declared {
+		return scriptPermAll
+	}
+	return strings.Join(perms, ",")
+}
+
+// scriptConsented reports whether the user accepted owner's current
+// permissions. Scripts that need nothing never ask.
+func scriptConsented(owner string) bool {
+	perm := scriptPermString(owner)
+	return perm == "" || gs.ScriptConsent[owner] == perm
+}
+
+// scriptPermSummary describes owner's permissions for the consent dialog
+// and the script details.
+func scriptPermSummary(owner string) string {
+	scriptMu.RLock()
+	perms, declared := scriptPerms[owner]
+	scriptMu.RUnlock()
+	if !declared {
+		return "This script does not declare permissions, so it can use the whole script API, including sending commands and rewriting your chat input."
+	}
+	if len(perms) == 0 {
+		return "This script needs no permissions."
+	}
+	lines := []string{"This script can:"}
+	for _, p := range perms {
+		if sp := scriptPermByName(p); sp != nil {
+			lines = append(lines, fmt.Sprintf("- %s (%s)", sp.desc, sp.name))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scriptConsentAsked tracks owners with an open consent dialog so repeated
+// enable attempts do not stack prompts. Only touched on the game thread.
+var scriptConsentAsked = map[string]bool{}
+
+// requestscriptConsent asks the user to accept owner's permissions before
+// it is enabled. Accepting records consent and enables the script;
+// declining clears its enablement.
+func requestscriptConsent(owner string) {
+	scriptUIDo("", "", func() {
+		if scriptConsentAsked[owner] || scriptConsented(owner) {
+			return
+		}
+		scriptConsentAsked[owner] = true
+		scriptMu.RLock()
+		name := scriptDisplayNames[owner]
+		author := scriptAuthors[owner]
+		scriptMu.RUnlock()
+		perm := scriptPermString(owner)
+		showPopup(
+			"Enable Script",
+			fmt.Sprintf("Enable %s by %s?\n\n%s", name, author, scriptPermSummary(owner)),
+			[]popupButton{
+				{Text: "Cancel", Action: func() {
+					delete(scriptConsentAsked, owner)
+					clearscriptScope(owner)
+				}},
+				{Text: "Enable", Action: func() {
+					delete(scriptConsentAsked, owner)
+					if gs.ScriptConsent == nil {
+						gs.ScriptConsent = map[string]string{}
+					}
+					gs.ScriptConsent[owner] = perm
+					settingsDirty = true
+					applyEnabledScripts()
+				}},
+			},
+		)
+	})
+}
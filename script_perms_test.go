@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Test that declared permissions are parsed and that exportsForscript only
+// exposes the functions of granted permissions.
+func TestScriptPermissions(t *testing.T) {
+	src := []byte("package main\n\nconst scriptPermissions = \"Storage, send,bogus\"\n\nfunc Init() { gt.Run(\"/yell\"); gt.SetInputText(\"x\") }\n")
+	perms, declared, unknown := parsescriptPerms(src)
+	if !declared || !reflect.DeepEqual(perms, []string{"send", "storage"}) {
+		t.Fatalf("perms = %q declared=%v", perms, declared)
+	}
+	if !reflect.DeepEqual(unknown, []string{"bogus"}) {
+		t.Fatalf("unknown = %q", unknown)
+	}
+	if missing := scriptMissingPerms(src, perms); !reflect.DeepEqual(missing, []string{"input"}) {
+		t.Fatalf("missing = %q", missing)
+	}
+	if _, declared, _ := parsescriptPerms([]byte("package main\n")); declared {
+		t.Fatalf("permissions found in script without a block")
+	}
+
+	scriptMu = sync.RWMutex{}
+	scriptPerms = map[string][]string{"dice": {"send"}, "none": {}}
+	defer func() { scriptPerms = map[string][]string{} }()
+
+	has := func(owner, sym string) bool {
+		_, ok := exportsForscript(owner)["gt/gt"][sym]
+		return ok
+	}
+	if !has("dice", "Run") || has("dice", "SetInputText") || has("dice", "Save") {
+		t.Fatalf("dice exports do not match its permissions")
+	}
+	if has("none", "Cmd") || has("none", "OverlayRect") || !has("none", "Print") {
+		t.Fatalf("script without permissions kept restricted functions")
+	}
+	if !has("legacy", "SetInputText") || !has("legacy", "Run") {
+		t.Fatalf("script without a block lost functions")
+	}
+
+	prev := gs.ScriptConsent
+	defer func() { gs.ScriptConsent = prev }()
+	gs.ScriptConsent = map[string]string{"dice": "send"}
+	if !scriptConsented("dice") || !scriptConsented("none") || scriptConsented("legacy") {
+		t.Fatalf("consent check failed")
+	}
+	scriptPerms["dice"] = []string{"send", "storage"}
+	if scriptConsented("dice") {
+		t.Fatalf("consent kept after permissions changed")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -16,9 +17,21 @@ type scriptStore struct {
 	path  string
 	data  map[string]any
 	dirty bool
+	// sizes holds the encoded size of each entry; size is their sum.
+	sizes map[string]int
+	size  int
 	mu    sync.Mutex
 }
 
+// scriptEntrySize returns the approximate encoded size of one entry.
+func scriptEntrySize(key string, value any) int {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return len(key)
+	}
+	return len(key) + len(b)
+}
+
 var (
 	scriptStores  = map[string]*scriptStore{}
 	scriptStoreMu sync.Mutex
@@ -34,56 +47,241 @@ func scriptStoragePath(owner string) string {
 	return filepath.Join(dataDirPath, "scripts", "storage", file)
 }
 
+// scriptCharStorageDir holds one folder of stores per character, named by
+// sanitizeName.
+func scriptCharStorageDir() string {
+	return filepath.Join(dataDirPath, "scripts", "storage", "chars")
+}
+
+func scriptCharStoragePath(owner, char string) string {
+	return filepath.Join(scriptCharStorageDir(), char, filepath.Base(scriptStoragePath(owner)))
+}
+
+// getscriptStore returns owner's shared store.
 func getscriptStore(owner string) *scriptStore {
+	return openscriptStore(owner, scriptStoragePath(owner))
+}
+
+// getscriptCharStore returns owner's store for char, a sanitized character
+// name.
+func getscriptCharStore(owner, char string) *scriptStore {
+	return openscriptStore(owner+"/"+char, scriptCharStoragePath(owner, char))
+}
+
+func openscriptStore(id, path string) *scriptStore {
 	scriptStoreMu.Lock()
-	ps, ok := scriptStores[owner]
+	ps, ok := scriptStores[id]
 	if ok {
 		scriptStoreMu.Unlock()
 		return ps
 	}
-	path := scriptStoragePath(owner)
 	data := map[string]any{}
 	if b, err := os.ReadFile(path); err == nil {
 		if err := json.Unmarshal(b, &data); err != nil {
 			log.Printf("load script storage %s: %v", path, err)
 		}
 	}
-	ps = &scriptStore{path: path, data: data}
-	scriptStores[owner] = ps
+	ps = &scriptStore{path: path, data: data, sizes: make(map[string]int, len(data))}
+	for k, v := range data {
+		n := scriptEntrySize(k, v)
+		ps.sizes[k] = n
+		ps.size += n
+	}
+	scriptStores[id] = ps
 	scriptStoreMu.Unlock()
 	return ps
 }
 
-func scriptStorageGet(owner, key string) any {
-	ps := getscriptStore(owner)
+func (ps *scriptStore) get(key string) any {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 	return ps.data[key]
 }
 
-func scriptStorageSet(owner, key string, value any) {
-	ps := getscriptStore(owner)
+// set stores value under key unless that would take the store past
+// scriptStorageMaxBytes, in which case the error is recorded for owner.
+func (ps *scriptStore) set(owner, key string, value any) {
 	ps.mu.Lock()
 	if old, ok := ps.data[key]; !ok || !reflect.DeepEqual(old, value) {
+		n := scriptEntrySize(key, value)
+		if total := ps.size - ps.sizes[key] + n; total > scriptStorageMaxBytes {
+			ps.mu.Unlock()
+			scriptRecordError(owner, fmt.Sprintf("storage limit of %d bytes reached", scriptStorageMaxBytes))
+			return
+		}
 		if ps.data == nil {
 			ps.data = make(map[string]any)
 		}
+		if ps.sizes == nil {
+			ps.sizes = make(map[string]int)
+		}
 		ps.data[key] = value
+		ps.size += n - ps.sizes[key]
+		ps.sizes[key] = n
 		ps.dirty = true
 	}
 	ps.mu.Unlock()
 }
 
-func scriptStorageDelete(owner, key string) {
-	ps := getscriptStore(owner)
+func (ps *scriptStore) delete(key string) {
 	ps.mu.Lock()
 	if _, ok := ps.data[key]; ok {
 		delete(ps.data, key)
+		ps.size -= ps.sizes[key]
+		delete(ps.sizes, key)
 		ps.dirty = true
 	}
 	ps.mu.Unlock()
 }
 
+// clear removes every entry.
+func (ps *scriptStore) clear() {
+	ps.mu.Lock()
+	if len(ps.data) > 0 {
+		ps.data = map[string]any{}
+		ps.sizes = map[string]int{}
+		ps.size = 0
+		ps.dirty = true
+	}
+	ps.mu.Unlock()
+}
+
+func scriptStorageGet(owner, key string) any {
+	return getscriptStore(owner).get(key)
+}
+
+func scriptStorageSet(owner, key string, value any) {
+	getscriptStore(owner).set(owner, key, value)
+}
+
+func scriptStorageDelete(owner, key string) {
+	getscriptStore(owner).delete(key)
+}
+
+// scriptChar returns the sanitized name of the character whose storage
+// scripts use, or "" before any character has logged in.
+func scriptChar() string {
+	return sanitizeName(effectiveCharacterName())
+}
+
+func scriptCharStorageGet(owner, key string) any {
+	char := scriptChar()
+	if char == "" {
+		return nil
+	}
+	return getscriptCharStore(owner, char).get(key)
+}
+
+func scriptCharStorageSet(owner, key string, value any) {
+	char := scriptChar()
+	if char == "" {
+		scriptRecordError(owner, "no character for character storage")
+		return
+	}
+	getscriptCharStore(owner, char).set(owner, key, value)
+}
+
+func scriptCharStorageDelete(owner, key string) {
+	if char := scriptChar(); char != "" {
+		getscriptCharStore(owner, char).delete(key)
+	}
+}
+
+// scriptMoveToChar moves key from owner's shared store to the current
+// character's store. It does nothing and returns false when the character
+// already has key or the shared store does not. Scripts call it to migrate
+// data saved before character storage existed.
+func scriptMoveToChar(owner, key string) bool {
+	char := scriptChar()
+	if char == "" {
+		return false
+	}
+	cs := getscriptCharStore(owner, char)
+	if cs.get(key) != nil {
+		return false
+	}
+	shared := getscriptStore(owner)
+	v := shared.get(key)
+	if v == nil {
+		return false
+	}
+	cs.set(owner, key, v)
+	if cs.get(key) == nil {
+		return false
+	}
+	shared.delete(key)
+	return true
+}
+
+type characterHandler struct {
+	owner string
+	fn    func(string)
+}
+
+var (
+	characterHandlersMu    sync.Mutex
+	scriptCharHandlers     []characterHandler
+	lastScriptCharNotified string
+)
+
+func scriptRegisterCharacterHandler(owner string, fn func(string)) {
+	if scriptIsDisabled(owner) || fn == nil {
+		return
+	}
+	characterHandlersMu.Lock()
+	scriptCharHandlers = append(scriptCharHandlers, characterHandler{owner: owner, fn: fn})
+	characterHandlersMu.Unlock()
+}
+
+// scriptRemoveCharacterHandlers drops all character handlers for owner.
+func scriptRemoveCharacterHandlers(owner string) {
+	characterHandlersMu.Lock()
+	for i := len(scriptCharHandlers) - 1; i >= 0; i-- {
+		if scriptCharHandlers[i].owner == owner {
+			scriptCharHandlers = append(scriptCharHandlers[:i], scriptCharHandlers[i+1:]...)
+		}
+	}
+	characterHandlersMu.Unlock()
+}
+
+// runCharacterHandlers tells scripts that the character whose storage they
+// use has changed, so they can reload what they keep in memory. Scripts
+// that stay enabled across a character switch are not re-initialized.
+// Logging the same character back in does not count as a change; the first
+// login does, as scripts may have started before it with LastCharacter.
+func runCharacterHandlers() {
+	char := scriptChar()
+	characterHandlersMu.Lock()
+	if char == "" || char == lastScriptCharNotified {
+		characterHandlersMu.Unlock()
+		return
+	}
+	lastScriptCharNotified = char
+	handlers := append([]characterHandler{}, scriptCharHandlers...)
+	characterHandlersMu.Unlock()
+	name := effectiveCharacterName()
+	for _, h := range handlers {
+		scriptLogEvent(h.owner, "CharacterHandler", name)
+		fn := h.fn
+		scriptGo(h.owner, "CharacterHandler", func() { fn(name) })
+	}
+}
+
+// scriptStorageChars lists the characters with a store folder.
+func scriptStorageChars() []string {
+	entries, err := os.ReadDir(scriptCharStorageDir())
+	if err != nil {
+		return nil
+	}
+	var chars []string
+	for _, e := range entries {
+		if e.IsDir() {
+			chars = append(chars, e.Name())
+		}
+	}
+	return chars
+}
+
 func savescriptStores() {
 	if isWASM {
 		// Skip persistence in WASM.
@@ -243,3 +243,63 @@ func TestDisablescriptRemovesHandlers(t *testing.T) {
 		t.Fatalf("player handlers not cleaned up: %+v", scriptPlayerHandlers)
 	}
 }
+
+// Test that frame handlers fire each frame and vitals handlers only on change.
+func TestScriptFrameAndVitalsHandlers(t *testing.T) {
+	scriptMu = sync.RWMutex{}
+	scriptDisabled = map[string]bool{}
+	scriptInvalid = map[string]bool{}
+	scriptEnabledFor = map[string]scriptScope{}
+	frameHandlersMu = sync.RWMutex{}
+	scriptFrameHandlers = nil
+	scriptVitalsHandlers = nil
+	lastVitals = [6]int{}
+
+	frames := make(chan FrameInfo, 4)
+	vitals := make(chan int, 4)
+	scriptRegisterFrameHandler("plug", func(fi FrameInfo) { frames <- fi })
+	scriptRegisterVitalsHandler("plug", func(hp, hpMax, sp, spMax, bal, balMax int) { vitals <- hp })
+
+	stateMu.Lock()
+	prevHP := state.hp
+	state.hp = 7
+	stateMu.Unlock()
+	defer func() {
+		stateMu.Lock()
+		state.hp = prevHP
+		stateMu.Unlock()
+	}()
+
+	runFrameHandlers(42)
+	runFrameHandlers(43)
+	seen := map[int32]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case fi := <-frames:
+			seen[fi.Ack] = true
+		case <-time.After(time.Second):
+			t.Fatalf("frame handler not called")
+		}
+	}
+	if !seen[42] || !seen[43] {
+		t.Fatalf("unexpected frames %v", seen)
+	}
+	select {
+	case hp := <-vitals:
+		if hp != 7 {
+			t.Fatalf("hp = %d, want 7", hp)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("vitals handler not called")
+	}
+	select {
+	case <-vitals:
+		t.Fatalf("vitals handler called without a change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	scriptRemoveFrameHandlers("plug")
+	if len(scriptFrameHandlers) != 0 || len(scriptVitalsHandlers) != 0 {
+		t.Fatalf("handlers not removed")
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptScenario describes a -testScript run: the steps are executed in
+// order against a single script loaded without a server connection.
+type scriptScenario struct {
+	Player  string               `json:"player"`
+	Timeout string               `json:"timeout"` // per expectation, default 1s
+	Steps   []scriptScenarioStep `json:"steps"`
+}
+
+// scriptScenarioStep is one action or expectation. Exactly one field
+// should be set.
+type scriptScenarioStep struct {
+	Chat      string               `json:"chat,omitempty"`
+	Console   string               `json:"console,omitempty"`
+	Server    *scriptScenarioMsg   `json:"server,omitempty"`
+	Inventory []scriptScenarioItem `json:"inventory,omitempty"`
+	Hotkey    string               `json:"hotkey,omitempty"`
+	Command   string               `json:"command,omitempty"`
+	Ticks     int                  `json:"ticks,omitempty"`
+	Wait      string               `json:"wait,omitempty"`
+
+	ExpectCommand      string `json:"expectCommand,omitempty"`
+	ExpectNotification string `json:"expectNotification,omitempty"`
+	ExpectNothing      bool   `json:"expectNothing,omitempty"`
+}
+
+// scriptScenarioMsg is a server text message built with the /testhooks
+// message names (share, fallen, think, ...).
+type scriptScenarioMsg struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Text string `json:"text"`
+}
+
+type scriptScenarioItem struct {
+	ID       uint16 `json:"id"`
+	Name     string `json:"name"`
+	Equipped bool   `json:"equipped,omitempty"`
+}
+
+// scriptRecorder captures what a script sends while under -testScript.
+type scriptRecorder struct {
+	mu       sync.Mutex
+	commands []string
+	notes    []string
+}
+
+// scriptTestRec is set while a -testScript run is active. Commands and
+// notifications are recorded instead of reaching the server or screen.
+var scriptTestRec *scriptRecorder
+
+func (r *scriptRecorder) command(cmd string) {
+	r.mu.Lock()
+	r.commands = append(r.commands, cmd)
+	r.mu.Unlock()
+}
+
+func (r *scriptRecorder) notify(msg string) {
+	r.mu.Lock()
+	r.notes = append(r.notes, msg)
+	r.mu.Unlock()
+}
+
+// drain moves commands that bypassed scriptRunCommand, such as equip
+// requests, from the outgoing queue into the recording.
+func (r *scriptRecorder) drain() {
+	r.mu.Lock()
+	if pendingCommand != "" {
+		r.commands = append(r.commands, pendingCommand)
+		pendingCommand = ""
+	}
+	r.commands = append(r.commands, commandQueue...)
+	commandQueue = nil
+	r.mu.Unlock()
+}
+
+// take removes the first entry equal to want from list, waiting up to
+// timeout for it to appear.
+func (r *scriptRecorder) take(list *[]string, want string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		r.drain()
+		r.mu.Lock()
+		for i, v := range *list {
+			if v == want {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				r.mu.Unlock()
+				return true
+			}
+		}
+		r.mu.Unlock()
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// pending returns everything recorded but not yet matched.
+func (r *scriptRecorder) pending() []string {
+	r.drain()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, c := range r.commands {
+		out = append(out, "command "+c)
+	}
+	for _, n := range r.notes {
+		out = append(out, "notification "+n)
+	}
+	return out
+}
+
+// scriptScenarioPath returns the default scenario for a script file:
+// foo.go uses foo.test.json.
+func scriptScenarioPath(script string) string {
+	return strings.TrimSuffix(script, filepath.Ext(script)) + ".test.json"
+}
+
+// inventoryPacket encodes items as a server inventory update that replaces
+// the current inventory.
+func inventoryPacket(items []scriptScenarioItem) []byte {
+	b := []byte{kInvCmdMultiple, byte(len(items) + 1), kInvCmdFull, 0}
+	for _, it := range items {
+		cmd := byte(kInvCmdAdd)
+		if it.Equipped {
+			cmd = kInvCmdAddEquip
+		}
+		b = append(b, cmd)
+		b = binary.BigEndian.AppendUint16(b, it.ID)
+		b = append(b, encodeMacRoman(it.Name)...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// waitscriptIdle waits until owner has no handlers running or timeout passes.
+func waitscriptIdle(owner string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		scriptStatsMu.Lock()
+		running := getscriptStats(owner).running
+		scriptStatsMu.Unlock()
+		if running == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runScriptTest loads the script at path and plays the scenario against it.
+// Progress is printed to stdout; a non-nil error means the run failed.
+func runScriptTest(path, scenarioPath string) error {
+	data, err := os.ReadFile(scenarioPath)
+	if err != nil {
+		return err
+	}
+	var sc scriptScenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return fmt.Errorf("parse %s: %w", scenarioPath, err)
+	}
+	timeout := time.Second
+	if sc.Timeout != "" {
+		if timeout, err = time.ParseDuration(sc.Timeout); err != nil {
+			return fmt.Errorf("timeout: %w", err)
+		}
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	var owner string
+	var info scriptInfo
+	for o, in := range scanscripts([]string{filepath.Dir(abs)}, nil) {
+		if in.path == abs {
+			owner, info = o, in
+		}
+	}
+	if owner == "" {
+		return fmt.Errorf("%s is not a script", path)
+	}
+	if info.invalid || info.apiVer != scriptAPICurrentVersion {
+		return fmt.Errorf("%s is invalid or targets another API version", path)
+	}
+
+	playerName = sc.Player
+	if playerName == "" {
+		playerName = "Tester"
+	}
+	rec := &scriptRecorder{}
+	scriptTestRec = rec
+	defer func() { scriptTestRec = nil }()
+
+	scriptMu.Lock()
+	scriptDisplayNames[owner] = info.name
+	scriptAuthors[owner] = info.author
+	scriptCategories[owner] = info.category
+	scriptPaths[owner] = info.path
+	if info.permsDecl {
+		scriptPerms[owner] = info.perms
+	}
+	scriptMu.Unlock()
+	loadscriptSource(owner, info.name, info.path, info.src, restrictedStdlib())
+	if scriptIsDisabled(owner) {
+		return fmt.Errorf("%s failed to load", path)
+	}
+	waitscriptIdle(owner, timeout)
+	defer disablescript(owner, "reloaded")
+
+	failed := 0
+	for n, st := range sc.Steps {
+		step := n + 1
+		switch {
+		case st.Chat != "":
+			chatMessage(st.Chat)
+		case st.Console != "":
+			consoleMessage(st.Console)
+		case st.Server != nil:
+			tag, ok := hookLookup[strings.ToLower(st.Server.Type)]
+			if !ok {
+				return fmt.Errorf("step %d: unknown server message type %q", step, st.Server.Type)
+			}
+			payload := encodeMacRoman(st.Server.Text)
+			if st.Server.Name != "" {
+				payload = append(append(pnTag(st.Server.Name), ' '), payload...)
+			}
+			msg := make([]byte, 16)
+			msg = append(msg, bepp(tag, payload)...)
+			processServerMessage(msg)
+		case st.Inventory != nil:
+			if _, ok := parseInventory(inventoryPacket(st.Inventory)); !ok {
+				return fmt.Errorf("step %d: bad inventory", step)
+			}
+		case st.Hotkey != "":
+			pressscriptHotkey(owner, st.Hotkey)
+		case st.Command != "":
+			if !runscriptCommand(st.Command) {
+				return fmt.Errorf("step %d: no script command for %q", step, st.Command)
+			}
+		case st.Ticks > 0:
+			for i := 0; i < st.Ticks; i++ {
+				scriptAdvanceTick()
+			}
+		case st.Wait != "":
+			d, err := time.ParseDuration(st.Wait)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", step, err)
+			}
+			time.Sleep(d)
+		case st.ExpectCommand != "":
+			if rec.take(&rec.commands, st.ExpectCommand, timeout) {
+				fmt.Printf("ok   %d: command %q\n", step, st.ExpectCommand)
+			} else {
+				fmt.Printf("FAIL %d: command %q not sent; pending %q\n", step, st.ExpectCommand, rec.pending())
+				failed++
+			}
+		case st.ExpectNotification != "":
+			if rec.take(&rec.notes, st.ExpectNotification, timeout) {
+				fmt.Printf("ok   %d: notification %q\n", step, st.ExpectNotification)
+			} else {
+				fmt.Printf("FAIL %d: notification %q not shown; pending %q\n", step, st.ExpectNotification, rec.pending())
+				failed++
+			}
+		case st.ExpectNothing:
+			waitscriptIdle(owner, timeout)
+			if p := rec.pending(); len(p) > 0 {
+				fmt.Printf("FAIL %d: unexpected %q\n", step, p)
+				failed++
+			} else {
+				fmt.Printf("ok   %d: nothing sent\n", step)
+			}
+		default:
+			return fmt.Errorf("step %d: empty step", step)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d expectations failed", failed, countExpectations(sc.Steps))
+	}
+	fmt.Printf("PASS %s\n", path)
+	return nil
+}
+
+func countExpectations(steps []scriptScenarioStep) int {
+	n := 0
+	for _, st := range steps {
+		if st.ExpectCommand != "" || st.ExpectNotification != "" || st.ExpectNothing {
+			n++
+		}
+	}
+	return n
+}
+
+// pressscriptHotkey fires owner's hotkey bound to combo as if pressed.
+func pressscriptHotkey(owner, combo string) {
+	for _, hk := range scriptHotkeys(owner) {
+		if hk.Disabled || !sameCombo(hk.Combo, combo) {
+			continue
+		}
+		if fn, ok := scriptGetHotkeyFn(owner, hk.Combo); ok && fn != nil {
+			parts := strings.Split(combo, "-")
+			ev := HotkeyEvent{Combo: combo, Parts: parts, Trigger: parts[len(parts)-1]}
+			scriptLogEvent(owner, "Hotkey", combo)
+			scriptGo(owner, "Hotkey", func() { fn(ev) })
+		}
+		for _, c := range hk.Commands {
+			if cmd := strings.TrimSpace(c.Command); cmd != "" && !runscriptCommand(cmd) {
+				scriptTestRec.command(cmd)
+			}
+		}
+	}
+}
+
+// runscriptCommand runs a typed slash command registered by a script and
+// reports whether one matched.
+func runscriptCommand(txt string) bool {
+	if !strings.HasPrefix(txt, "/") {
+		return false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(txt, "/"), " ", 2)
+	name := strings.ToLower(parts[0])
+	args := ""
+	if len(parts) > 1 {
+		args = parts[1]
+	}
+	scriptMu.RLock()
+	handler, ok := scriptCommands[name]
+	owner := scriptCommandOwners[name]
+	scriptMu.RUnlock()
+	if !ok || handler == nil || scriptIsDisabled(owner) {
+		return false
+	}
+	scriptLogEvent(owner, "Command", args)
+	scriptGo(owner, "Command", func() { handler(args) })
+	return true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that scenario inventories round-trip through the inventory parser.
+func TestInventoryPacket(t *testing.T) {
+	resetInventory()
+	defer resetInventory()
+	items := []scriptScenarioItem{
+		{ID: 100, Name: "Axe", Equipped: true},
+		{ID: 200, Name: "Dice"},
+	}
+	if _, ok := parseInventory(inventoryPacket(items)); !ok {
+		t.Fatalf("parseInventory failed")
+	}
+	inv := getInventory()
+	if len(inv) != 2 {
+		t.Fatalf("got %d items: %+v", len(inv), inv)
+	}
+	if inv[0].Name != "Axe" || !inv[0].Equipped || inv[1].Name != "Dice" || inv[1].Equipped {
+		t.Fatalf("unexpected inventory %+v", inv)
+	}
+}
+
+// Test that the recorder captures queued commands and matches them once.
+func TestScriptRecorder(t *testing.T) {
+	prevPending, prevQueue := pendingCommand, commandQueue
+	defer func() { pendingCommand, commandQueue = prevPending, prevQueue }()
+	pendingCommand, commandQueue = "", nil
+
+	r := &scriptRecorder{}
+	r.command("/yell hi")
+	pendingCommand = "/unequip 5"
+	if !r.take(&r.commands, "/unequip 5", 0) {
+		t.Fatalf("queued command not recorded")
+	}
+	if r.take(&r.commands, "/yell bye", 10*time.Millisecond) {
+		t.Fatalf("matched a command that was never sent")
+	}
+	if p := r.pending(); len(p) != 1 || p[0] != "command /yell hi" {
+		t.Fatalf("pending = %q", p)
+	}
+}
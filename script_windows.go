@@ -0,0 +1,433 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"gothoom/eui"
+)
+
+// Scripts build windows through integer handles. The eui work itself is
+// queued and applied on the game thread by runscriptUIQueue, so scripts can
+// call these from any handler. Repeated changes to the same value of a
+// widget replace each other in the queue, and each script may have at most
+// scriptUIMaxOps changes queued.
+
+type scriptWindow struct {
+	owner string
+	key   string // gs.ScriptWindows key
+	title string
+	win   *eui.WindowData
+	root  *eui.ItemData
+}
+
+type scriptWidget struct {
+	owner string
+	win   *scriptWindow
+	item  *eui.ItemData
+	list  bool
+}
+
+var (
+	scriptWindowsMu  sync.Mutex
+	scriptWindows    = map[int]*scriptWindow{}
+	scriptWidgets    = map[int]*scriptWidget{}
+	scriptWindowNext int
+	scriptUIQueue    []scriptUIOp
+	// scriptUIKeys maps the key of each mergeable op to its queue index.
+	scriptUIKeys = map[string]int{}
+	// scriptUIQueued counts each script's ops in scriptUIQueue.
+	scriptUIQueued = map[string]int{}
+	// scriptUIStruck holds scripts that dropped ops since the last run.
+	scriptUIStruck = map[string]bool{}
+)
+
+// scriptUIOp is a queued window change. Ops with the same non-empty key set
+// the same value, so a newer one replaces an older one.
+type scriptUIOp struct {
+	owner string
+	key   string
+	fn    func()
+}
+
+// scriptUIPush appends op to the queue. Call with scriptWindowsMu held.
+func scriptUIPush(op scriptUIOp) {
+	scriptUIQueue = append(scriptUIQueue, op)
+	if op.key != "" {
+		scriptUIKeys[op.key] = len(scriptUIQueue) - 1
+	}
+	if op.owner != "" {
+		scriptUIQueued[op.owner]++
+	}
+}
+
+// scriptUIDo queues fn for owner to run on the game thread, replacing a
+// queued op with the same key. It drops fn once owner has scriptUIMaxOps
+// ops queued; ops the client queues itself pass owner "" and are not
+// limited.
+func scriptUIDo(owner, key string, fn func()) {
+	scriptWindowsMu.Lock()
+	if i, ok := scriptUIKeys[key]; ok && key != "" {
+		scriptUIQueue[i].fn = fn
+		scriptWindowsMu.Unlock()
+		return
+	}
+	if scriptUIQueued[owner] >= scriptUIMaxOps {
+		strike := !scriptUIStruck[owner]
+		scriptUIStruck[owner] = true
+		scriptWindowsMu.Unlock()
+		scriptRecordDrop(owner, fmt.Sprintf("window limit of %d queued changes reached", scriptUIMaxOps), strike)
+		return
+	}
+	scriptUIPush(scriptUIOp{owner: owner, key: key, fn: fn})
+	scriptWindowsMu.Unlock()
+}
+
+// runscriptUIQueue applies pending script window changes. Called from Update.
+func runscriptUIQueue() {
+	scriptWindowsMu.Lock()
+	q := scriptUIQueue
+	scriptUIQueue = nil
+	clear(scriptUIKeys)
+	clear(scriptUIQueued)
+	clear(scriptUIStruck)
+	scriptWindowsMu.Unlock()
+	for _, op := range q {
+		op.fn()
+	}
+}
+
+func scriptWindowKey(owner, title string) string {
+	scriptMu.RLock()
+	name := scriptDisplayNames[owner]
+	scriptMu.RUnlock()
+	if name == "" {
+		name = owner
+	}
+	return name + "/" + title
+}
+
+// scriptNewWindow returns a window for owner with the given title, reusing
+// an existing one with the same title. It returns 0 when owner already has
+// scriptMaxWindows windows.
+func scriptNewWindow(owner, title string) int {
+	if scriptIsDisabled(owner) {
+		return 0
+	}
+	key := scriptWindowKey(owner, title)
+	scriptWindowsMu.Lock()
+	count := 0
+	for id, w := range scriptWindows {
+		if w.owner != owner {
+			continue
+		}
+		if w.title == title {
+			scriptWindowsMu.Unlock()
+			return id
+		}
+		count++
+	}
+	if count >= scriptMaxWindows {
+		scriptWindowsMu.Unlock()
+		scriptRecordError(owner, "window limit reached")
+		return 0
+	}
+	scriptWindowNext++
+	id := scriptWindowNext
+	sw := &scriptWindow{owner: owner, title: title, key: key}
+	scriptWindows[id] = sw
+	// Creating windows and widgets is bounded by their own limits, so it
+	// is not counted against scriptUIMaxOps.
+	scriptUIPush(scriptUIOp{fn: func() {
+		win := eui.NewWindow()
+		win.Title = title
+		win.Closable = true
+		win.Resizable = true
+		win.AutoSize = true
+		win.Movable = true
+		root := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true}
+		win.AddItem(root)
+		win.AddWindow(false)
+		if st, ok := gs.ScriptWindows[key]; ok {
+			sx, sy := eui.ScreenSize()
+			clampWindowState(&st, float64(sx), float64(sy))
+			applyWindowState(win, &st)
+		} else {
+			win.SetZone(eui.HZoneCenter, eui.VZoneMiddleTop)
+		}
+		scriptWindowsMu.Lock()
+		sw.win, sw.root = win, root
+		scriptWindowsMu.Unlock()
+	}})
+	scriptWindowsMu.Unlock()
+	return id
+}
+
+// scriptAddWidget registers item under a new handle and queues adding it to
+// window w. It returns 0 if w does not belong to owner or the widget limit
+// has been reached.
+func scriptAddWidget(owner string, w int, list bool, build func() *eui.ItemData) int {
+	scriptWindowsMu.Lock()
+	defer scriptWindowsMu.Unlock()
+	sw := scriptWindows[w]
+	if sw == nil || sw.owner != owner {
+		return 0
+	}
+	count := 0
+	for _, wd := range scriptWidgets {
+		if wd.owner == owner {
+			count++
+		}
+	}
+	if count >= scriptMaxWidgets {
+		scriptRecordError(owner, "widget limit reached")
+		return 0
+	}
+	scriptWindowNext++
+	id := scriptWindowNext
+	wd := &scriptWidget{owner: owner, win: sw, list: list}
+	scriptWidgets[id] = wd
+	scriptUIPush(scriptUIOp{fn: func() {
+		if sw.root == nil {
+			return
+		}
+		wd.item = build()
+		sw.root.AddItem(wd.item)
+		sw.win.Refresh()
+	}})
+	return id
+}
+
+// scriptWindowDo queues fn for owner's window w once it exists.
+func scriptWindowDo(owner string, w int, fn func(*scriptWindow)) {
+	scriptWindowsMu.Lock()
+	sw := scriptWindows[w]
+	scriptWindowsMu.Unlock()
+	if sw == nil || sw.owner != owner {
+		return
+	}
+	scriptUIDo(owner, "", func() {
+		if sw.win != nil {
+			fn(sw)
+		}
+	})
+}
+
+// scriptWidgetDo queues fn for owner's widget id once it exists. fn sets
+// the widget's value named by what; it replaces a queued fn for the same.
+func scriptWidgetDo(owner string, id int, what string, fn func(*scriptWidget)) {
+	scriptWindowsMu.Lock()
+	wd := scriptWidgets[id]
+	scriptWindowsMu.Unlock()
+	if wd == nil || wd.owner != owner {
+		return
+	}
+	scriptUIDo(owner, fmt.Sprintf("%d/%s", id, what), func() {
+		if wd.item != nil {
+			fn(wd)
+			wd.item.Dirty = true
+			wd.win.win.Refresh()
+		}
+	})
+}
+
+func scriptShowWindow(owner string, w int) {
+	scriptWindowDo(owner, w, func(sw *scriptWindow) { sw.win.MarkOpen() })
+}
+
+func scriptHideWindow(owner string, w int) {
+	scriptWindowDo(owner, w, func(sw *scriptWindow) {
+		if sw.win.IsOpen() {
+			sw.win.Close()
+		}
+	})
+}
+
+func scriptToggleWindow(owner string, w int) {
+	scriptWindowDo(owner, w, func(sw *scriptWindow) { sw.win.Toggle() })
+}
+
+// scriptClearWindow removes every widget from window w.
+func scriptClearWindow(owner string, w int) {
+	scriptWindowsMu.Lock()
+	for id, wd := range scriptWidgets {
+		if wd.owner == owner && wd.win == scriptWindows[w] {
+			delete(scriptWidgets, id)
+		}
+	}
+	scriptWindowsMu.Unlock()
+	scriptWindowDo(owner, w, func(sw *scriptWindow) {
+		sw.root.Contents = sw.root.Contents[:0]
+		sw.win.Refresh()
+	})
+}
+
+func scriptAddText(owner string, w int, txt string) int {
+	return scriptAddWidget(owner, w, false, func() *eui.ItemData {
+		t, _ := eui.NewText()
+		t.Text = txt
+		t.FontSize = 12
+		t.Size = eui.Point{X: 300, Y: 18}
+		return t
+	})
+}
+
+func scriptAddButton(owner string, w int, label string, fn func()) int {
+	return scriptAddWidget(owner, w, false, func() *eui.ItemData {
+		b, h := eui.NewButton()
+		b.Text = label
+		b.Size = eui.Point{X: 160, Y: 24}
+		h.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventClick && fn != nil && !scriptIsDisabled(owner) {
+				scriptLogEvent(owner, "Window", label)
+				scriptGo(owner, "Window", fn)
+			}
+		}
+		return b
+	})
+}
+
+func scriptAddCheckbox(owner string, w int, label string, checked bool, fn func(bool)) int {
+	return scriptAddWidget(owner, w, false, func() *eui.ItemData {
+		cb, h := eui.NewCheckbox()
+		cb.Text = label
+		cb.Checked = checked
+		cb.Size = eui.Point{X: 200, Y: 24}
+		h.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventCheckboxChanged && fn != nil && !scriptIsDisabled(owner) {
+				on := ev.Checked
+				scriptLogEvent(owner, "Window", label)
+				scriptGo(owner, "Window", func() { fn(on) })
+			}
+		}
+		return cb
+	})
+}
+
+func scriptAddSlider(owner string, w int, label string, min, max, value float64, fn func(float64)) int {
+	return scriptAddWidget(owner, w, false, func() *eui.ItemData {
+		s, h := eui.NewSlider()
+		s.Label = label
+		s.MinValue = float32(min)
+		s.MaxValue = float32(max)
+		s.Value = float32(value)
+		s.Size = eui.Point{X: 200, Y: 24}
+		h.Handle = func(ev eui.UIEvent) {
+			if ev.Type == eui.EventSliderChanged && fn != nil && !scriptIsDisabled(owner) {
+				v := float64(ev.Value)
+				scriptLogEvent(owner, "Window", label)
+				scriptGo(owner, "Window", func() { fn(v) })
+			}
+		}
+		return s
+	})
+}
+
+// scriptAddList adds a scrollable list of text lines.
+func scriptAddList(owner string, w int, lines []string) int {
+	lines = append([]string(nil), lines...)
+	return scriptAddWidget(owner, w, true, func() *eui.ItemData {
+		list := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true}
+		list.Size = eui.Point{X: 300, Y: 160}
+		setscriptListLines(list, lines)
+		return list
+	})
+}
+
+func setscriptListLines(list *eui.ItemData, lines []string) {
+	list.Contents = list.Contents[:0]
+	for _, ln := range lines {
+		t, _ := eui.NewText()
+		t.Text = ln
+		t.FontSize = 12
+		t.Size = eui.Point{X: 290, Y: 16}
+		list.AddItem(t)
+	}
+}
+
+// scriptSetText changes the text of a text, button or checkbox widget.
+func scriptSetText(owner string, id int, txt string) {
+	scriptWidgetDo(owner, id, "text", func(wd *scriptWidget) {
+		if !wd.list {
+			wd.item.Text = txt
+		}
+	})
+}
+
+func scriptSetChecked(owner string, id int, on bool) {
+	scriptWidgetDo(owner, id, "checked", func(wd *scriptWidget) { wd.item.Checked = on })
+}
+
+func scriptSetValue(owner string, id int, v float64) {
+	scriptWidgetDo(owner, id, "value", func(wd *scriptWidget) { wd.item.Value = float32(v) })
+}
+
+func scriptSetList(owner string, id int, lines []string) {
+	lines = append([]string(nil), lines...)
+	scriptWidgetDo(owner, id, "list", func(wd *scriptWidget) {
+		if wd.list {
+			setscriptListLines(wd.item, lines)
+		}
+	})
+}
+
+// syncscriptWindows records script window geometry in gs.ScriptWindows.
+func syncscriptWindows() bool {
+	changed := false
+	scriptWindowsMu.Lock()
+	for _, sw := range scriptWindows {
+		if sw.win == nil {
+			continue
+		}
+		if gs.ScriptWindows == nil {
+			gs.ScriptWindows = map[string]WindowState{}
+		}
+		st := gs.ScriptWindows[sw.key]
+		if syncWindow(sw.win, &st) {
+			gs.ScriptWindows[sw.key] = st
+			changed = true
+		}
+	}
+	scriptWindowsMu.Unlock()
+	return changed
+}
+
+// scriptRemoveWindows saves and removes every window owned by owner.
+func scriptRemoveWindows(owner string) {
+	scriptWindowsMu.Lock()
+	var wins []*scriptWindow
+	for id, sw := range scriptWindows {
+		if sw.owner == owner {
+			wins = append(wins, sw)
+			delete(scriptWindows, id)
+		}
+	}
+	for id, wd := range scriptWidgets {
+		if wd.owner == owner {
+			delete(scriptWidgets, id)
+		}
+	}
+	scriptWindowsMu.Unlock()
+	if len(wins) == 0 {
+		return
+	}
+	// Not counted against the owner's limit: this must run.
+	scriptWindowsMu.Lock()
+	scriptUIPush(scriptUIOp{fn: func() {
+		for _, sw := range wins {
+			if sw.win == nil {
+				continue
+			}
+			if gs.ScriptWindows == nil {
+				gs.ScriptWindows = map[string]WindowState{}
+			}
+			st := gs.ScriptWindows[sw.key]
+			if syncWindow(sw.win, &st) {
+				gs.ScriptWindows[sw.key] = st
+				settingsDirty = true
+			}
+			sw.win.RemoveWindow()
+		}
+	}})
+	scriptWindowsMu.Unlock()
+}
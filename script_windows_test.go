@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"gothoom/eui"
+)
+
+// Test that script windows are owned per script, reused by title and
+// removed with their widgets when the script stops.
+func TestScriptWindows(t *testing.T) {
+	scriptMu = sync.RWMutex{}
+	scriptDisabled = map[string]bool{}
+	scriptWindowsMu.Lock()
+	scriptWindows = map[int]*scriptWindow{}
+	scriptWidgets = map[int]*scriptWidget{}
+	scriptUIQueue = nil
+	clear(scriptUIKeys)
+	clear(scriptUIQueued)
+	clear(scriptUIStruck)
+	scriptWindowsMu.Unlock()
+
+	w := scriptNewWindow("plug", "Totals")
+	if w == 0 {
+		t.Fatalf("window not created")
+	}
+	if again := scriptNewWindow("plug", "Totals"); again != w {
+		t.Fatalf("same title gave handle %d, want %d", again, w)
+	}
+	if scriptAddButton("other", w, "Nope", nil) != 0 {
+		t.Fatalf("another script added to the window")
+	}
+	b := scriptAddButton("plug", w, "Go", func() {})
+	if b == 0 {
+		t.Fatalf("button not created")
+	}
+	scriptSetText("plug", b, "Stop")
+	runscriptUIQueue()
+
+	sw := scriptWindows[w]
+	if sw.win == nil || len(sw.root.Contents) != 1 {
+		t.Fatalf("window not built")
+	}
+	if got := scriptWidgets[b].item.Text; got != "Stop" {
+		t.Fatalf("button text %q, want Stop", got)
+	}
+
+	win := sw.win
+	scriptRemoveWindows("plug")
+	runscriptUIQueue()
+	if len(scriptWindows) != 0 || len(scriptWidgets) != 0 {
+		t.Fatalf("handles not removed")
+	}
+	for _, ew := range eui.Windows() {
+		if ew == win {
+			t.Fatalf("window still registered")
+		}
+	}
+}
+
+// Test that repeated widget updates replace each other in the queue and
+// that a script flooding the queue has calls dropped and gets a strike.
+func TestScriptUIQueueLimit(t *testing.T) {
+	scriptMu = sync.RWMutex{}
+	scriptDisabled = map[string]bool{}
+	scriptWindowsMu.Lock()
+	scriptWindows = map[int]*scriptWindow{}
+	scriptWidgets = map[int]*scriptWidget{}
+	scriptUIQueue = nil
+	clear(scriptUIKeys)
+	clear(scriptUIQueued)
+	clear(scriptUIStruck)
+	scriptWindowsMu.Unlock()
+	resetscriptStats()
+	defer resetscriptStats()
+	defer scriptRemoveWindows("plug")
+
+	w := scriptNewWindow("plug", "Flood")
+	txt := scriptAddText("plug", w, "")
+	runscriptUIQueue()
+	for i := 0; i < 1000; i++ {
+		scriptSetText("plug", txt, fmt.Sprint(i))
+	}
+	if n := len(scriptUIQueue); n != 1 {
+		t.Fatalf("%d ops queued for one widget, want 1", n)
+	}
+	runscriptUIQueue()
+	if got := scriptWidgets[txt].item.Text; got != "999" {
+		t.Fatalf("text %q, want 999", got)
+	}
+
+	for i := 0; i < scriptUIMaxOps+10; i++ {
+		scriptToggleWindow("plug", w)
+	}
+	if n := len(scriptUIQueue); n != scriptUIMaxOps {
+		t.Fatalf("%d ops queued, want %d", n, scriptUIMaxOps)
+	}
+	scriptStatsMu.Lock()
+	st := getscriptStats("plug")
+	dropped, strikes := st.dropped, len(st.strikes)
+	scriptStatsMu.Unlock()
+	if dropped != 10 || strikes != 1 {
+		t.Fatalf("dropped %d with %d strikes, want 10 with 1", dropped, strikes)
+	}
+	runscriptUIQueue()
+}
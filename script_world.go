@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Picture describes a picture drawn in the latest frame.
+type Picture struct {
+	PictID     uint16
+	H, V       int16
+	Plane      int
+	Moving     bool
+	Background bool
+}
+
+// Descriptor describes a mobile descriptor (player, monster or NPC).
+type Descriptor struct {
+	Index  uint8
+	Type   uint8
+	PictID uint16
+	Name   string
+	Colors []byte
+}
+
+// SelfInfo describes your own mobile and bars. Found is false when your
+// mobile is not in the current frame.
+type SelfInfo struct {
+	Mobile
+	Found      bool
+	HP, HPMax  int
+	SP, SPMax  int
+	Balance    int
+	BalanceMax int
+}
+
+// makeMobile builds the script view of a mobile. Range is the rounded pixel
+// distance from (selfH, selfV).
+func makeMobile(m frameMobile, d frameDescriptor, selfH, selfV int16) Mobile {
+	dh := float64(m.H - selfH)
+	dv := float64(m.V - selfV)
+	return Mobile{
+		Index:  m.Index,
+		Name:   d.Name,
+		H:      m.H,
+		V:      m.V,
+		PictID: d.PictID,
+		Colors: m.Colors,
+		Type:   d.Type,
+		State:  m.State,
+		Dead:   m.State == poseDead,
+		Range:  int(math.Round(math.Hypot(dh, dv))),
+	}
+}
+
+// selfPosLocked returns your mobile if it is in the current frame. The zero
+// value sits at the field origin. Call with stateMu held.
+func selfPosLocked() (frameMobile, bool) {
+	if m, ok := state.mobiles[playerIndex]; ok {
+		return m, true
+	}
+	return frameMobile{}, false
+}
+
+// scriptMobiles returns a copy of all mobiles in the latest frame sorted
+// nearest first, including fallen ones.
+func scriptMobiles() []Mobile {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	self, _ := selfPosLocked()
+	res := make([]Mobile, 0, len(state.liveMobs))
+	for _, m := range state.liveMobs {
+		d := state.descriptors[m.Index]
+		res = append(res, makeMobile(m, d, self.H, self.V))
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Range < res[j].Range })
+	return res
+}
+
+// scriptPictures returns a copy of the pictures drawn in the latest frame.
+func scriptPictures() []Picture {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	res := make([]Picture, 0, len(state.pictures))
+	for _, p := range state.pictures {
+		res = append(res, Picture{
+			PictID:     p.PictID,
+			H:          p.H,
+			V:          p.V,
+			Plane:      p.Plane,
+			Moving:     p.Moving,
+			Background: p.Background,
+		})
+	}
+	return res
+}
+
+// scriptDescriptors returns a copy of all known descriptors ordered by index.
+func scriptDescriptors() []Descriptor {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	res := make([]Descriptor, 0, len(state.descriptors))
+	for i := 0; i < 256; i++ {
+		d, ok := state.descriptors[uint8(i)]
+		if !ok {
+			continue
+		}
+		res = append(res, Descriptor{
+			Index:  d.Index,
+			Type:   d.Type,
+			PictID: d.PictID,
+			Name:   d.Name,
+			Colors: append([]byte(nil), d.Colors...),
+		})
+	}
+	return res
+}
+
+// scriptSelf returns your mobile along with health, spirit and balance.
+func scriptSelf() SelfInfo {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	info := SelfInfo{
+		HP:         state.hp,
+		HPMax:      state.hpMax,
+		SP:         state.sp,
+		SPMax:      state.spMax,
+		Balance:    state.balance,
+		BalanceMax: state.balanceMax,
+	}
+	if m, ok := selfPosLocked(); ok {
+		info.Mobile = makeMobile(m, state.descriptors[m.Index], m.H, m.V)
+		info.Found = true
+	}
+	return info
+}
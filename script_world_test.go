@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+// Test that world snapshots report range, fallen mobiles and vitals.
+func TestScriptWorldSnapshots(t *testing.T) {
+	stateMu.Lock()
+	prevState := state
+	prevIndex := playerIndex
+	state = drawState{
+		descriptors: map[uint8]frameDescriptor{
+			1: {Index: 1, Type: kDescPlayer, PictID: 447, Name: "Me"},
+			2: {Index: 2, Type: kDescPlayer, PictID: 447, Name: "Fallen"},
+			3: {Index: 3, Type: kDescMonster, PictID: 100, Name: "Rat"},
+		},
+		mobiles: map[uint8]frameMobile{
+			1: {Index: 1, H: 10, V: 10},
+			2: {Index: 2, H: 13, V: 14, State: poseDead},
+			3: {Index: 3, H: 40, V: 10},
+		},
+		pictures:   []framePicture{{PictID: 5, H: 1, V: 2, Plane: -1}},
+		hp:         5,
+		hpMax:      10,
+		balance:    3,
+		balanceMax: 4,
+	}
+	playerIndex = 1
+	prepareRenderCacheLocked()
+	stateMu.Unlock()
+	defer func() {
+		stateMu.Lock()
+		state = prevState
+		playerIndex = prevIndex
+		stateMu.Unlock()
+	}()
+
+	ms := scriptMobiles()
+	if len(ms) != 3 {
+		t.Fatalf("got %d mobiles", len(ms))
+	}
+	if ms[0].Name != "Me" || ms[1].Name != "Fallen" || ms[2].Name != "Rat" {
+		t.Fatalf("unexpected order: %+v", ms)
+	}
+	if !ms[1].Dead || ms[1].Range != 5 {
+		t.Fatalf("fallen mobile = %+v", ms[1])
+	}
+	if ms[2].Type != kDescMonster || ms[2].Range != 30 {
+		t.Fatalf("monster = %+v", ms[2])
+	}
+
+	self := scriptSelf()
+	if !self.Found || self.Name != "Me" || self.HP != 5 || self.BalanceMax != 4 {
+		t.Fatalf("self = %+v", self)
+	}
+	if ds := scriptDescriptors(); len(ds) != 3 || ds[0].Index != 1 {
+		t.Fatalf("descriptors = %+v", ds)
+	}
+	if ps := scriptPictures(); len(ps) != 1 || ps[0].PictID != 5 {
+		t.Fatalf("pictures = %+v", ps)
+	}
+}
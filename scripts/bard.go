@@ -9,6 +9,7 @@ const scriptName = "Bard Macros"
 const scriptAuthor = "Examples"
 const scriptCategory = "Profession"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 // Init sets up our commands and hotkeys.
 func Init() {
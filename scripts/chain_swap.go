@@ -12,6 +12,7 @@ const scriptName = "Chain Swap"
 const scriptAuthor = "Examples"
 const scriptCategory = "Equipment"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var savedName string
 var lastSwap time.Time
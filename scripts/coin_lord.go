@@ -14,28 +14,33 @@ const scriptName = "Coin Lord"
 const scriptAuthor = "Examples"
 const scriptCategory = "Quality Of Life"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,storage,window"
 
 var (
 	clRunning bool
 	clTotal   int
 	clStart   time.Time
+	// clChar is the character the totals belong to.
+	clChar string
+
+	// Window and widget handles.
+	clWin    int
+	clText   int
+	clRunBtn int
 )
 
 func Init() {
-	// Load saved totals.
-	if s := gt.Load("total"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil {
-			clTotal = n
-		}
-	}
-	if s := gt.Load("start"); s != "" {
-		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
-			clStart = time.Unix(n, 0)
-		}
-	}
-	if clStart.IsZero() {
-		clStart = time.Now()
-	}
+	// Totals are kept per character. Older versions saved them for all
+	// characters; the first character to load this version takes them.
+	gt.MoveToChar("total")
+	gt.MoveToChar("start")
+	clLoad()
+	// The script keeps running when you switch characters; load the new
+	// character's totals instead of saving these over them.
+	gt.OnCharacter(func(string) {
+		clLoad()
+		clUpdateWindow()
+	})
 
 	// Toggle counting with /cw.
 	gt.RegisterCommand("cw", cwCmd)
@@ -46,58 +51,103 @@ func Init() {
 	// Clear the total with /cwclear.
 	gt.RegisterCommand("cwclear", cwClearCmd)
 
-	// Show current totals with /cwdata or Shift+C.
+	// Show current totals with /cwdata.
 	gt.RegisterCommand("cwdata", cwDataCmd)
 
 	// Tally coin messages like "You get 3 coins"
 	gt.Chat("You get ", clHandle)
-	// Use a function hotkey instead of running a slash command.
+	// Shift+C toggles a window with live totals.
+	clWin = gt.NewWindow("Coin Lord")
+	clText = gt.AddText(clWin, "")
+	clRunBtn = gt.AddButton(clWin, "Start", func() { cwCmd("") })
+	gt.AddButton(clWin, "Reset", func() { cwNewCmd("") })
+	clUpdateWindow()
+	gt.Every(60000, clUpdateWindow)
 	gt.Key("Shift-C", cwDataHotkey)
 }
 
+// clLoad reads the current character's totals.
+func clLoad() {
+	clChar = gt.PlayerName()
+	clTotal = 0
+	clStart = time.Time{}
+	if s := gt.CharLoad("total"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil {
+			clTotal = n
+		}
+	}
+	if s := gt.CharLoad("start"); s != "" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			clStart = time.Unix(n, 0)
+		}
+	}
+	if clStart.IsZero() {
+		clStart = time.Now()
+	}
+}
+
+// clUpdateWindow refreshes the totals shown in the window.
+func clUpdateWindow() {
+	gt.SetText(clText, clSummary())
+	if clRunning {
+		gt.SetText(clRunBtn, "Stop")
+	} else {
+		gt.SetText(clRunBtn, "Start")
+	}
+}
+
+func clSummary() string {
+	hours := time.Since(clStart).Hours()
+	rate := 0.0
+	if hours > 0 {
+		rate = float64(clTotal) / hours
+	}
+	return fmt.Sprintf("Coins: %d (%.0f/hr)", clTotal, rate)
+}
+
 func cwCmd(args string) {
 	clRunning = !clRunning
 	if clRunning {
 		clStart = time.Now()
 		clTotal = 0
-		gt.Save("start", strconv.FormatInt(clStart.Unix(), 10))
-		gt.Save("total", "0")
+		gt.CharSave("start", strconv.FormatInt(clStart.Unix(), 10))
+		gt.CharSave("total", "0")
 		gt.Print("Coin Lord started")
 	} else {
-		gt.Save("start", strconv.FormatInt(clStart.Unix(), 10))
-		gt.Save("total", strconv.Itoa(clTotal))
+		gt.CharSave("start", strconv.FormatInt(clStart.Unix(), 10))
+		gt.CharSave("total", strconv.Itoa(clTotal))
 		gt.Print("Coin Lord stopped")
 	}
+	clUpdateWindow()
 }
 
 func cwNewCmd(args string) {
 	clStart = time.Now()
 	clTotal = 0
-	gt.Save("start", strconv.FormatInt(clStart.Unix(), 10))
-	gt.Save("total", "0")
+	gt.CharSave("start", strconv.FormatInt(clStart.Unix(), 10))
+	gt.CharSave("total", "0")
 	gt.Print("Coin data reset")
+	clUpdateWindow()
 }
 
 func cwClearCmd(args string) {
 	clTotal = 0
-	gt.Save("total", "0")
+	gt.CharSave("total", "0")
 	gt.Print("Coin total cleared")
+	clUpdateWindow()
 }
 
 func cwDataCmd(args string) {
-	hours := time.Since(clStart).Hours()
-	rate := 0.0
-	if hours > 0 {
-		rate = float64(clTotal) / hours
-	}
-	gt.Print(fmt.Sprintf("Coins: %d (%.0f/hr)", clTotal, rate))
+	gt.Print(clSummary())
 }
 
-func cwDataHotkey() { cwDataCmd("") }
+func cwDataHotkey() { gt.ToggleWindow(clWin) }
 
 // clHandle watches chat for messages like "You get 3 coins" and tallies them.
 func clHandle(msg string) {
-	if !clRunning {
+	// Until OnCharacter reloads, the totals in memory are another
+	// character's.
+	if !clRunning || gt.PlayerName() != clChar {
 		return
 	}
 	if !gt.StartsWith(msg, "You get ") || !gt.Includes(msg, " coin") {
@@ -112,5 +162,6 @@ func clHandle(msg string) {
 		return
 	}
 	clTotal += n
-	gt.Save("total", strconv.Itoa(clTotal))
+	gt.CharSave("total", strconv.Itoa(clTotal))
+	clUpdateWindow()
 }
@@ -9,6 +9,7 @@ const scriptName = "Creature Aliases"
 const scriptAuthor = "Examples"
 const scriptCategory = "Fun"
 const scriptAPIVersion = 1
+const scriptPermissions = "input"
 
 // Init registers a bunch of chat shortcuts so typing
 // "abo" automatically expands to the full creature name.
@@ -11,6 +11,7 @@ import (
 const scriptAuthor = "Examples"
 const scriptCategory = "Fun"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 const scriptName = "Dance Macros"
 
 // How to use:
@@ -12,6 +12,7 @@ import "gt"
 // - You can add or remove entries in the list below; each line is "short": "full".
 
 const scriptAPIVersion = 1
+const scriptPermissions = "input"
 const scriptName = "Default Shortcuts"
 const scriptAuthor = "Distortions"
 const scriptCategory = "Quality Of Life"
@@ -13,6 +13,7 @@ import (
 const scriptAuthor = "Examples"
 const scriptCategory = "Fun"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 const scriptName = "Dice Roller"
 
 // Init registers the /roll command.
@@ -0,0 +1,68 @@
+//go:build script
+
+package main
+
+import "gt"
+
+// script metadata
+const scriptName = "Follow Leader"
+const scriptAuthor = "Examples"
+const scriptCategory = "Movement"
+const scriptAPIVersion = 1
+const scriptPermissions = "input,move"
+
+// Type /leader Name to pick who to follow, then press F6 to start or stop
+// following. F7 takes one step toward the last clicked mobile.
+
+var (
+	leader    string
+	following bool
+)
+
+func Init() {
+	gt.RegisterCommand("leader", func(args string) {
+		leader = gt.Trim(args)
+		gt.Print("Following " + leader + " when F6 is pressed")
+	})
+	gt.Key("F6", toggleFollow)
+	gt.Key("F7", stepToTarget)
+	gt.OnFrame(followFrame)
+}
+
+func toggleFollow() {
+	if leader == "" {
+		gt.Print("Set a leader with /leader Name first")
+		return
+	}
+	following = !following
+	if !following {
+		gt.StopWalking()
+	}
+}
+
+// followFrame walks toward the leader while they are more than a few
+// steps away.
+func followFrame(f gt.FrameInfo) {
+	if !following {
+		return
+	}
+	for _, m := range gt.Mobiles() {
+		if !gt.IgnoreCase(m.Name, leader) {
+			continue
+		}
+		if m.Range > 40 {
+			gt.WalkTo(int(m.H), int(m.V))
+		} else {
+			gt.StopWalking()
+		}
+		return
+	}
+	gt.StopWalking()
+}
+
+func stepToTarget() {
+	c := gt.LastClick()
+	if c.OnMobile {
+		gt.ClickMobile(c.Mobile.Name)
+	}
+}
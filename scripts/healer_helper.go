@@ -11,6 +11,7 @@ const scriptName = "Healer Helper"
 const scriptAuthor = "Examples"
 const scriptCategory = "Profession"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 // Init subscribes to mouse click hotkeys rather than polling.
 func Init() {
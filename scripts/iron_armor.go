@@ -13,6 +13,7 @@ const scriptName = "Iron Armor Manager"
 const scriptAuthor = "Examples"
 const scriptCategory = "Equipment"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var armorCondition string
 
@@ -9,6 +9,7 @@ const scriptName = "Kudzu Helper"
 const scriptAuthor = "Examples"
 const scriptCategory = "Tools"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 // Init sets up a few helper commands for planting and moving kudzu seeds.
 func Init() {
@@ -13,6 +13,7 @@ const scriptName = "Ledger Actions"
 const scriptAuthor = "Examples"
 const scriptCategory = "Tools"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var fighters = []string{
 	"Angilsa", "Aktur", "Atkia", "Atkus", "Balthus", "Bodrus", "Darkus",
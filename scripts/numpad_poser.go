@@ -15,6 +15,7 @@ const scriptName = "Numpad Poser"
 const scriptAuthor = "Examples"
 const scriptCategory = "Fun"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 // Init binds each number key on the keypad to a fun pose.
 func Init() {
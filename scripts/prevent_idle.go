@@ -13,6 +13,7 @@ const scriptName = "Prevent Idle"
 const scriptAuthor = "Examples"
 const scriptCategory = "Quality Of Life"
 const scriptAPIVersion = 1
+const scriptPermissions = "send"
 
 const maxKeepAlive = 6 // 5 * 6 = 30min
 
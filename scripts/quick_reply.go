@@ -16,6 +16,7 @@ var scriptAuthor = "Examples"
 var scriptCategory = "Quality Of Life"
 
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var lastThinker string // remembers who last thought to us
 
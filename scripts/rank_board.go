@@ -0,0 +1,54 @@
+//go:build script
+
+package main
+
+import "gt"
+
+var scriptName = "Rank Board"
+var scriptAuthor = "Examples"
+var scriptCategory = "Tools"
+
+const scriptAPIVersion = 1
+const scriptPermissions = "window"
+
+// Rank Board lists the ranks published by Rank Decoder. Enable both
+// scripts and press Shift-R to show the board.
+
+var (
+	boardWin  int
+	boardList int
+	// Subscribe handlers can run at the same time, so they pass ranks
+	// to keepRanks, which alone holds the list.
+	rankLines = make(chan string, 20)
+)
+
+func Init() {
+	boardWin = gt.NewWindow("Rank Board")
+	boardList = gt.AddList(boardWin, []string{"No ranks yet."})
+	go keepRanks()
+	gt.Subscribe("rank", showRank)
+	gt.Key("Shift-R", func() { gt.ToggleWindow(boardWin) })
+}
+
+func showRank(payload any) {
+	m, ok := payload.(map[string]any)
+	if !ok {
+		return
+	}
+	rank, _ := m["rank"].(string)
+	msg, _ := m["message"].(string)
+	rankLines <- rank + " – " + msg
+}
+
+// keepRanks shows the last 20 ranks.
+func keepRanks() {
+	var ranks []string
+	for line := range rankLines {
+		ranks = append(ranks, line)
+		if len(ranks) > 20 {
+			ranks = ranks[len(ranks)-20:]
+		}
+		gt.SetList(boardList, ranks)
+		gt.ShowWindow(boardWin)
+	}
+}
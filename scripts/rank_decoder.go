@@ -10,6 +10,7 @@ var scriptAuthor = "Examples"
 var scriptCategory = "Tools"
 
 const scriptAPIVersion = 1
+const scriptPermissions = ""
 
 // rankMessages maps trainer phrases to their numerical rank ranges.
 var rankMessages = map[string]string{
@@ -84,6 +85,8 @@ func rankDecodeChat(msg string) {
 	for phrase, rank := range rankMessages {
 		if gt.Includes(msg, phrase) {
 			gt.ShowNotification("Rank " + rank)
+			// Let other scripts, such as Rank Board, show the result.
+			gt.Publish("rank", map[string]string{"rank": rank, "message": phrase})
 			break
 		}
 	}
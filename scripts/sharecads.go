@@ -12,6 +12,7 @@ const scriptName = "Sharecads"
 const scriptAuthor = "Examples"
 const scriptCategory = "Quality Of Life"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var (
 	scOn    bool
@@ -9,6 +9,7 @@ const scriptName = "Weapon Cycle"
 const scriptAuthor = "Examples"
 const scriptCategory = "Equipment"
 const scriptAPIVersion = 1
+const scriptPermissions = "input,send"
 
 var cycleItems = []string{"Axe", "Short Sword", "Dagger", "Chocolate"}
 
@@ -19,6 +19,7 @@ const scriptName = "Yes Boats"
 const scriptAuthor = "Example"
 const scriptCategory = "Quality Of Life"
 const scriptAPIVersion = 1
+const scriptPermissions = "send"
 
 var (
 	lastYes     time.Time         // last time we replied
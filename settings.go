@@ -171,6 +171,11 @@ var gsdef settings = settings{
 	ScriptSpamKill:        true,
 	PromptOnSaveRecording: true,
 	AutoRecord:            false,
+	InstantReplay:         true,
+	InstantReplayMinutes:  5,
+	AutoRecordOnFall:      false,
+	AutoRecordTriggers:    "",
+	AutoRecordStopMinutes: 5,
 	PromptDisableShaders:  true,
 	ChatTimestamps:        false,
 	ConsoleTimestamps:     false,
@@ -205,6 +210,9 @@ var gsdef settings = settings{
 	MusicEnhancement:       true,
 	HighQualityResampling:  false,
 	ServerAddress:          defaultServerHostName + ":5010",
+	AutoReconnect:          false,
+	ReconnectAttempts:      10,
+	NetQualityLog:          false,
 
 	NightEffect:    true,
 	ShaderLighting: false,
@@ -330,6 +338,16 @@ type settings struct {
 	ScriptSpamKill        bool
 	PromptOnSaveRecording bool
 	AutoRecord            bool
+	// InstantReplay keeps the last InstantReplayMinutes of play in memory
+	// so /instantreplay can save them as a movie.
+	InstantReplay        bool
+	InstantReplayMinutes int
+	// AutoRecordOnFall and AutoRecordTriggers (comma separated text, such
+	// as area names) start a recording that stops AutoRecordStopMinutes
+	// after the last trigger.
+	AutoRecordOnFall      bool
+	AutoRecordTriggers    string
+	AutoRecordStopMinutes int
 	PromptDisableShaders  bool
 	ChatTimestamps        bool
 	ConsoleTimestamps     bool
@@ -356,6 +374,10 @@ type settings struct {
 	MessagesWindow  WindowState
 	ChatWindow      WindowState
 	WindowZones     map[string]eui.WindowZoneState
+	// ScriptWindows holds script window geometry keyed by "script/title".
+	ScriptWindows map[string]WindowState
+	// ScriptConsent maps a script to the permissions the user accepted.
+	ScriptConsent map[string]string
 
 	ShaderLightStrength float64
 	ShaderGlowStrength  float64
@@ -378,6 +400,11 @@ type settings struct {
 	altNetMode        bool
 	altNetDelay       int
 	ServerAddress     string
+	// AutoReconnect retries login with backoff when the connection drops,
+	// up to ReconnectAttempts times (0 means no limit).
+	AutoReconnect     bool
+	ReconnectAttempts int
+	NetQualityLog     bool // per-second connection CSV of each session in logs/
 	hideMoving        bool
 	hideMobiles       bool
 	vsync             bool
@@ -634,6 +661,9 @@ func syncWindowSettings() bool {
 		gs.ChatWindow.Open = false
 		changed = true
 	}
+	if syncscriptWindows() {
+		changed = true
+	}
 	zones := eui.SaveWindowZones()
 	if !reflect.DeepEqual(zones, gs.WindowZones) {
 		gs.WindowZones = zones
@@ -76,6 +76,10 @@ func stopAllAudioPlayers() {
 // Each ID is loaded, mixed with simple clipping and then played at the current
 // global volume. The function returns immediately after scheduling playback.
 func playSound(ids []uint16) {
+	if videoAudio != nil {
+		videoAudio.addSounds(ids)
+		return
+	}
 	if len(ids) == 0 || gs.Mute || focusMuted || !gs.GameSound {
 		return
 	}
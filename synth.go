@@ -533,7 +533,21 @@ func dumpPCMAsWAV(pcm []byte) {
 	}
 	defer f.Close()
 
-	dataLen := uint32(len(pcm))
+	header := wavHeader(uint32(len(pcm)))
+	if _, err := f.Write(header[:]); err != nil {
+		log.Printf("dump music header: %v", err)
+		return
+	}
+	if _, err := f.Write(pcm); err != nil {
+		log.Printf("dump music data: %v", err)
+		return
+	}
+	log.Printf("wrote %s", name)
+}
+
+// wavHeader returns a canonical 44-byte WAV header for 16-bit stereo PCM at
+// sampleRate holding dataLen bytes of sample data.
+func wavHeader(dataLen uint32) [44]byte {
 	var header [44]byte
 	copy(header[0:], []byte("RIFF"))
 	binary.LittleEndian.PutUint32(header[4:], 36+dataLen)
@@ -548,14 +562,5 @@ func dumpPCMAsWAV(pcm []byte) {
 	binary.LittleEndian.PutUint16(header[34:], 16)
 	copy(header[36:], []byte("data"))
 	binary.LittleEndian.PutUint32(header[40:], dataLen)
-
-	if _, err := f.Write(header[:]); err != nil {
-		log.Printf("dump music header: %v", err)
-		return
-	}
-	if _, err := f.Write(pcm); err != nil {
-		log.Printf("dump music data: %v", err)
-		return
-	}
-	log.Printf("wrote %s", name)
+	return header
 }
@@ -309,6 +309,9 @@ func parseShareText(raw []byte, s string) bool {
 func parseFallenText(raw []byte, s string) bool {
 	if playerName != "" {
 		if strings.HasPrefix(s, "You have fallen") {
+			if gs.AutoRecordOnFall {
+				autoRecordTrigger("you have fallen")
+			}
 			playersMu.Lock()
 			if p, ok := players[playerName]; ok {
 				p.Dead = true
@@ -166,18 +166,7 @@ func playClanLordTune(tune string) error {
 	prog := instruments[inst].program
 
 	// Enqueue for sequential playback and return immediately.
-	tuneOnce.Do(startTuneWorker)
-	select {
-	case tuneQueue <- tuneJob{program: prog, notes: ns}:
-	default:
-		// If the queue is full, drop the oldest by draining one then enqueue.
-		// This prevents unbounded growth during bursts.
-		select {
-		case <-tuneQueue:
-		default:
-		}
-		tuneQueue <- tuneJob{program: prog, notes: ns}
-	}
+	enqueueTune(tuneJob{program: prog, notes: ns})
 	return nil
 }
 
@@ -414,10 +403,16 @@ func makeTuneJob(who, inst, tempo, vol int, notes string) tuneJob {
 }
 
 func enqueueTune(job tuneJob) {
+	if videoAudio != nil {
+		videoAudio.addTune(job)
+		return
+	}
 	tuneOnce.Do(startTuneWorker)
 	select {
 	case tuneQueue <- job:
 	default:
+		// If the queue is full, drop the oldest by draining one then enqueue.
+		// This prevents unbounded growth during bursts.
 		select {
 		case <-tuneQueue:
 		default:
@@ -92,6 +92,8 @@ var selectedscript string
 var scriptConfigWin *eui.WindowData
 var scriptConfigOwner string
 var scriptDebugList *eui.ItemData
+var scriptStatsWin *eui.WindowData
+var scriptStatsList *eui.ItemData
 
 // Checkboxes in the Windows window so we can update their state live
 var windowsPlayersCB *eui.ItemData
@@ -119,6 +121,7 @@ var (
 	recordBtn          *eui.ItemData
 	recordStatus       *eui.ItemData
 	recordPath         string
+	recordMeta         clmovMeta
 	qualityPresetDD    *eui.ItemData
 	shaderLightSlider  *eui.ItemData
 	shaderGlowSlider   *eui.ItemData
@@ -525,6 +528,19 @@ func makescriptsWindow() {
 	}
 	buttonsBottom.AddItem(openBtn)
 
+	statsBtn, sh := eui.NewButton()
+	statsBtn.Text = "Stats"
+	statsBtn.SetTooltip("Show time used and errors per script")
+	statsBtn.Size = eui.Point{X: 64, Y: 24}
+	sh.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			makescriptStatsWindow()
+			refreshscriptStatsWindow()
+			scriptStatsWin.ToggleNear(ev.Item)
+		}
+	}
+	buttonsBottom.AddItem(statsBtn)
+
 	debugFlow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL}
 	root.AddItem(debugFlow)
 	debugCB, debugEvents := eui.NewCheckbox()
@@ -799,6 +815,11 @@ func refreshscriptDetails() {
 	}
 	line("Category: " + catLabel)
 	line("Status: " + status)
+	perms := scriptPermString(owner)
+	if perms == "" {
+		perms = "none"
+	}
+	line("Permissions: " + perms)
 	errText := "None"
 	if invalid {
 		errText = "Invalid script"
@@ -877,6 +898,71 @@ func refreshscriptDebug() {
 	}
 }
 
+func makescriptStatsWindow() {
+	if scriptStatsWin != nil {
+		return
+	}
+	scriptStatsWin = eui.NewWindow()
+	scriptStatsWin.Title = "Script Stats"
+	scriptStatsWin.Closable = true
+	scriptStatsWin.Resizable = false
+	scriptStatsWin.AutoSize = true
+	scriptStatsWin.Movable = true
+	scriptStatsWin.SetZone(eui.HZoneCenterLeft, eui.VZoneMiddleTop)
+
+	scriptStatsList = &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_VERTICAL, Scrollable: true}
+	scriptStatsWin.AddItem(scriptStatsList)
+	scriptStatsWin.AddWindow(false)
+}
+
+// refreshscriptStatsWindow rebuilds the per-script resource table.
+func refreshscriptStatsWindow() {
+	if scriptStatsList == nil {
+		return
+	}
+	scriptStatsList.Contents = scriptStatsList.Contents[:0]
+	rows := scriptStatsSnapshot()
+	if len(rows) == 0 {
+		t, _ := eui.NewText()
+		t.Text = "No scripts have run yet"
+		t.FontSize = 12
+		t.Size = eui.Point{X: 400, Y: 16}
+		scriptStatsList.AddItem(t)
+	}
+	scriptMu.RLock()
+	names := make(map[string]string, len(rows))
+	for _, r := range rows {
+		names[r.owner] = scriptDisplayNames[r.owner]
+	}
+	scriptMu.RUnlock()
+	now := time.Now()
+	for _, r := range rows {
+		name := names[r.owner]
+		if name == "" {
+			name = r.owner
+		}
+		if scriptIsDisabled(r.owner) {
+			name += " (stopped)"
+		}
+		t, _ := eui.NewText()
+		t.Text = fmt.Sprintf("%s: %v busy (wall time) in %d calls, %d running, %d dropped",
+			name, r.busy.Round(time.Millisecond), r.calls, r.running, r.dropped)
+		t.FontSize = 12
+		t.Size = eui.Point{X: 480, Y: 16}
+		scriptStatsList.AddItem(t)
+		if r.lastErr != "" {
+			e, _ := eui.NewText()
+			e.Text = fmt.Sprintf("    %s ago: %s", now.Sub(r.lastErrAt).Round(time.Second), r.lastErr)
+			e.FontSize = 10
+			e.Size = eui.Point{X: 480, Y: 14}
+			scriptStatsList.AddItem(e)
+		}
+	}
+	if scriptStatsWin != nil {
+		scriptStatsWin.Refresh()
+	}
+}
+
 func openscriptConfigWindow(owner string) {
 	scriptConfigMu.RLock()
 	entries := scriptConfigEntries[owner]
@@ -1376,14 +1462,28 @@ func confirmExitSession() {
 }
 
 func startRecording() {
+	mr := newRecording()
+	if mr == nil {
+		return
+	}
+	recorder = mr
+	wroteLoginBlocks = false
+	consoleMessage(fmt.Sprintf("recording to %s", filepath.Base(recordPath)))
+	updateRecordButton()
+}
+
+// newRecording creates a movie file in the Movies folder and sets
+// recordPath and recordMeta for it. It returns nil after reporting an
+// error.
+func newRecording() *movieRecorder {
 	if isWASM {
 		consoleMessage("movie recording unavailable in browser build")
-		return
+		return nil
 	}
 	dir := filepath.Join(dataDirPath, "Movies")
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		logError("record movie: %v", err)
-		return
+		return nil
 	}
 	ts := time.Now().Format("2006-01-02-15-04-05")
 	base := gs.LastCharacter
@@ -1396,12 +1496,13 @@ func startRecording() {
 	if err != nil {
 		logError("record movie: %v", err)
 		recordPath = ""
-		return
+		return nil
 	}
-	recorder = mr
-	wroteLoginBlocks = false
-	consoleMessage(fmt.Sprintf("recording to %s", filepath.Base(recordPath)))
-	updateRecordButton()
+	recordMeta = clmovMeta{Character: gs.LastCharacter, ClientVersion: clVersion, Recorded: time.Now().UTC()}
+	if tcpConn != nil {
+		recordMeta.Server = tcpConn.RemoteAddr().String()
+	}
+	return mr
 }
 
 func stopRecording() {
@@ -1413,6 +1514,7 @@ func stopRecording() {
 	}
 	recorder = nil
 	wroteLoginBlocks = false
+	autoRecordUntil = time.Time{}
 	if recordPath != "" {
 		saved := recordPath
 		consoleMessage(fmt.Sprintf("saved movie: %s", filepath.Base(saved)))
@@ -1461,6 +1563,8 @@ var status dataFilesStatus
 var recordSaveWin *eui.WindowData
 var recordSaveInput *eui.ItemData
 var recordSaveCompressCB *eui.ItemData
+var recordSaveContainerCB *eui.ItemData
+var recordSaveTagsInput *eui.ItemData
 var recordSaveDontShowCB *eui.ItemData
 
 func showRecordingSaveDialog(path string) {
@@ -1504,6 +1608,23 @@ func showRecordingSaveDialog(path string) {
 	recordSaveCompressCB.Size = eui.Point{X: 420, Y: 24}
 	flow.AddItem(recordSaveCompressCB)
 
+	recordSaveContainerCB, _ = eui.NewCheckbox()
+	recordSaveContainerCB.Text = ".clMovZ container (compressed, keeps character, server and tags)"
+	recordSaveContainerCB.Size = eui.Point{X: 420, Y: 24}
+	flow.AddItem(recordSaveContainerCB)
+
+	tagsRow := &eui.ItemData{ItemType: eui.ITEM_FLOW, FlowType: eui.FLOW_HORIZONTAL, Fixed: true}
+	tagsLbl, _ := eui.NewText()
+	tagsLbl.Text = "Tags:"
+	tagsLbl.Size = eui.Point{X: 64, Y: 24}
+	tagsLbl.FontSize = 12
+	tagsRow.AddItem(tagsLbl)
+	recordSaveTagsInput, _ = eui.NewInput()
+	recordSaveTagsInput.Size = eui.Point{X: 340, Y: 24}
+	recordSaveTagsInput.FontSize = 12
+	tagsRow.AddItem(recordSaveTagsInput)
+	flow.AddItem(tagsRow)
+
 	recordSaveDontShowCB, _ = eui.NewCheckbox()
 	recordSaveDontShowCB.Text = "Don't show this again"
 	recordSaveDontShowCB.Size = eui.Point{X: 420, Y: 24}
@@ -1568,6 +1689,22 @@ func showRecordingSaveDialog(path string) {
 				}
 			}(path)
 		}
+		// Write a .clMovZ container next to it if requested.
+		if recordSaveContainerCB != nil && recordSaveContainerCB.Checked {
+			meta := recordMeta
+			if recordSaveTagsInput != nil {
+				meta.Tags = parseTags(recordSaveTagsInput.Text)
+			}
+			go func(src string) {
+				dst := strings.TrimSuffix(src, filepath.Ext(src)) + ".clMovZ"
+				if err := convertToClmovZ(src, dst, meta, true); err != nil {
+					logError("clMovZ: %v", err)
+					consoleMessage("clMovZ failed: " + err.Error())
+				} else {
+					consoleMessage("saved: " + filepath.Base(dst))
+				}
+			}(path)
+		}
 		if recordSaveWin != nil {
 			recordSaveWin.Close()
 		}
@@ -2419,7 +2556,9 @@ func startLogin() {
 			makeErrorWindow("Error: Login: " + err.Error())
 			return
 		}
-		closeConnectDialog()
+		if !reconnectPending() {
+			closeConnectDialog()
+		}
 	}()
 }
 
@@ -2650,6 +2789,8 @@ func makeLoginWindow() {
 				applyEnabledScripts()
 				ctx, cancel := context.WithCancel(gameCtx)
 				mp := newMoviePlayer(frames, clMovFPS, cancel)
+				mp.loadNotes(filename)
+				mp.addKeyframes(loadMovieKeyframes(filename))
 				mp.makePlaybackWindow()
 				run := func() { go mp.run(ctx) }
 				if (gs.precacheSounds || gs.precacheImages) && !assetsPrecached {
@@ -4732,6 +4873,91 @@ func makeAdvancedSettingsWindow() {
 	}
 	toolsCol.AddItem(autoRecCB)
 
+	replayCB, replayEvents := eui.NewCheckbox()
+	replayCB.Text = "Keep instant replay"
+	replayCB.Size = eui.Point{X: columnWidth, Y: 24}
+	replayCB.Checked = gs.InstantReplay
+	replayCB.SetTooltip("Keep the last minutes of play in memory; /instantreplay (or a hotkey running it) saves them as a movie")
+	replayEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventCheckboxChanged {
+			SettingsLock.Lock()
+			gs.InstantReplay = ev.Checked
+			SettingsLock.Unlock()
+			if !ev.Checked {
+				replayBuf.reset()
+			}
+			settingsDirty = true
+		}
+	}
+	toolsCol.AddItem(replayCB)
+
+	replayMinSlider, replayMinEvents := eui.NewSlider()
+	replayMinSlider.Label = "Instant replay minutes"
+	replayMinSlider.MinValue = 1
+	replayMinSlider.MaxValue = 30
+	replayMinSlider.IntOnly = true
+	replayMinSlider.Value = float32(gs.InstantReplayMinutes)
+	replayMinSlider.Size = eui.Point{X: columnWidth - 10, Y: 24}
+	replayMinSlider.SetTooltip("How much play the instant replay keeps")
+	replayMinEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventSliderChanged {
+			SettingsLock.Lock()
+			gs.InstantReplayMinutes = int(ev.Value)
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	toolsCol.AddItem(replayMinSlider)
+
+	autoFallCB, autoFallEvents := eui.NewCheckbox()
+	autoFallCB.Text = "Auto-record when fallen"
+	autoFallCB.Size = eui.Point{X: columnWidth, Y: 24}
+	autoFallCB.Checked = gs.AutoRecordOnFall
+	autoFallCB.SetTooltip("Start recording, including the instant replay, when you fall")
+	autoFallEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventCheckboxChanged {
+			SettingsLock.Lock()
+			gs.AutoRecordOnFall = ev.Checked
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	toolsCol.AddItem(autoFallCB)
+
+	autoTextInput, autoTextEvents := eui.NewInput()
+	autoTextInput.Label = "Auto-record on text"
+	autoTextInput.Text = gs.AutoRecordTriggers
+	autoTextInput.TextPtr = &gs.AutoRecordTriggers
+	autoTextInput.Size = eui.Point{X: columnWidth, Y: 24}
+	autoTextInput.SetTooltip("Comma separated; start recording when a message contains one, e.g. an area name")
+	autoTextEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventInputChanged {
+			SettingsLock.Lock()
+			gs.AutoRecordTriggers = ev.Text
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	toolsCol.AddItem(autoTextInput)
+
+	autoStopSlider, autoStopEvents := eui.NewSlider()
+	autoStopSlider.Label = "Auto-record minutes"
+	autoStopSlider.MinValue = 1
+	autoStopSlider.MaxValue = 30
+	autoStopSlider.IntOnly = true
+	autoStopSlider.Value = float32(gs.AutoRecordStopMinutes)
+	autoStopSlider.Size = eui.Point{X: columnWidth - 10, Y: 24}
+	autoStopSlider.SetTooltip("Stop an automatic recording this long after the last trigger")
+	autoStopEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventSliderChanged {
+			SettingsLock.Lock()
+			gs.AutoRecordStopMinutes = int(ev.Value)
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	toolsCol.AddItem(autoStopSlider)
+
 	// Interface column
 	addSectionLabel(interfaceCol, "Interface")
 
@@ -5101,6 +5327,39 @@ func makeAdvancedSettingsWindow() {
 	}
 	systemCol.AddItem(serverInput)
 
+	reconnectCB, reconnectEvents := eui.NewCheckbox()
+	reconnectCB.Text = "Reconnect automatically"
+	reconnectCB.Size = eui.Point{X: columnWidth, Y: 24}
+	reconnectCB.Checked = gs.AutoReconnect
+	reconnectCB.SetTooltip("When the connection drops, log back in instead of returning to the login window")
+	reconnectEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventCheckboxChanged {
+			SettingsLock.Lock()
+			gs.AutoReconnect = ev.Checked
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	systemCol.AddItem(reconnectCB)
+
+	reconnectSlider, reconnectSliderEvents := eui.NewSlider()
+	reconnectSlider.Label = "Reconnect attempts"
+	reconnectSlider.MinValue = 0
+	reconnectSlider.MaxValue = 50
+	reconnectSlider.IntOnly = true
+	reconnectSlider.Value = float32(gs.ReconnectAttempts)
+	reconnectSlider.Size = eui.Point{X: columnWidth - 10, Y: 24}
+	reconnectSlider.SetTooltip("Give up after this many tries (0 keeps trying)")
+	reconnectSliderEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventSliderChanged {
+			SettingsLock.Lock()
+			gs.ReconnectAttempts = int(ev.Value)
+			SettingsLock.Unlock()
+			settingsDirty = true
+		}
+	}
+	systemCol.AddItem(reconnectSlider)
+
 	pingLabel, _ := eui.NewText()
 	pingLabel.Text = ""
 	pingLabel.Size = eui.Point{X: columnWidth, Y: 24}
@@ -5135,6 +5394,9 @@ func makeAdvancedSettingsWindow() {
 						time.Sleep(200 * time.Millisecond)
 					}
 				}
+				if worst > 0 {
+					netHealth.ping(worst)
+				}
 				pingLabel.Text = fmt.Sprintf("Ping: %d ms", worst.Milliseconds())
 				pingLabel.Dirty = true
 				advancedWin.Refresh()
@@ -5143,6 +5405,17 @@ func makeAdvancedSettingsWindow() {
 	}
 	systemCol.AddItem(pingBtn)
 
+	netWinBtn, netWinEvents := eui.NewButton()
+	netWinBtn.Text = "Network Health"
+	netWinBtn.Size = eui.Point{X: columnWidth, Y: 24}
+	netWinBtn.SetTooltip("Graphs of the connection to tell client, network and server lag apart")
+	netWinEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			makeNetHealthWindow()
+		}
+	}
+	systemCol.AddItem(netWinBtn)
+
 	addSectionLabel(systemCol, "Performance")
 
 	psBGCB, psBGEvents := eui.NewCheckbox()
@@ -5526,6 +5799,54 @@ func makeDebugWindow() {
 	totalCacheLabel.FontSize = 10
 	debugFlow.AddItem(totalCacheLabel)
 
+	inspectorBtn, inspectorEvents := eui.NewButton()
+	inspectorBtn.Text = "Protocol Inspector"
+	inspectorBtn.Size = eui.Point{X: width, Y: 24}
+	inspectorBtn.SetTooltip("List server messages with decoded fields and hex dumps")
+	inspectorEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			makeInspectorWindow()
+		}
+	}
+	debugFlow.AddItem(inspectorBtn)
+
+	netHealthBtn, netHealthEvents := eui.NewButton()
+	netHealthBtn.Text = "Network Health"
+	netHealthBtn.Size = eui.Point{X: width, Y: 24}
+	netHealthBtn.SetTooltip("Graph frame interval, latency, jitter and dropped frames")
+	netHealthEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type == eui.EventClick {
+			makeNetHealthWindow()
+		}
+	}
+	debugFlow.AddItem(netHealthBtn)
+
+	netSimInput, netSimEvents := eui.NewInput()
+	netSimInput.Label = "Simulated network"
+	netSimInput.Size = eui.Point{X: width, Y: 24}
+	if cfg := currentNetSim(); cfg.enabled() {
+		netSimInput.Text = cfg.String()
+	}
+	netSimInput.SetTooltip("e.g. latency=150ms,jitter=40ms,loss=5%,reorder=1%,dup=1%,seed=1. Applies to movie playback at once and to the server connection from the next login")
+	debugFlow.AddItem(netSimInput)
+	netSimStatus, _ := eui.NewText()
+	netSimStatus.Text = "Network simulation: " + currentNetSim().String()
+	netSimStatus.Size = eui.Point{X: width, Y: 20}
+	netSimStatus.FontSize = 10
+	netSimEvents.Handle = func(ev eui.UIEvent) {
+		if ev.Type != eui.EventInputChanged {
+			return
+		}
+		if cfg, err := parseNetSim(ev.Text); err != nil {
+			netSimStatus.Text = err.Error()
+		} else {
+			setNetSim(cfg)
+			netSimStatus.Text = "Network simulation: " + cfg.String()
+		}
+		netSimStatus.Dirty = true
+	}
+	debugFlow.AddItem(netSimStatus)
+
 	debugWin.AddItem(debugFlow)
 
 	debugWin.AddWindow(false)